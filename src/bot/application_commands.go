@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	. "elaina-common"
 	"elaina-common/restapi"
 	"errors"
@@ -24,7 +25,7 @@ func (c CommandCollection) GetCommand(name string) *ApplicationCommand {
 
 // dispatchCommand attempts to execute the command given an input ApplicationCommandData from discord. The data should
 // be verified to be of the correct type of command prior to calling dispatchCommand
-func dispatchCommand(c *ApplicationCommand, guild Snowflake, interactionId Snowflake, interactionToken string, data ApplicationCommandData) error {
+func dispatchCommand(ctx context.Context, c *ApplicationCommand, guild Snowflake, interactionId Snowflake, interactionToken string, data ApplicationCommandData) error {
 	params := CommandParams{
 		GuildId:          guild,
 		InteractionId:    interactionId,
@@ -37,7 +38,7 @@ func dispatchCommand(c *ApplicationCommand, guild Snowflake, interactionId Snowf
 		slog.Info("[Command] Dispatching application command: " + c.Name)
 
 		params.Options = &data.Options
-		if err := c.Handler(params); err != nil {
+		if err := c.Handler(ctx, params); err != nil {
 			return err
 		}
 		return nil
@@ -78,14 +79,14 @@ func dispatchCommand(c *ApplicationCommand, guild Snowflake, interactionId Snowf
 		return fmt.Errorf("subcommand %s does not have a handler", subcommand.Name)
 	}
 
-	return subcommand.Handler(params)
+	return subcommand.Handler(ctx, params)
 }
 
-func DeployCommands(commands CommandCollection) {
+func DeployCommands(ctx context.Context, commands CommandCollection) {
 	slog.Info("Deploying application commands...")
 	for _, com := range commands {
 		func() {
-			_, err := restapi.CreateOrUpdateCommand(com)
+			_, err := restapi.CreateOrUpdateCommand(ctx, com)
 			if err != nil {
 				slog.Error("Error registering command: ", slog.String("command", com.Name), slog.String("error", err.Error()))
 				return
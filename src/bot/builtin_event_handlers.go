@@ -1,21 +1,22 @@
 package main
 
 import (
+	"context"
 	. "elaina-common"
 	"encoding/json"
 	"log/slog"
 )
 
 // interactionCreateEvent dispatches ApplicationCommands
-func interactionCreateEvent(payload InteractionCreatePayload) error { // Built-in event handler for dispatching application Commands
+func interactionCreateEvent(ctx context.Context, payload InteractionCreatePayload) error { // Built-in event handler for dispatching application Commands
 	if payload.Type != 2 { // https://discord.com/developers/docs/interactions/receiving-and-responding#interaction-object-interaction-data
 		return nil
 	}
 
 	var c ApplicationCommandData
 	if err := json.Unmarshal(*payload.Data, &c); err != nil {
-		slog.Error("[Command] Failed to parse application command data: " + err.Error())
-		_ = SendInteractionResponse(InteractionResponse{
+		eventLogger(ctx).Error("[Command] Failed to parse application command data: " + err.Error())
+		_ = SendInteractionResponse(ctx, InteractionResponse{
 			Type: RespTypeChannelMessage,
 			Data: Message{Content: "Elaina couldn't parse this command, you should report this to the developers!: " + err.Error(), Flags: MsgFlagEphemeral},
 		}, payload.Id, payload.Token)
@@ -24,13 +25,13 @@ func interactionCreateEvent(payload InteractionCreatePayload) error { // Built-i
 
 	command := Commands.GetCommand(c.Name)
 	if command == nil {
-		slog.Warn("[Command] Application command was dispatched but no handler was found: " + c.Name)
+		eventLogger(ctx).Warn("[Command] Application command was dispatched but no handler was found: " + c.Name)
 		return nil
 	}
 
-	if err := dispatchCommand(command, payload.GuildId, payload.Id, payload.Token, c); err != nil {
-		slog.Error("[Command] Error executing application command: ", slog.String("command", c.Name), slog.String("error", err.Error()))
-		_ = SendInteractionResponse(InteractionResponse{
+	if err := dispatchCommand(ctx, command, payload.GuildId, payload.Id, payload.Token, c); err != nil {
+		eventLogger(ctx).Error("[Command] Error executing application command: ", slog.String("command", c.Name), slog.String("error", err.Error()))
+		_ = SendInteractionResponse(ctx, InteractionResponse{
 			Type: RespTypeChannelMessage,
 			Data: Message{Content: "An error occurred executing this command: " + err.Error(), Flags: MsgFlagEphemeral},
 		}, payload.Id, payload.Token)
@@ -38,42 +39,103 @@ func interactionCreateEvent(payload InteractionCreatePayload) error { // Built-i
 	return nil
 }
 
-func updateChannelEvent(payload UpdateChannelPayload) error {
+func createChannelEvent(ctx context.Context, payload CreateChannelPayload) error {
+	State.SetChannel(payload)
+	return nil
+}
+
+func updateChannelEvent(ctx context.Context, payload UpdateChannelPayload) error {
+	ChannelCache.Update(payload.Id, payload)
+	State.SetChannel(payload)
+	return nil
+}
+
+func deleteChannelEvent(ctx context.Context, payload DeleteChannelPayload) error {
+	ChannelCache.Invalidate(payload.Id)
+	State.RemoveChannel(payload.Id)
+	return nil
+}
+
+func createThreadEvent(ctx context.Context, payload CreateThreadPayload) error {
+	State.SetChannel(payload.Channel)
+	return nil
+}
+
+func updateThreadEvent(ctx context.Context, payload UpdateThreadPayload) error {
 	ChannelCache.Update(payload.Id, payload)
+	State.SetChannel(payload)
 	return nil
 }
 
-func deleteChannelEvent(payload DeleteChannelPayload) error {
+func deleteThreadEvent(ctx context.Context, payload DeleteThreadPayload) error {
 	ChannelCache.Invalidate(payload.Id)
+	State.RemoveChannel(payload.Id)
 	return nil
 }
 
-func updateMessageEvent(payload UpdateMessagePayload) error {
+func updateMessageEvent(ctx context.Context, payload UpdateMessagePayload) error {
 	MessageCache.Update(payload.Id, payload.Message)
 	return nil
 }
 
-func deleteMessageEvent(payload DeleteMessagePayload) error {
+func deleteMessageEvent(ctx context.Context, payload DeleteMessagePayload) error {
 	MessageCache.Invalidate(payload.Id)
 	return nil
 }
 
-func updateRoleEvent(payload UpdateRolePayload) error {
+func createRoleEvent(ctx context.Context, payload CreateRolePayload) error {
+	State.SetRole(payload.GuildId, payload.Role)
+	return nil
+}
+
+func updateRoleEvent(ctx context.Context, payload UpdateRolePayload) error {
 	RoleCache.Update(payload.Role.Id, payload.Role)
+	State.SetRole(payload.GuildId, payload.Role)
 	return nil
 }
 
-func deleteRoleEvent(payload DeleteRolePayload) error {
+func deleteRoleEvent(ctx context.Context, payload DeleteRolePayload) error {
 	RoleCache.Invalidate(payload.RoleId)
+	State.RemoveRole(payload.GuildId, payload.RoleId)
+	return nil
+}
+
+func createGuildEvent(ctx context.Context, payload CreateGuildPayload) error {
+	State.AddGuild(payload)
 	return nil
 }
 
-func updateGuildEvent(payload UpdateGuildPayload) error {
+func updateGuildEvent(ctx context.Context, payload UpdateGuildPayload) error {
 	GuildCache.Update(payload.Id, payload)
+	State.UpdateGuild(payload)
 	return nil
 }
 
-func deleteGuildEvent(payload DeleteGuildPayload) error {
+func deleteGuildEvent(ctx context.Context, payload DeleteGuildPayload) error {
 	GuildCache.Invalidate(payload.Id)
+	if payload.Unavailable { // The guild is in an outage, discord will send GUILD_CREATE once it is back
+		State.SetUnavailable(payload.Id)
+	} else {
+		State.RemoveGuild(payload.Id)
+	}
+	return nil
+}
+
+func addGuildMemberEvent(ctx context.Context, payload AddGuildMemberPayload) error {
+	State.SetMember(payload.GuildId, payload.GuildMember)
+	return nil
+}
+
+func updateGuildMemberEvent(ctx context.Context, payload UpdateGuildMemberPayload) error {
+	if payload.User != nil {
+		GuildMemberCache.Invalidate(payload.User.Id)
+	}
+	State.SetMember(payload.GuildId, payload.GuildMember)
+	return nil
+}
+
+func removeGuildMemberEvent(ctx context.Context, payload RemoveGuildMemberPayload) error {
+	GuildMemberCache.Invalidate(payload.User.Id)
+	State.RemoveMember(payload.GuildId, payload.User.Id)
 	return nil
 }
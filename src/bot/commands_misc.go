@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	. "elaina-common"
+	"fmt"
 	"log/slog"
+	"time"
 )
 
 var echoCommand = ApplicationCommand{
@@ -20,6 +23,13 @@ var echoCommand = ApplicationCommand{
 	},
 }
 
+var pingCommand = ApplicationCommand{
+	Name:        "ping",
+	Type:        CmdTypeChatInput,
+	Description: "Shows Elaina's gateway and REST latency",
+	Handler:     pingHandler,
+}
+
 var macroCommand = ApplicationCommand{
 	Name:        "macro",
 	Description: "Macros are a handy way to save a message and fetch it using a keyword",
@@ -82,12 +92,29 @@ var editMacroCommand = ApplicationCommand{
 	},
 }
 
-func echoHandler(params CommandParams) error {
+func echoHandler(ctx context.Context, params CommandParams) error {
 	echo := params.GetOption("string").AsString()
-	return SendInteractionMessageResponse(Message{Content: echo, Flags: MsgFlagEphemeral}, params.InteractionId, params.InteractionToken)
+	return SendInteractionMessageResponse(ctx, Message{Content: echo, Flags: MsgFlagEphemeral}, params.InteractionId, params.InteractionToken)
+}
+
+func pingHandler(ctx context.Context, params CommandParams) error {
+	start := time.Now() // REST latency is measured as the round trip of the deferred response
+	if err := SendInteractionResponse(ctx, InteractionResponse{Type: RespTypeDeferredChannelMessage, Data: Message{Flags: MsgFlagEphemeral}}, params.InteractionId, params.InteractionToken); err != nil {
+		return err
+	}
+	rest := time.Since(start)
+
+	gateway := "unknown"
+	if handle := gatewayHandle.Load(); handle != nil {
+		if latency := handle.Latency(); latency > 0 {
+			gateway = fmt.Sprintf("%dms", latency.Milliseconds())
+		}
+	}
+
+	return EditInteractionResponse(ctx, fmt.Sprintf("Pong! Gateway: %s, REST: %dms", gateway, rest.Milliseconds()), params.InteractionToken)
 }
 
-func macroSetHandler(params CommandParams) (err error) {
+func macroSetHandler(ctx context.Context, params CommandParams) (err error) {
 	macro := Macro{
 		Guild:    params.GuildId,
 		Key:      params.GetOption("keyword").AsString(),
@@ -99,10 +126,10 @@ func macroSetHandler(params CommandParams) (err error) {
 	}
 	slog.Info("Macro set:", slog.String("key", macro.Key), slog.String("response", macro.Response))
 
-	return SendInteractionMessageResponse(Message{Content: "Macro set!", Flags: MsgFlagEphemeral}, params.InteractionId, params.InteractionToken)
+	return SendInteractionMessageResponse(ctx, Message{Content: "Macro set!", Flags: MsgFlagEphemeral}, params.InteractionId, params.InteractionToken)
 }
 
-func macroDeleteHandler(params CommandParams) error {
+func macroDeleteHandler(ctx context.Context, params CommandParams) error {
 	key := params.GetOption("keyword").AsString()
 
 	var response string
@@ -115,10 +142,10 @@ func macroDeleteHandler(params CommandParams) error {
 		response = "No macro found for \"" + key + "\""
 	}
 
-	return SendInteractionMessageResponse(Message{Content: response, Flags: MsgFlagEphemeral}, params.InteractionId, params.InteractionToken)
+	return SendInteractionMessageResponse(ctx, Message{Content: response, Flags: MsgFlagEphemeral}, params.InteractionId, params.InteractionToken)
 }
 
-func macroUseHandler(params CommandParams) error {
+func macroUseHandler(ctx context.Context, params CommandParams) error {
 	key := params.GetOption("keyword").AsString()
 	macro, err := GetMacro(params.GuildId, key)
 	if err != nil {
@@ -132,5 +159,5 @@ func macroUseHandler(params CommandParams) error {
 		response = Message{Content: "No macro found for \"" + key + "\"", Flags: MsgFlagEphemeral}
 	}
 
-	return SendInteractionMessageResponse(response, params.InteractionId, params.InteractionToken)
+	return SendInteractionMessageResponse(ctx, response, params.InteractionId, params.InteractionToken)
 }
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	. "elaina-common"
+	"elaina-common/restapi"
 	"fmt"
 	"time"
 )
@@ -42,6 +44,7 @@ var banCommand = ApplicationCommand{
 			Description: "Reason for ban",
 			Type:        CmdOptString,
 			Required:    false,
+			MaxLength:   restapi.MaxAuditLogReason,
 		},
 		{
 			Name:        "delete_messages",
@@ -68,6 +71,13 @@ var unbanCommand = ApplicationCommand{
 			Type:        CmdOptUser,
 			Required:    true,
 		},
+		{
+			Name:        "reason",
+			Description: "Reason for unban",
+			Type:        CmdOptString,
+			Required:    false,
+			MaxLength:   restapi.MaxAuditLogReason,
+		},
 	},
 }
 
@@ -99,11 +109,12 @@ var timeoutCommand = ApplicationCommand{
 			Type:        CmdOptString,
 			Required:    false,
 			MinLength:   1,
+			MaxLength:   restapi.MaxAuditLogReason,
 		},
 	},
 }
 
-func honeypotHandler(params CommandParams) error {
+func honeypotHandler(ctx context.Context, params CommandParams) error {
 	channel := params.GetOption("channel").AsSnowflake()
 
 	settings, err := GetGuildSettings(params.GuildId)
@@ -115,13 +126,13 @@ func honeypotHandler(params CommandParams) error {
 		return err
 	}
 
-	return SendInteractionMessageResponse(Message{
+	return SendInteractionMessageResponse(ctx, Message{
 		Content: fmt.Sprintf("Honey pot channel set to: <#%s>", channel.String()),
 		Flags:   MsgFlagEphemeral,
 	}, params.InteractionId, params.InteractionToken)
 }
 
-func banHandler(params CommandParams) error {
+func banHandler(ctx context.Context, params CommandParams) error {
 	userId := params.GetOption("user").AsSnowflake()
 	reason := "No reason specified"
 	del := 0
@@ -134,28 +145,34 @@ func banHandler(params CommandParams) error {
 	}
 
 	// Deferred response because there's a bunch of API calls during the ban flow
-	if err := SendInteractionResponse(InteractionResponse{Type: RespTypeDeferredChannelMessage}, params.InteractionId, params.InteractionToken); err != nil {
+	if err := SendInteractionResponse(ctx, InteractionResponse{Type: RespTypeDeferredChannelMessage}, params.InteractionId, params.InteractionToken); err != nil {
 		return err
 	}
 
 	user := params.Resolved.Users[userId]
-	if err := banUser(params.GuildId, user, reason, del); err != nil {
+	if err := banUser(ctx, params.GuildId, user, reason, del); err != nil {
 		return err
 	}
 
-	return EditInteractionResponse(user.Username+" was banned.", params.InteractionToken)
+	return EditInteractionResponse(ctx, user.Username+" was banned.", params.InteractionToken)
 }
 
-func unbanHandler(params CommandParams) error {
+func unbanHandler(ctx context.Context, params CommandParams) error {
 	userId := params.GetOption("user").AsSnowflake()
-	if err := unbanUser(params.GuildId, userId); err != nil {
+	reason := "No reason specified"
+
+	if opt := params.GetOption("reason"); opt != nil {
+		reason = opt.AsString()
+	}
+
+	if err := unbanUser(ctx, params.GuildId, userId, reason); err != nil {
 		return err
 	}
 
-	return SendInteractionMessageResponse(Message{Content: userId.String() + " was unbanned."}, params.InteractionId, params.InteractionToken)
+	return SendInteractionMessageResponse(ctx, Message{Content: userId.String() + " was unbanned."}, params.InteractionId, params.InteractionToken)
 }
 
-func timeoutHandler(params CommandParams) error {
+func timeoutHandler(ctx context.Context, params CommandParams) error {
 	userId := params.GetOption("user").AsSnowflake()
 	reason := "No reason specified"
 	duration := time.Second * time.Duration(params.GetOption("duration").AsInt64())
@@ -165,9 +182,9 @@ func timeoutHandler(params CommandParams) error {
 	}
 
 	user := params.Resolved.Users[userId]
-	if err := timeoutUser(params.GuildId, user, duration, reason); err != nil {
+	if err := timeoutUser(ctx, params.GuildId, user, duration, reason); err != nil {
 		return err
 	}
 
-	return SendInteractionMessageResponse(Message{Content: user.Username + " was timed out."}, params.InteractionId, params.InteractionToken)
+	return SendInteractionMessageResponse(ctx, Message{Content: user.Username + " was timed out."}, params.InteractionId, params.InteractionToken)
 }
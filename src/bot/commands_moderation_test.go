@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	. "elaina-common"
+	"elaina-common/discordtest"
+	"elaina-common/restapi"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startFakeDiscord sends every REST request to a new fake discord
+func startFakeDiscord(t *testing.T) *discordtest.Server {
+	server := discordtest.NewServer()
+	SetApiUrl(server.URL)
+	CommonSecrets.Id = "99"
+	t.Cleanup(func() {
+		SetApiUrl("")
+		CommonSecrets.Id = ""
+		server.Close()
+	})
+	return server
+}
+
+// newCommandParams returns the params of a command invoked in guild just now with options as sent by discord
+func newCommandParams(t *testing.T, guild Snowflake, options string, users ...User) CommandParams {
+	var data []CommandOptionData
+	require.NoError(t, json.Unmarshal([]byte(options), &data))
+
+	resolved := &ResolvedData{Users: make(map[Snowflake]User)}
+	for _, user := range users {
+		resolved.Users[user.Id] = user
+	}
+	return CommandParams{GuildId: guild, InteractionId: TimeToSnowflake(time.Now()), InteractionToken: "token", Options: &data, Resolved: resolved}
+}
+
+// Tests that the ban command notifies the user before banning them
+func TestBanHandler(t *testing.T) {
+	server := startFakeDiscord(t)
+	user := User{Id: 31, Username: "saya"}
+	server.AddGuild(Guild{Id: 30})
+	server.AddMember(30, GuildMember{User: &user})
+
+	// TEST CASE: The user is sent a DM with the reason, then banned, then the interaction response is edited
+	params := newCommandParams(t, 30, `[{"name":"user","type":6,"value":"31"},{"name":"reason","type":3,"value":"Stealing bread"}]`, user)
+	require.NoError(t, banHandler(t.Context(), params))
+
+	assert.Equal(t, []string{
+		"POST /interactions/{interaction}/{token}/callback",
+		"POST /users/@me/channels",
+		"POST /channels/{channel}/messages",
+		"PUT /guilds/{guild}/bans/{user}",
+		"PATCH /webhooks/{application}/{token}/messages/@original",
+	}, server.Patterns())
+	dms := server.DMs(31)
+	require.Len(t, dms, 1)
+	assert.Contains(t, dms[0].Content, "Stealing bread")
+	require.NotNil(t, server.Ban(30, 31))
+	assert.Equal(t, "Stealing bread", server.Ban(30, 31).Reason)
+	assert.Nil(t, server.Member(30, 31))
+
+	// TEST CASE: The user is still banned if they can't be sent a DM
+	other := User{Id: 32, Username: "fran"}
+	server.AddMember(30, GuildMember{User: &other})
+	server.FailNext("POST /channels/{channel}/messages", discordtest.Error{Status: http.StatusForbidden, Code: discordtest.CodeCannotMessageUser, Message: "Cannot send messages to this user"})
+
+	params = newCommandParams(t, 30, `[{"name":"user","type":6,"value":"32"}]`, other)
+	require.NoError(t, banHandler(t.Context(), params))
+	assert.Empty(t, server.DMs(32))
+	assert.NotNil(t, server.Ban(30, 32))
+}
+
+// Tests that the reasons given to the unban and timeout commands reach the audit log
+func TestModerationAuditLog(t *testing.T) {
+	server := startFakeDiscord(t)
+	user := User{Id: 36, Username: "saya"}
+	server.AddGuild(Guild{Id: 35})
+	server.AddMember(35, GuildMember{User: &user})
+
+	// TEST CASE: The timeout reason is sent with the member update
+	params := newCommandParams(t, 35, `[{"name":"user","type":6,"value":"36"},{"name":"duration","type":4,"value":60},{"name":"reason","type":3,"value":"Cool off"}]`, user)
+	require.NoError(t, timeoutHandler(t.Context(), params))
+	assert.NotEmpty(t, server.Member(35, 36).CommunicationDisabledUntil)
+	assert.Eventually(t, func() bool { return len(server.DMs(36)) == 1 }, time.Second, time.Millisecond*10) // Sent in the background
+
+	// TEST CASE: The unban reason is sent, or a default if none was given
+	params = newCommandParams(t, 35, `[{"name":"user","type":6,"value":"36"}]`, user)
+	require.NoError(t, banHandler(t.Context(), params))
+	params = newCommandParams(t, 35, `[{"name":"user","type":6,"value":"36"},{"name":"reason","type":3,"value":"Appealed"}]`, user)
+	require.NoError(t, unbanHandler(t.Context(), params))
+
+	var reasons []string
+	for _, entry := range server.AuditLog(35) {
+		reasons = append(reasons, entry.Reason)
+	}
+	assert.Equal(t, []string{"Cool off", "No reason specified", "Appealed"}, reasons)
+}
+
+// Tests that the initial interaction response gives up once discord would no longer accept it, counting the window from
+// when the interaction was received
+func TestInteractionResponseWindow(t *testing.T) {
+	server := startFakeDiscord(t)
+	response := InteractionResponse{Type: RespTypeDeferredChannelMessage}
+	receivedAt := func(received time.Time) context.Context {
+		return withEventInfo(t.Context(), eventInfo{Name: "INTERACTION_CREATE", Logger: slog.Default(), Received: received})
+	}
+
+	// TEST CASE: A response within the window is sent
+	require.NoError(t, SendInteractionResponse(receivedAt(time.Now()), response, TimeToSnowflake(time.Now()), "token"))
+	assert.Len(t, server.InteractionResponses(), 1)
+
+	// TEST CASE: An interaction whose snowflake is from a clock behind the host's is still answered within the window
+	skewed := TimeToSnowflake(time.Now().Add(-interactionResponseWindow * 2))
+	require.NoError(t, SendInteractionResponse(receivedAt(time.Now()), response, skewed, "token"))
+	assert.Len(t, server.InteractionResponses(), 2)
+
+	// TEST CASE: A response after the window isn't sent at all
+	err := SendInteractionResponse(receivedAt(time.Now().Add(-interactionResponseWindow)), response, TimeToSnowflake(time.Now()), "token")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Len(t, server.InteractionResponses(), 2)
+
+	// TEST CASE: Outside an event there is no receive time, so the response is only limited by ctx
+	require.NoError(t, SendInteractionResponse(t.Context(), response, skewed, "token"))
+	assert.Len(t, server.InteractionResponses(), 3)
+}
+
+// Tests that errors discord responds to interaction responses with are returned
+func TestInteractionResponseErrors(t *testing.T) {
+	server := startFakeDiscord(t)
+	response := InteractionResponse{Type: RespTypeDeferredChannelMessage}
+
+	// TEST CASE: Unknown interactions are returned as ErrUnknownInteraction
+	server.FailNext("POST /interactions/{interaction}/{token}/callback", discordtest.Error{Status: http.StatusNotFound, Code: discordtest.CodeUnknownInteraction, Message: "Unknown interaction"})
+	err := SendInteractionResponse(t.Context(), response, TimeToSnowflake(time.Now()), "token")
+	assert.ErrorIs(t, err, restapi.ErrUnknownInteraction)
+
+	// TEST CASE: Failed edits are returned
+	server.FailNext("PATCH /webhooks/{application}/{token}/messages/@original", discordtest.Error{Status: http.StatusBadRequest, Message: "Invalid Form Body"})
+	assert.ErrorContains(t, EditInteractionResponse(t.Context(), "edited", "token"), "Invalid Form Body")
+	assert.NoError(t, EditInteractionResponse(t.Context(), "edited", "token"))
+}
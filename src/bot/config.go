@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"time"
 )
 
 const configPath = "data/config.json"
@@ -14,12 +15,20 @@ const configPath = "data/config.json"
 const (
 	HelloEmoji        = "hello_emoji"
 	DefaultHelloEmoji = "default_hello_emoji"
+	StatusRotation    = "status_rotation"  // Semicolon separated list of "type:text" statuses, {guilds} is replaced by the guild count
+	StatusInterval    = "status_interval"  // Seconds between status changes
+	HandlerTimeout    = "handler_timeout"  // Seconds each event handler may run before its context is cancelled, 0 for no limit
+	ShutdownTimeout   = "shutdown_timeout" // Seconds to wait for running event handlers when shutting down, 0 for no limit
 )
 
 func defaultConfigValues() map[string]string {
 	return map[string]string{
 		HelloEmoji:        "elainastare:1462289034188689468",
 		DefaultHelloEmoji: "elainastare:1462289034188689468",
+		StatusRotation:    "watching:{guilds} guilds;custom:Travelling the world",
+		StatusInterval:    "300",
+		HandlerTimeout:    "30",
+		ShutdownTimeout:   "10",
 	}
 }
 
@@ -62,6 +71,17 @@ func getConfigSnowflake(key string) *Snowflake {
 	return &s
 }
 
+// getConfigSeconds returns a config value holding a number of seconds as a duration. Invalid values are logged and
+// treated as 0.
+func getConfigSeconds(key string) time.Duration {
+	seconds, err := strconv.Atoi(getConfig(key))
+	if err != nil {
+		slog.Error("[Elaina] Failed to load config value as seconds: \"" + key + "\"")
+		return 0
+	}
+	return time.Second * time.Duration(seconds)
+}
+
 func initializeConfig() (err error) {
 	slog.Info("[Elaina] Loading config...")
 	config.values = defaultConfigValues()
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	. "elaina-common"
 	"elaina-common/restapi"
 	"errors"
+	"fmt"
 	"log/slog"
 	"regexp"
 	"time"
@@ -11,70 +13,70 @@ import (
 
 var elainaRegex = regexp.MustCompile("(?i)common")
 
+// honeypotReason is shown in the audit log for the timeout and unban of users caught by the honeypot
+const honeypotReason = "Typed in the honeypot channel"
+
 func registerEvents() {
-	Events.CreateMessage.Register(logMessagesEvent, respondToNameEvent, banHoneypotEvent)
+	Events.Use(reportErrors, recoverPanics, timeHandlers)
+
+	Events.CreateMessage.Use(ignoreBots)
+	Events.CreateMessage.Register(logMessagesEvent, WithIntents(IntentMessageContent))
+	Events.CreateMessage.Register(respondToNameEvent, WithIntents(IntentMessageContent))
+	Events.CreateMessage.Register(withMiddleware(banHoneypotEvent, guildOnly, featureEnabled(honeypotEnabled)))
 }
 
-func logMessagesEvent(payload CreateMessagePayload) error {
-	if payload.Author.Bot {
-		return nil
-	}
-	slog.Info("[Elaina] Message received:", slog.String("author", payload.Author.Username), slog.String("content", payload.Content))
-	return nil
+func honeypotEnabled(settings GuildSettings) bool {
+	return settings.HoneypotChannel != nil
 }
 
-func respondToNameEvent(payload CreateMessagePayload) error {
-	if payload.Author.Bot {
-		return nil
-	}
+func logMessagesEvent(ctx context.Context, payload CreateMessagePayload) error {
+	eventLogger(ctx).Info("[Elaina] Message received:", slog.String("author", payload.Author.Username), slog.String("content", payload.Content))
+	return nil
+}
 
+func respondToNameEvent(ctx context.Context, payload CreateMessagePayload) error {
 	if elainaRegex.MatchString(payload.Content) {
-		if err := restapi.CreateReaction(payload.ChannelId, payload.Id, getConfig(HelloEmoji)); err != nil {
-			slog.Error("[Elaina] Could not say hello to " + payload.Author.Username + ": " + err.Error())
+		if err := restapi.CreateReaction(ctx, payload.ChannelId, payload.Id, getConfig(HelloEmoji)); err != nil {
+			eventLogger(ctx).Error("[Elaina] Could not say hello to " + payload.Author.Username + ": " + err.Error())
 		} else {
-			slog.Info("[Elaina] Saying hello to " + payload.Author.Username)
+			eventLogger(ctx).Info("[Elaina] Saying hello to " + payload.Author.Username)
 		}
 	}
 	return nil
 }
 
-func banHoneypotEvent(payload CreateMessagePayload) error {
-	if payload.Author.Bot || payload.GuildId == 0 {
-		return nil
+func banHoneypotEvent(ctx context.Context, payload CreateMessagePayload) error {
+	settings, ok := loadedGuildSettings(ctx)
+	if !ok {
+		return errors.New("guild settings weren't loaded by featureEnabled")
 	}
-
-	settings, err := GetGuildSettings(payload.GuildId)
-	if err != nil {
-		return err
-	}
-
-	if settings.HoneypotChannel == nil || payload.ChannelId != *settings.HoneypotChannel {
+	if payload.ChannelId != *settings.HoneypotChannel { // Enabled, checked by featureEnabled
 		return nil
 	}
 
-	guild, err := restapi.GetGuild(payload.GuildId)
+	guild, err := restapi.GetGuild(ctx, payload.GuildId)
 	if err != nil {
 		return err
 	}
 
-	channel, err := restapi.GetChannel(payload.ChannelId)
+	channel, err := restapi.GetChannel(ctx, payload.ChannelId)
 	if err != nil {
 		return err
 	}
 
-	perms, err := getMemberPermsInChannel(*guild, *payload.Member, payload.Author.Id, *channel)
+	perms, err := getMemberPermsInChannel(ctx, *guild, *payload.Member, payload.Author.Id, *channel)
 	if err != nil || perms&PermAdministrator > 0 || perms&PermModerateMembers > 0 {
 		return err
 	}
 
-	if err := restapi.ModifyGuildMember(payload.GuildId, payload.Author.Id, ModifyGuildMemberPayload{CommunicationDisabledUntil: &Nullable[time.Time]{Value: time.Now().Add(time.Minute * 15)}}); err != nil {
-		return errors.New("failed to timeout guild member: " + err.Error())
+	if err := restapi.ModifyGuildMember(ctx, payload.GuildId, payload.Author.Id, ModifyGuildMemberPayload{CommunicationDisabledUntil: &Nullable[time.Time]{Value: time.Now().Add(time.Minute * 15)}}, restapi.WithReason(honeypotReason)); err != nil {
+		return fmt.Errorf("failed to timeout guild member: %w", err)
 	}
-	if err := banUser(payload.GuildId, payload.Author, "You typed in the honeypot channel. You can rejoin immediately, but you are timed out for 15 minutes.", 900); err != nil {
-		return errors.New("failed to ban user: " + err.Error())
+	if err := banUser(ctx, payload.GuildId, payload.Author, "You typed in the honeypot channel. You can rejoin immediately, but you are timed out for 15 minutes.", 900); err != nil {
+		return fmt.Errorf("failed to ban user: %w", err)
 	}
-	if err := restapi.DeleteBan(payload.GuildId, payload.Author.Id); err != nil {
-		return errors.New("failed to unban ban: " + err.Error())
+	if err := restapi.DeleteBan(ctx, payload.GuildId, payload.Author.Id, restapi.WithReason(honeypotReason)); err != nil {
+		return fmt.Errorf("failed to unban ban: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	. "elaina-common"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// slowHandlerThreshold is how long a handler may run before timeHandlers logs it as slow
+const slowHandlerThreshold = time.Second
+
+// Handler is an event handler with the type of its payload erased, so middleware can be shared between events.
+type Handler = func(ctx context.Context, payload any) error
+
+// Middleware wraps a handler, running before and after it. Middleware can stop the event from reaching the handler by
+// returning without calling next.
+type Middleware = func(next Handler) Handler
+
+// withMiddleware wraps handler with the given middleware. The first middleware is the outermost, so it runs first.
+func withMiddleware[T any](handler EventHandler[T], middleware ...Middleware) EventHandler[T] {
+	if len(middleware) == 0 {
+		return handler
+	}
+
+	next := func(ctx context.Context, payload any) error {
+		return handler(ctx, payload.(T))
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		next = middleware[i](next)
+	}
+	return func(ctx context.Context, payload T) error {
+		return next(ctx, payload)
+	}
+}
+
+// --------------------------------------------------------------------
+// |                             FILTERS                              |
+// --------------------------------------------------------------------
+
+// ignoreBots stops events caused by bots, including Elaina herself, from reaching the handler. Events which don't
+// implement UserEvent always pass.
+func ignoreBots(next Handler) Handler {
+	return func(ctx context.Context, payload any) error {
+		if event, ok := payload.(UserEvent); ok {
+			if user := event.EventUser(); user != nil && user.Bot {
+				return nil
+			}
+		}
+		return next(ctx, payload)
+	}
+}
+
+// guildOnly stops events sent outside a guild from reaching the handler. Events which don't implement GuildEvent never
+// pass.
+func guildOnly(next Handler) Handler {
+	return func(ctx context.Context, payload any) error {
+		if event, ok := payload.(GuildEvent); !ok || event.EventGuild() == 0 {
+			return nil
+		}
+		return next(ctx, payload)
+	}
+}
+
+type guildSettingsKey struct{}
+
+// loadedGuildSettings returns the settings of the event's guild loaded by featureEnabled, so handlers behind it don't
+// need to load them again. Returns false if the handler isn't wrapped by featureEnabled.
+func loadedGuildSettings(ctx context.Context) (GuildSettings, bool) {
+	settings, ok := ctx.Value(guildSettingsKey{}).(GuildSettings)
+	return settings, ok
+}
+
+// featureEnabled returns middleware only letting events through if enabled returns true for the settings of the
+// event's guild. Events outside a guild never pass. The settings are passed on to the handler, see loadedGuildSettings.
+func featureEnabled(enabled func(settings GuildSettings) bool) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, payload any) error {
+			event, ok := payload.(GuildEvent)
+			if !ok || event.EventGuild() == 0 {
+				return nil
+			}
+
+			settings, err := GetGuildSettings(event.EventGuild())
+			if err != nil {
+				return fmt.Errorf("failed to load guild settings: %w", err)
+			}
+			if !enabled(settings) {
+				return nil
+			}
+			return next(context.WithValue(ctx, guildSettingsKey{}, settings), payload)
+		}
+	}
+}
+
+// --------------------------------------------------------------------
+// |                            REPORTING                             |
+// --------------------------------------------------------------------
+
+// recoverPanics turns a panic in the handler into an error including the stack trace, so a single bad event can't
+// crash the process.
+func recoverPanics(next Handler) Handler {
+	return func(ctx context.Context, payload any) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("handler panicked: %v\n%s", r, debug.Stack())
+			}
+		}()
+		return next(ctx, payload)
+	}
+}
+
+// timeHandlers logs handlers which take longer than slowHandlerThreshold to return.
+func timeHandlers(next Handler) Handler {
+	return func(ctx context.Context, payload any) error {
+		start := time.Now()
+		err := next(ctx, payload)
+		if elapsed := time.Since(start); elapsed > slowHandlerThreshold {
+			eventLogger(ctx).Warn("[Event] Slow event handler:", slog.Duration("duration", elapsed))
+		}
+		return err
+	}
+}
+
+// reportErrors logs errors returned by the handler with the event they happened in, then discards them.
+func reportErrors(next Handler) Handler {
+	return func(ctx context.Context, payload any) error {
+		if err := next(ctx, payload); err != nil {
+			eventLogger(ctx).Error("[Event] Event handler failed:", slog.String("error", err.Error()))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	. "elaina-common"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests that middleware runs outermost first and can stop events from reaching the handler
+func TestWithMiddleware(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, payload any) error {
+				order = append(order, name)
+				return next(ctx, payload)
+			}
+		}
+	}
+	handler := func(ctx context.Context, payload CreateMessagePayload) error {
+		order = append(order, "handler")
+		return nil
+	}
+
+	// TEST CASE: The first middleware is the outermost
+	require.NoError(t, withMiddleware(handler, trace("a"), trace("b"))(context.Background(), CreateMessagePayload{}))
+	assert.Equal(t, []string{"a", "b", "handler"}, order)
+
+	// TEST CASE: Filters stop events from reaching the handler
+	order = nil
+	filtered := withMiddleware(handler, ignoreBots, guildOnly)
+	require.NoError(t, filtered(context.Background(), CreateMessagePayload{Message: Message{Author: User{Bot: true}}, GuildId: 1}))
+	require.NoError(t, filtered(context.Background(), CreateMessagePayload{}))
+	assert.Empty(t, order)
+
+	require.NoError(t, filtered(context.Background(), CreateMessagePayload{GuildId: 1}))
+	assert.Equal(t, []string{"handler"}, order)
+}
+
+// Tests that payloads without a user pass ignoreBots, and payloads without a guild never pass guildOnly
+func TestFilterUnknownPayloads(t *testing.T) {
+	called := 0
+	handler := func(ctx context.Context, payload testPayload) error {
+		called++
+		return nil
+	}
+
+	// TEST CASE: ignoreBots can't tell who caused the event, so it passes
+	require.NoError(t, withMiddleware(handler, ignoreBots)(context.Background(), testPayload{}))
+	assert.Equal(t, 1, called)
+
+	// TEST CASE: guildOnly can't tell which guild the event belongs to, so it doesn't pass
+	require.NoError(t, withMiddleware(handler, guildOnly)(context.Background(), testPayload{}))
+	assert.Equal(t, 1, called)
+}
+
+// Tests that panics become errors, and that errors are reported instead of returned
+func TestReportingMiddleware(t *testing.T) {
+	panics := func(ctx context.Context, payload testPayload) error {
+		panic("bad event")
+	}
+	fails := func(ctx context.Context, payload testPayload) error {
+		return errors.New("failed")
+	}
+
+	// TEST CASE: Panics are recovered as errors
+	err := withMiddleware(panics, recoverPanics)(context.Background(), testPayload{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad event")
+
+	// TEST CASE: Reported errors aren't returned
+	assert.NoError(t, withMiddleware(fails, reportErrors, timeHandlers)(context.Background(), testPayload{}))
+	assert.NoError(t, withMiddleware(panics, reportErrors, recoverPanics)(context.Background(), testPayload{}))
+}
+
+// Tests that event middleware only wraps registered handlers, while global middleware wraps built-in handlers too
+func TestEventMiddleware(t *testing.T) {
+	var handled, builtin int
+	event := Event[CreateMessagePayload]{Name: "MESSAGE_CREATE", builtin: []EventHandler[CreateMessagePayload]{
+		func(ctx context.Context, payload CreateMessagePayload) error {
+			builtin++
+			panic("built-in")
+		},
+	}}
+	event.Use(ignoreBots)
+	event.Register(func(ctx context.Context, payload CreateMessagePayload) error {
+		handled++
+		return nil
+	})
+
+	// TEST CASE: Filters don't stop built-in handlers, and global middleware recovers their panics
+	event.dispatch(context.Background(), []byte(`{"author":{"id":"1","bot":true}}`), jsonEncoding{}, 0, []Middleware{recoverPanics})
+	assert.Equal(t, 0, handled)
+	assert.Equal(t, 1, builtin)
+
+	event.dispatch(context.Background(), []byte(`{"author":{"id":"1","bot":false}}`), jsonEncoding{}, 0, []Middleware{recoverPanics})
+	assert.Equal(t, 1, handled)
+	assert.Equal(t, 2, builtin)
+}
+
+// Tests that global middleware can be added while events are dispatched, without changing middleware already in use
+func TestGlobalMiddlewareUse(t *testing.T) {
+	dispatcher := newEventDispatcher()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			dispatcher.Use(recoverPanics)
+		}
+	}()
+
+	// TEST CASE: Middleware is only ever added, and a returned slice doesn't grow
+	previous := 0
+	for {
+		middleware := dispatcher.globalMiddleware()
+		assert.GreaterOrEqual(t, len(middleware), previous)
+		assert.Equal(t, len(middleware), cap(middleware))
+		previous = len(middleware)
+		select {
+		case <-done:
+			assert.Len(t, dispatcher.globalMiddleware(), 100)
+			return
+		default:
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// registeredHandler is a handler added to an Event by Register
+type registeredHandler[T any] struct {
+	id       uint64
+	priority int
+	intents  int
+	handler  EventHandler[T]
+}
+
+// HandlerOption changes how a handler is registered, see Event.Register
+type HandlerOption func(options *handlerOptions)
+
+type handlerOptions struct {
+	priority int
+	intents  int
+}
+
+// WithPriority sets the priority of a handler. Handlers with a higher priority run first, handlers with the same
+// priority run in the order they were registered. The default priority is 0.
+func WithPriority(priority int) HandlerOption {
+	return func(options *handlerOptions) {
+		options.priority = priority
+	}
+}
+
+// WithIntents adds intents the handler needs on top of the event's own, such as IntentMessageContent to read the
+// content of messages. See Event.requiredIntents
+func WithIntents(intents int) HandlerOption {
+	return func(options *handlerOptions) {
+		options.intents |= intents
+	}
+}
+
+// Registration is returned by Event.Register, and removes the handler again with Unregister.
+type Registration struct {
+	once       sync.Once
+	unregister func()
+}
+
+// Unregister removes the handler from its event. Calls to a handler which already started will still finish. Calling
+// Unregister more than once has no additional effect.
+func (r *Registration) Unregister() {
+	r.once.Do(r.unregister)
+}
+
+// Register an event handler to be run when an event of this type is received by the gateway. Multiple handlers can be
+// registered for a single type. The returned Registration can be used to remove the handler again. Handlers registered
+// after the gateway started without the intents they need never run, which is logged as a warning.
+func (event *Event[T]) Register(handler EventHandler[T], options ...HandlerOption) *Registration {
+	var opts handlerOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	if missing := event.missingIntents(opts.intents); missing != 0 {
+		slog.Warn("[Event] Handler registered without the intents it needs, it will not receive events until restarted", slog.String("event", event.Name), slog.Int("missing_intents", missing))
+	}
+
+	event.mutex.Lock()
+	defer event.mutex.Unlock()
+
+	event.nextId++
+	id := event.nextId
+	registered := registeredHandler[T]{id: id, priority: opts.priority, intents: opts.intents, handler: handler}
+
+	i := len(event.handlers) // Insert after every handler with the same or a higher priority
+	for i > 0 && event.handlers[i-1].priority < opts.priority {
+		i--
+	}
+	event.handlers = slices.Insert(event.handlers, i, registered)
+
+	return &Registration{unregister: func() {
+		event.mutex.Lock()
+		defer event.mutex.Unlock()
+		event.handlers = slices.DeleteFunc(event.handlers, func(h registeredHandler[T]) bool { return h.id == id })
+	}}
+}
+
+// requiredIntents returns the intents needed to receive this event and those added by its handlers' WithIntents, or 0
+// if no handlers are registered. Built-in handlers don't request intents, they only keep caches up to date with the
+// events the bot receives anyway.
+func (event *Event[T]) requiredIntents() int {
+	event.mutex.RLock()
+	defer event.mutex.RUnlock()
+
+	if len(event.handlers) == 0 {
+		return 0
+	}
+	intents := event.intents
+	for _, registered := range event.handlers {
+		intents |= registered.intents
+	}
+	return intents
+}
+
+// eventWaiter is a pending WaitFor call, see Event.notify
+type eventWaiter[T any] struct {
+	predicate func(payload T) bool
+	matched   chan T // Buffered, only the first match is kept
+}
+
+// matches returns whether payload matches the waiter's predicate. Predicates are called by the shard's reader, so a
+// panicking predicate is recovered and doesn't match.
+func (waiter *eventWaiter[T]) matches(payload T) (matched bool) {
+	if waiter.predicate == nil {
+		return true
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			slog.Error(fmt.Sprintf("[Event] WaitFor predicate panicked: %v", err))
+			matched = false
+		}
+	}()
+	return waiter.predicate(payload)
+}
+
+// WaitFor blocks until an event matching predicate is received and returns its payload, or returns ctx's error if ctx
+// is done first. A nil predicate matches any event. The payload is only seen by WaitFor if it is waiting when the event
+// is received.
+//
+// Events are passed to WaitFor by the shard's reader before they are queued, so a handler can wait for events which
+// will be queued behind it, such as a reaction in its own guild. predicate must therefore return quickly. Middleware
+// doesn't apply to WaitFor, predicate has to filter out unwanted events itself. Returns ErrMissingIntents right away if
+// the gateway didn't identify with the intents needed to receive the event.
+func (event *Event[T]) WaitFor(ctx context.Context, predicate func(payload T) bool) (T, error) {
+	if missing := event.missingIntents(0); missing != 0 {
+		var zero T
+		return zero, fmt.Errorf("%w: waiting for %s, missing intents %d", ErrMissingIntents, event.Name, missing)
+	}
+	waiter := &eventWaiter[T]{predicate: predicate, matched: make(chan T, 1)}
+	event.mutex.Lock()
+	event.waiters = append(event.waiters, waiter)
+	event.mutex.Unlock()
+
+	defer func() {
+		event.mutex.Lock()
+		defer event.mutex.Unlock()
+		event.waiters = slices.DeleteFunc(event.waiters, func(w *eventWaiter[T]) bool { return w == waiter })
+	}()
+
+	select {
+	case payload := <-waiter.matched:
+		return payload, nil
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// deliverWaiters decodes the given []byte with encoding and passes it to every WaitFor call it matches, see notify
+func (event *Event[T]) deliverWaiters(raw []byte, encoding gatewayEncoding) error {
+	return event.notify(func(data *T) error {
+		return encoding.unmarshal(raw, data)
+	})
+}
+
+// notify decodes the payload with decode and passes it to every WaitFor call it matches. decode is only called if
+// something is waiting for the event.
+func (event *Event[T]) notify(decode func(data *T) error) error {
+	event.mutex.RLock()
+	waiters := slices.Clone(event.waiters) // Copied so waiters can return while being notified
+	event.mutex.RUnlock()
+
+	if len(waiters) == 0 {
+		return nil
+	}
+	var data T
+	if err := decode(&data); err != nil {
+		return err
+	}
+	for _, waiter := range waiters {
+		if waiter.matches(data) {
+			select {
+			case waiter.matched <- data:
+			default:
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"elaina-bot/gatewaytest"
+	. "elaina-common"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests that handlers run by priority, then registration order, and stop running once unregistered
+func TestRegisterPriority(t *testing.T) {
+	event := Event[testPayload]{Name: "TEST_EVENT"}
+	var order []string
+	handler := func(name string) EventHandler[testPayload] {
+		return func(ctx context.Context, payload testPayload) error {
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	a := event.Register(handler("a"))
+	event.Register(handler("b"), WithPriority(10))
+	event.Register(handler("c"))
+	event.Register(handler("d"), WithPriority(10))
+	event.Register(handler("e"), WithPriority(-1))
+
+	// TEST CASE: Higher priorities run first, equal priorities run in registration order
+	event.dispatch(context.Background(), []byte(`{}`), jsonEncoding{}, 0, nil)
+	assert.Equal(t, []string{"b", "d", "a", "c", "e"}, order)
+
+	// TEST CASE: Unregistered handlers no longer run, and unregistering twice has no effect
+	a.Unregister()
+	a.Unregister()
+	order = nil
+	event.dispatch(context.Background(), []byte(`{}`), jsonEncoding{}, 0, nil)
+	assert.Equal(t, []string{"b", "d", "c", "e"}, order)
+}
+
+// Tests that a handler can unregister itself while it is being called
+func TestUnregisterDuringDispatch(t *testing.T) {
+	event := Event[testPayload]{Name: "TEST_EVENT"}
+	calls := 0
+	var registration *Registration
+	registration = event.Register(func(ctx context.Context, payload testPayload) error {
+		calls++
+		registration.Unregister()
+		return nil
+	})
+
+	event.dispatch(context.Background(), []byte(`{}`), jsonEncoding{}, 0, nil)
+	event.dispatch(context.Background(), []byte(`{}`), jsonEncoding{}, 0, nil)
+	assert.Equal(t, 1, calls)
+}
+
+// Tests that WaitFor returns the first matching payload, and gives up once its context is done
+func TestWaitFor(t *testing.T) {
+	event := Event[testPayload]{Name: "TEST_EVENT"}
+
+	// TEST CASE: Only payloads matching the predicate are returned
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			if i%2 == 0 {
+				assert.NoError(t, event.deliverWaiters([]byte(`{"value":"ignored"}`), jsonEncoding{}))
+			} else {
+				assert.NoError(t, event.deliverWaiters([]byte(`{"value":"confirm"}`), jsonEncoding{}))
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	payload, err := event.WaitFor(ctx, func(payload testPayload) bool { return payload.Value == "confirm" })
+	require.NoError(t, err)
+	assert.Equal(t, "confirm", payload.Value)
+
+	// TEST CASE: WaitFor returns the context's error if no event matches in time
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	_, err = event.WaitFor(ctx, func(payload testPayload) bool { return false })
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// TEST CASE: Panicking predicates don't match
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	_, err = event.WaitFor(ctx, func(payload testPayload) bool { panic("predicate failed") })
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// TEST CASE: WaitFor removes itself once it returns
+	event.mutex.RLock()
+	defer event.mutex.RUnlock()
+	assert.Empty(t, event.waiters)
+	assert.Empty(t, event.handlers)
+}
+
+// Tests that a handler can wait for an event of its own guild, which is queued on the worker the handler occupies
+func TestWaitForFromHandler(t *testing.T) {
+	server := gatewaytest.NewServer()
+	defer server.Close()
+	handle, err := listenGateway(gatewayOptions{intents: baseIntents | IntentGuildMessageReactions, encoding: jsonEncoding{}, url: server.URL})
+	require.NoError(t, err)
+	defer handle.Close()
+	conn, err := server.Accept()
+	require.NoError(t, err)
+
+	reactions := make(chan ReactionAddPayload, 1)
+	errs := make(chan error, 1)
+	registration := Events.CreateMessage.Register(func(ctx context.Context, payload CreateMessagePayload) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		reaction, err := Events.ReactionAdd.WaitFor(ctx, func(reaction ReactionAddPayload) bool {
+			return reaction.MessageId == payload.Id
+		})
+		errs <- err
+		reactions <- reaction
+		return nil
+	})
+	defer registration.Unregister()
+
+	_, _, err = conn.Handshake(time.Second * 45)
+	require.NoError(t, err)
+	require.Eventually(t, handle.manager.shards[0].ready.Load, time.Second, time.Millisecond*5)
+
+	_, err = conn.Dispatch("MESSAGE_CREATE", map[string]any{"id": "10", "channel_id": "20", "guild_id": "30"})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		Events.ReactionAdd.mutex.RLock()
+		defer Events.ReactionAdd.mutex.RUnlock()
+		return len(Events.ReactionAdd.waiters) > 0
+	}, time.Second, time.Millisecond*5)
+	_, err = conn.Dispatch("MESSAGE_REACTION_ADD", map[string]any{"user_id": "40", "message_id": "11", "channel_id": "20", "guild_id": "30"})
+	require.NoError(t, err)
+	_, err = conn.Dispatch("MESSAGE_REACTION_ADD", map[string]any{"user_id": "40", "message_id": "10", "channel_id": "20", "guild_id": "30"})
+	require.NoError(t, err)
+
+	// TEST CASE: The reaction reaches the handler waiting for it on the guild's worker
+	require.NoError(t, <-errs)
+	reaction := <-reactions
+	assert.Equal(t, Snowflake(10), reaction.MessageId)
+	assert.Equal(t, Snowflake(40), reaction.UserId)
+}
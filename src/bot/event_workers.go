@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	. "elaina-common"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults for gatewayOptions.workers and gatewayOptions.queueSize
+const (
+	defaultEventWorkers   = 16
+	defaultEventQueueSize = 64
+)
+
+// eventJob is a single dispatch waiting to be handled by an eventWorkers worker
+type eventJob struct {
+	shard    *gateway
+	name     string
+	raw      []byte
+	received time.Time // When the shard read the event, see eventInfo
+	key      Snowflake // Events with the same key are handled in order, see eventRoutingKey
+}
+
+// eventWorkers handles dispatches on a fixed number of workers, each with its own queue. Events are assigned a worker by
+// their guild, or their channel if they don't belong to a guild, so events of a single guild are always handled in the
+// order they were received while different guilds are handled in parallel. Queues are bounded: once a worker's queue is
+// full, queueing blocks until the worker catches up. Shards queue from forwardEvents, so their readers keep reading
+// heartbeats meanwhile, and only stop reading once their backlog is full as well.
+type eventWorkers struct {
+	queues []chan eventJob
+	handle func(job eventJob) // Called by a worker for each job in its queue
+
+	handled atomic.Uint64 // Number of events which have been handled
+	blocked atomic.Uint64 // Number of times queueing an event had to wait for a full queue
+}
+
+// EventQueueStats is a snapshot of the event queues, see GatewayHandle.EventQueueStats
+type EventQueueStats struct {
+	Depths   []int  // Number of events waiting in each worker's queue
+	Capacity int    // Size of each worker's queue
+	Handled  uint64 // Events handled since the gateway started
+	Blocked  uint64 // Times a shard had to wait for a full queue before it could queue more events
+}
+
+func newEventWorkers(workers int, queueSize int, handle func(job eventJob)) *eventWorkers {
+	w := &eventWorkers{queues: make([]chan eventJob, max(workers, 1)), handle: handle}
+	for i := range w.queues {
+		w.queues[i] = make(chan eventJob, max(queueSize, 1))
+	}
+	return w
+}
+
+// start runs every worker until stop is called
+func (w *eventWorkers) start(handlers *sync.WaitGroup) {
+	for _, queue := range w.queues {
+		go func() {
+			for job := range queue {
+				w.handle(job)
+				w.handled.Add(1)
+				handlers.Done()
+			}
+		}()
+	}
+}
+
+// stop closes every queue and lets the workers exit once they are empty. Nothing may be queued after calling stop.
+func (w *eventWorkers) stop() {
+	for _, queue := range w.queues {
+		close(queue)
+	}
+}
+
+// queue adds the job to its worker's queue, blocking while the queue is full. Returns ctx's error if ctx is done first,
+// in which case the job is dropped.
+func (w *eventWorkers) queue(ctx context.Context, handlers *sync.WaitGroup, job eventJob) error {
+	queue := w.queues[workerIndex(job.key, len(w.queues))]
+	handlers.Add(1)
+
+	select {
+	case queue <- job:
+		return nil
+	default:
+	}
+
+	w.blocked.Add(1)
+	select {
+	case queue <- job:
+		return nil
+	case <-ctx.Done():
+		handlers.Done()
+		return ctx.Err()
+	}
+}
+
+func (w *eventWorkers) stats() EventQueueStats {
+	stats := EventQueueStats{Depths: make([]int, len(w.queues)), Handled: w.handled.Load(), Blocked: w.blocked.Load()}
+	for i, queue := range w.queues {
+		stats.Depths[i] = len(queue)
+		stats.Capacity = cap(queue)
+	}
+	return stats
+}
+
+// eventRoutingKey returns the ID events are ordered by: the guild ID if the event belongs to a guild, otherwise the
+// channel ID. Events with neither, such as READY, return 0.
+func eventRoutingKey(name string, raw []byte, encoding gatewayEncoding) Snowflake {
+	var ids struct {
+		Id        Snowflake `json:"id"`
+		GuildId   Snowflake `json:"guild_id"`
+		ChannelId Snowflake `json:"channel_id"`
+	}
+	if err := encoding.unmarshal(raw, &ids); err != nil {
+		return 0 // The handler will report the invalid payload
+	}
+
+	switch {
+	case ids.GuildId != 0:
+		return ids.GuildId
+	case name == "GUILD_CREATE" || name == "GUILD_UPDATE" || name == "GUILD_DELETE": // Guild events use id for the guild
+		return ids.Id
+	default:
+		return ids.ChannelId
+	}
+}
+
+// workerIndex spreads keys evenly over n workers. Snowflakes are mostly timestamp, so they are mixed before taking the
+// remainder.
+func workerIndex(key Snowflake, n int) int {
+	mixed := uint64(key) * 0x9E3779B97F4A7C15 // Fibonacci hashing
+	return int((mixed >> 32) % uint64(n))
+}
+
+// dispatchJob handles a job by dispatching it on the shard which received it
+func dispatchJob(job eventJob) {
+	job.shard.dispatchEvent(job.name, job.raw, job.received)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	. "elaina-common"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests that events are routed by guild, then channel, and that guild events use their own ID
+func TestEventRoutingKey(t *testing.T) {
+	// TEST CASE: Events belonging to a guild use the guild
+	assert.Equal(t, Snowflake(1), eventRoutingKey("MESSAGE_CREATE", []byte(`{"id":"3","guild_id":"1","channel_id":"2"}`), jsonEncoding{}))
+	assert.Equal(t, Snowflake(1), eventRoutingKey("CHANNEL_CREATE", []byte(`{"id":"2","guild_id":"1","parent_id":null}`), jsonEncoding{}))
+
+	// TEST CASE: Guild events use the ID of the guild itself
+	assert.Equal(t, Snowflake(1), eventRoutingKey("GUILD_CREATE", []byte(`{"id":"1","name":"Guild"}`), jsonEncoding{}))
+
+	// TEST CASE: Events outside a guild use the channel
+	assert.Equal(t, Snowflake(2), eventRoutingKey("MESSAGE_CREATE", []byte(`{"id":"3","channel_id":"2"}`), jsonEncoding{}))
+
+	// TEST CASE: Events with neither use 0
+	assert.Equal(t, Snowflake(0), eventRoutingKey("READY", []byte(`{"session_id":"abc"}`), jsonEncoding{}))
+}
+
+// Tests that events of a single guild are handled in the order they were queued while guilds are spread over workers
+func TestEventWorkerOrdering(t *testing.T) {
+	var mu sync.Mutex
+	handled := make(map[string][]int)
+	workersUsed := make(map[int]bool)
+
+	var handlers sync.WaitGroup
+	workers := newEventWorkers(4, 8, func(job eventJob) {
+		var payload struct {
+			GuildId Snowflake `json:"guild_id"`
+			Index   int       `json:"index"`
+		}
+		require.NoError(t, json.Unmarshal(job.raw, &payload))
+		time.Sleep(time.Microsecond * 50) // Give other workers a chance to overtake if ordering was broken
+
+		mu.Lock()
+		defer mu.Unlock()
+		handled[payload.GuildId.String()] = append(handled[payload.GuildId.String()], payload.Index)
+		workersUsed[workerIndex(payload.GuildId, 4)] = true
+	})
+	workers.start(&handlers)
+
+	const guilds = 16
+	const events = 20
+	for i := range events {
+		for guild := 1; guild <= guilds; guild++ {
+			raw := []byte(`{"guild_id":"` + strconv.Itoa(guild<<22) + `","index":` + strconv.Itoa(i) + `}`)
+			job := eventJob{name: "MESSAGE_CREATE", raw: raw, key: eventRoutingKey("MESSAGE_CREATE", raw, jsonEncoding{})}
+			require.NoError(t, workers.queue(context.Background(), &handlers, job))
+		}
+	}
+	handlers.Wait()
+	workers.stop()
+
+	// TEST CASE: Every guild's events were handled in order
+	require.Len(t, handled, guilds)
+	for guild, indexes := range handled {
+		require.Len(t, indexes, events, "guild %s", guild)
+		for i, index := range indexes {
+			assert.Equal(t, i, index, "guild %s", guild)
+		}
+	}
+
+	// TEST CASE: Guilds are spread over more than one worker
+	assert.Greater(t, len(workersUsed), 1)
+	assert.EqualValues(t, guilds*events, workers.stats().Handled)
+}
+
+// Tests that queueing blocks once a worker's queue is full, and gives up once the context is done
+func TestEventWorkerBackpressure(t *testing.T) {
+	release := make(chan struct{})
+	var handlers sync.WaitGroup
+	workers := newEventWorkers(1, 1, func(job eventJob) { <-release })
+	workers.start(&handlers)
+	defer workers.stop()
+
+	job := eventJob{name: "TYPING_START", raw: []byte(`{"channel_id":"1"}`)}
+	require.NoError(t, workers.queue(context.Background(), &handlers, job)) // Taken by the worker, which blocks
+	require.Eventually(t, func() bool { return workers.stats().Depths[0] == 0 }, time.Second, time.Millisecond)
+	require.NoError(t, workers.queue(context.Background(), &handlers, job)) // Fills the queue
+
+	// TEST CASE: Queueing on a full queue blocks until the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	err := workers.queue(ctx, &handlers, job)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	stats := workers.stats()
+	assert.Equal(t, []int{1}, stats.Depths)
+	assert.Equal(t, 1, stats.Capacity)
+	assert.EqualValues(t, 1, stats.Blocked)
+
+	// TEST CASE: Dropped jobs aren't waited for
+	close(release)
+	handlers.Wait()
+	assert.EqualValues(t, 2, workers.stats().Handled)
+}
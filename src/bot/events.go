@@ -1,122 +1,313 @@
 package main
 
 import (
+	"context"
 	. "elaina-common"
+	"elaina-common/restapi"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"sync"
+	"time"
 )
 
-var Events = &EventDispatcher{
-	CreateMessage:     Event[CreateMessagePayload]{Name: "MESSAGE_CREATE"},
-	UpdateMessage:     Event[UpdateMessagePayload]{Name: "MESSAGE_UPDATE", builtin: []EventHandler[UpdateMessagePayload]{updateMessageEvent}},
-	DeleteMessage:     Event[DeleteMessagePayload]{Name: "MESSAGE_DELETE", builtin: []EventHandler[DeleteMessagePayload]{deleteMessageEvent}},
-	BulkDeleteMessage: Event[BulkDeleteMessagePayload]{Name: "MESSAGE_DELETE_BULK"},
-	ReactionAdd:       Event[ReactionAddPayload]{Name: "MESSAGE_REACTION_ADD"},
-	ReactionRemove:    Event[ReactionRemovePayload]{Name: "MESSAGE_REACTION_REMOVE"},
-	InteractionCreate: Event[InteractionCreatePayload]{Name: "INTERACTION_CREATE", builtin: []EventHandler[InteractionCreatePayload]{interactionCreateEvent}},
-	UpdateChannel:     Event[UpdateChannelPayload]{Name: "CHANNEL_UPDATE", builtin: []EventHandler[UpdateChannelPayload]{updateChannelEvent}},
-	DeleteChannel:     Event[DeleteChannelPayload]{Name: "CHANNEL_UPDATE", builtin: []EventHandler[DeleteChannelPayload]{deleteChannelEvent}},
-	UpdateRole:        Event[UpdateRolePayload]{Name: "GUILD_ROLE_UPDATE", builtin: []EventHandler[UpdateRolePayload]{updateRoleEvent}},
-	DeleteRole:        Event[DeleteRolePayload]{Name: "GUILD_ROLE_UPDATE", builtin: []EventHandler[DeleteRolePayload]{deleteRoleEvent}},
-	UpdateGuild:       Event[UpdateGuildPayload]{Name: "GUILD_UPDATE", builtin: []EventHandler[UpdateGuildPayload]{updateGuildEvent}},
-	DeleteGuild:       Event[DeleteGuildPayload]{Name: "GUILD_DELETE", builtin: []EventHandler[DeleteGuildPayload]{deleteGuildEvent}},
-}
+//go:generate go run ./internal/eventgen
+
+// Events dispatches every gateway event the bot handles. The EventDispatcher and its payloads are generated from the
+// catalogue in internal/eventgen/events.go.
+var Events = newEventDispatcher()
 
 // Event represents a deserialization and handler dispatcher for a type of Event. Built-in handlers will
 // always run LAST, as they may modify a LRUCache.
 type Event[T any] struct {
+	Name       string
+	handlers   []registeredHandler[T] // Sorted by priority, then registration order
+	waiters    []*eventWaiter[T]      // Pending WaitFor calls
+	builtin    []EventHandler[T]
+	intents    int          // Intents discord needs to send this event, see requiredIntents
+	middleware []Middleware // Only wraps handlers, built-in handlers always run
+	nextId     uint64
+	mutex      sync.RWMutex // Guards handlers, waiters and middleware, which can change while events are dispatched
+}
+
+// EventHandler is called when an event it is registered to fires. Events are called in order. ctx carries the
+// eventInfo of the event, and is cancelled once the handler timeout passes or the bot gives up waiting for it on shutdown.
+type EventHandler[T any] = func(ctx context.Context, payload T) error
+
+// eventInfo describes the event a handler is being called for, see getEventInfo
+type eventInfo struct {
+	Shard    int
 	Name     string
-	handlers []EventHandler[T]
-	builtin  []EventHandler[T]
-}
-
-// EventHandler is called when an event it is registered to fires. Events are called in order.
-type EventHandler[T any] = func(T) error
-
-// Register an event handler to be run when an event of this type is received by the gateway. Multiple handlers can be
-// registered for a single type.
-func (event *Event[T]) Register(handler ...EventHandler[T]) {
-	event.handlers = append(event.handlers, handler...)
-}
-
-type EventDispatcher struct {
-	CreateMessage     Event[CreateMessagePayload]
-	UpdateMessage     Event[UpdateMessagePayload]
-	DeleteMessage     Event[DeleteMessagePayload]
-	BulkDeleteMessage Event[BulkDeleteMessagePayload]
-	ReactionAdd       Event[ReactionAddPayload]
-	ReactionRemove    Event[ReactionRemovePayload]
-	InteractionCreate Event[InteractionCreatePayload]
-	UpdateChannel     Event[UpdateChannelPayload]
-	DeleteChannel     Event[DeleteChannelPayload]
-	UpdateRole        Event[UpdateRolePayload]
-	DeleteRole        Event[DeleteRolePayload]
-	UpdateGuild       Event[UpdateGuildPayload]
-	DeleteGuild       Event[DeleteGuildPayload]
-}
-
-// dispatch decodes the given json-encoded []byte and dispatches it as an event
-func (event *Event[T]) dispatch(raw []byte) {
-	if len(event.handlers) == 0 && len(event.builtin) == 0 {
+	Logger   *slog.Logger // Logs with the shard and event name attached
+	Received time.Time    // When the shard read the event from its connection
+}
+
+type eventInfoKey struct{}
+
+// withEventInfo returns a copy of ctx carrying info
+func withEventInfo(ctx context.Context, info eventInfo) context.Context {
+	return context.WithValue(ctx, eventInfoKey{}, info)
+}
+
+// getEventInfo returns the eventInfo carried by ctx. If ctx doesn't belong to an event, Logger is slog's default logger.
+func getEventInfo(ctx context.Context) eventInfo {
+	if info, ok := ctx.Value(eventInfoKey{}).(eventInfo); ok {
+		return info
+	}
+	return eventInfo{Logger: slog.Default()}
+}
+
+// eventLogger returns the logger of the event ctx belongs to, see getEventInfo
+func eventLogger(ctx context.Context) *slog.Logger {
+	return getEventInfo(ctx).Logger
+}
+
+// Use adds middleware wrapping every handler registered to this event, see Middleware. Built-in handlers are not
+// wrapped, so filters can't stop the bot's caches from being updated.
+func (event *Event[T]) Use(middleware ...Middleware) {
+	event.mutex.Lock()
+	event.middleware = append(event.middleware, middleware...)
+	event.mutex.Unlock()
+}
+
+// Use adds middleware wrapping every handler of every event, including built-in handlers. Filters should be added to
+// a single Event instead.
+func (dispatcher *EventDispatcher) Use(middleware ...Middleware) {
+	dispatcher.mutex.Lock()
+	dispatcher.middleware = append(dispatcher.middleware, middleware...)
+	dispatcher.mutex.Unlock()
+}
+
+// globalMiddleware returns the middleware added by EventDispatcher.Use
+func (dispatcher *EventDispatcher) globalMiddleware() []Middleware {
+	dispatcher.mutex.RLock()
+	defer dispatcher.mutex.RUnlock()
+	return dispatcher.middleware[:len(dispatcher.middleware):len(dispatcher.middleware)] // Use can't append into the returned slice
+}
+
+// RawEvent is the payload of Events.Raw and Events.Unknown, an event's name and its undecoded data. The shard and
+// sequence it was received with are in the handler's eventInfo.
+type RawEvent struct {
+	Name string
+	Data json.RawMessage
+}
+
+// rawDecoder returns a decode function for Event.call setting a RawEvent. raw is transcoded into JSON if it was received
+// with a different encoding.
+func rawDecoder(name string, raw []byte, encoding gatewayEncoding) func(data *RawEvent) error {
+	return func(data *RawEvent) error {
+		enc, err := encoding.toJson(raw)
+		if err != nil {
+			return err
+		}
+		*data = RawEvent{Name: name, Data: enc}
+		return nil
+	}
+}
+
+// eventDispatchFunc decodes and dispatches an event on one of the dispatcher's Events, see eventDispatchers
+type eventDispatchFunc func(dispatcher *EventDispatcher, ctx context.Context, raw []byte, encoding gatewayEncoding, timeout time.Duration)
+
+// eventWaitFunc decodes an event and passes it to the WaitFor calls of one of the dispatcher's Events, see eventWaiters
+type eventWaitFunc func(dispatcher *EventDispatcher, raw []byte, encoding gatewayEncoding) error
+
+// dispatch decodes the given []byte with encoding and dispatches it as an event. Each handler gets its own deadline if
+// timeout is above 0, and is wrapped by the global middleware before the event's own middleware.
+func (event *Event[T]) dispatch(ctx context.Context, raw []byte, encoding gatewayEncoding, timeout time.Duration, global []Middleware) {
+	event.call(ctx, timeout, global, func(data *T) error {
+		return encoding.unmarshal(raw, data)
+	})
+}
+
+// call decodes the payload with decode and calls every handler with it. decode is only called if the event has
+// handlers, see dispatch.
+func (event *Event[T]) call(ctx context.Context, timeout time.Duration, global []Middleware, decode func(data *T) error) {
+	event.mutex.RLock()
+	handlers := make([]EventHandler[T], len(event.handlers)) // Copied so handlers can unregister while being called
+	for i, registered := range event.handlers {
+		handlers[i] = withMiddleware(registered.handler, event.middleware...)
+	}
+	event.mutex.RUnlock()
+
+	if len(handlers) == 0 && len(event.builtin) == 0 {
 		return
 	}
+	logger := eventLogger(ctx)
+
 	var data T
-	if err := json.Unmarshal(raw, &data); err != nil {
-		slog.Error("[Event] Failed to parse gateway event: " + err.Error())
+	if err := decode(&data); err != nil {
+		logger.Error("[Event] Failed to parse gateway event: " + err.Error())
 		return
 	}
 
-	for _, handler := range event.handlers {
-		if err := handler(data); err != nil {
-			slog.Error("[Event] Failed to execute event handler: " + err.Error())
+	for _, handler := range handlers {
+		handler = withMiddleware(handler, global...)
+		if err := runHandler(ctx, timeout, handler, data); err != nil {
+			logger.Error("[Event] Failed to execute event handler: " + err.Error())
 		}
 	}
 	for _, handler := range event.builtin {
-		if err := handler(data); err != nil {
-			slog.Error("[Event] Failed to execute built-in event handler: " + err.Error())
+		handler = withMiddleware(handler, global...)
+		if err := runHandler(ctx, timeout, handler, data); err != nil {
+			logger.Error("[Event] Failed to execute built-in event handler: " + err.Error())
 		}
 	}
 }
 
-// Not really a fan of how this is implemented, but I couldn't figure out how to maintain type safety during event handler
-// registration without doing this.
-func (gateway *gateway) dispatchEvent(name string, raw []byte) {
+// runHandler calls handler with a context which expires after timeout, or never expires if timeout is 0
+func runHandler[T any](ctx context.Context, timeout time.Duration, handler EventHandler[T], data T) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return handler(ctx, data)
+}
+
+// queueEvent queues the dispatch on the manager's event workers, blocking while the worker's queue is full. See
+// eventWorkers.queue
+func (gateway *gateway) queueEvent(ctx context.Context, name string, raw []byte, received time.Time) error {
+	job := eventJob{shard: gateway, name: name, raw: raw, received: received, key: eventRoutingKey(name, raw, gateway.options.encoding)}
+	return gateway.manager.workers.queue(ctx, &gateway.manager.handlers, job)
+}
+
+// dispatchBacklogSize is how many intercepted dispatches the reader can hand to forwardEvents before it has to wait
+const dispatchBacklogSize = 256
+
+// pendingDispatch is a dispatch the reader has intercepted, waiting in the backlog to be queued by forwardEvents
+type pendingDispatch struct {
+	name     string
+	raw      []byte
+	sequence int32
+	received time.Time
+}
+
+// forwardEvents queues every dispatch in the backlog on the event workers until ctx is done. Waiting for a full worker
+// queue happens here instead of on the reader, so heartbeats and control opcodes keep being read meanwhile. The
+// reader only waits once the backlog is full as well. The sequence is stored once a dispatch is queued, so dispatches
+// still in the backlog when the connection closes are replayed if the session is resumed.
+func (gateway *gateway) forwardEvents(ctx context.Context, backlog <-chan pendingDispatch) {
+	defer gateway.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case dispatch := <-backlog:
+			if err := gateway.queueEvent(ctx, dispatch.name, dispatch.raw, dispatch.received); err != nil {
+				return // The connection is closing
+			}
+			gateway.sequence.Store(dispatch.sequence)
+		}
+	}
+}
+
+// interceptEvent is called by the shard's reader for every dispatch before it is queued. READY and RESUMED update the
+// session here, so the reader's own state is only written by the reader and State is reset before any GUILD_CREATE of
+// the session can be handled on another worker. Events answering a request which is being waited for, and events
+// passed to WaitFor, are delivered here rather than on the event workers, as the one waiting may be a handler
+// occupying the worker the event would be queued on. The event is still queued afterward, for Events.Raw.
+func (gateway *gateway) interceptEvent(name string, raw []byte) {
 	switch name {
 	case "READY": // Ready event has special handling, API users do not need it
 		var payload readyPayload
-		if err := json.Unmarshal(raw, &payload); err != nil {
-			panic(err) // Should never be hit
+		if err := gateway.options.encoding.unmarshal(raw, &payload); err != nil {
+			gateway.logger.Error("[Gateway] Failed to parse ready: "+err.Error(), slog.String("event", name))
+			return
 		}
 		gateway.resumeUrl = payload.ResumeGatewayUrl
 		gateway.sessionId = payload.SessionId
-		slog.Info("[Gateway] Gateway connection established")
-	case "RESUME":
-		slog.Info("[Gateway] Gateway connection resumed") // Payload doesn't need to be read here, only care for logging
-	case Events.CreateMessage.Name:
-		Events.CreateMessage.dispatch(raw)
-	case Events.UpdateMessage.Name:
-		Events.UpdateMessage.dispatch(raw)
-	case Events.DeleteMessage.Name:
-		Events.DeleteMessage.dispatch(raw)
-	case Events.BulkDeleteMessage.Name:
-		Events.BulkDeleteMessage.dispatch(raw)
-	case Events.ReactionAdd.Name:
-		Events.ReactionAdd.dispatch(raw)
-	case Events.ReactionRemove.Name:
-		Events.ReactionRemove.dispatch(raw)
-	case Events.InteractionCreate.Name:
-		Events.InteractionCreate.dispatch(raw)
-	case Events.UpdateChannel.Name:
-		Events.UpdateChannel.dispatch(raw)
-	case Events.DeleteChannel.Name:
-		Events.DeleteChannel.dispatch(raw)
-	case Events.UpdateRole.Name:
-		Events.UpdateRole.dispatch(raw)
-	case Events.DeleteRole.Name:
-		Events.DeleteRole.dispatch(raw)
-	case Events.UpdateGuild.Name:
-		Events.UpdateGuild.dispatch(raw)
-	case Events.DeleteGuild.Name:
-		Events.DeleteGuild.dispatch(raw)
+		gateway.restoredGuilds = nil // The restored session couldn't be resumed, discord sends GUILD_CREATE for every guild
+		gateway.guilds.Store(int32(len(payload.Guilds)))
+		unavailable := make([]Snowflake, len(payload.Guilds))
+		for i, guild := range payload.Guilds {
+			unavailable[i] = guild.Id
+		}
+		State.SetUnavailable(unavailable...)
+		gateway.ready.Store(true)
+		gateway.logger.Info("[Gateway] Gateway connection established")
+	case "RESUMED":
+		gateway.ready.Store(true)
+		gateway.logger.Info("[Gateway] Gateway connection resumed") // Payload doesn't need to be read here, only care for logging
+		if guilds := gateway.restoredGuilds; guilds != nil {
+			gateway.restoredGuilds = nil
+			gateway.manager.handlers.Add(1)
+			go gateway.fillGuilds(gateway.manager.handlerCtx, guilds)
+		}
+	case "GUILD_MEMBERS_CHUNK": // Chunks are only sent in response to a member request, so they go straight to it
+		var payload GuildMembersChunkPayload
+		if err := gateway.options.encoding.unmarshal(raw, &payload); err != nil {
+			gateway.logger.Error("[Gateway] Failed to parse guild members chunk: "+err.Error(), slog.String("event", name))
+			return
+		}
+		gateway.manager.memberRequests.deliver(payload)
+	}
+	gateway.deliverWaiters(name, raw)
+}
+
+// fillGuilds fetches the guilds of a restored session from discord and adds them to State. Guilds which were created or
+// removed by an event in the meantime are skipped, and guilds which can't be fetched stay unavailable, so lookups for
+// them keep falling back to REST.
+func (gateway *gateway) fillGuilds(ctx context.Context, guilds []Snowflake) {
+	defer gateway.manager.handlers.Done()
+
+	filled := 0
+	for _, id := range guilds {
+		if !State.IsUnavailable(id) {
+			continue
+		}
+		guild, err := restapi.FetchGuild(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return // The bot is shutting down
+			}
+			gateway.logger.Warn("[Gateway] Failed to fetch guild of restored session: "+err.Error(), slog.String("guild", id.String()))
+			continue
+		}
+		if State.IsUnavailable(id) {
+			State.AddGuild(*guild)
+			filled++
+		}
+	}
+	gateway.logger.Info("[Gateway] Fetched guilds of restored session:", slog.Int("guilds", filled))
+}
+
+// deliverWaiters passes the event to the WaitFor calls of Events.Raw, then to those of its Event, or Events.Unknown if
+// it isn't in the catalogue. See Event.notify
+func (gateway *gateway) deliverWaiters(name string, raw []byte) {
+	encoding := gateway.options.encoding
+	err := Events.Raw.notify(rawDecoder(name, raw, encoding))
+
+	switch deliver, ok := eventWaiters[name]; {
+	case ok:
+		err = errors.Join(err, deliver(Events, raw, encoding))
+	case name == "READY", name == "RESUMED", name == "GUILD_MEMBERS_CHUNK": // Handled by the gateway, never unknown
+	default:
+		err = errors.Join(err, Events.Unknown.notify(rawDecoder(name, raw, encoding)))
+	}
+	if err != nil {
+		gateway.logger.Error("[Event] Failed to parse gateway event: "+err.Error(), slog.String("event", name))
+	}
+}
+
+// dispatchEvent handles a gateway event. Every event is first passed to Events.Raw. READY, RESUMED and
+// GUILD_MEMBERS_CHUNK are handled by the gateway itself in interceptEvent, every other event is looked up in
+// eventDispatchers to keep handler registration type safe, or passed to Events.Unknown if it isn't in the catalogue.
+// received is when the shard read the event, see eventInfo.
+func (gateway *gateway) dispatchEvent(name string, raw []byte, received time.Time) {
+	logger := gateway.logger.With(slog.String("event", name))
+	encoding := gateway.options.encoding
+	ctx := withEventInfo(gateway.manager.handlerCtx, eventInfo{Shard: gateway.shardId, Name: name, Logger: logger, Received: received})
+	timeout := gateway.manager.options.handlerTimeout
+
+	Events.Raw.call(ctx, timeout, Events.globalMiddleware(), rawDecoder(name, raw, encoding))
+
+	switch name {
+	case "READY", "RESUMED", "GUILD_MEMBERS_CHUNK": // Already handled by interceptEvent
+	default:
+		if dispatch, ok := eventDispatchers[name]; ok {
+			dispatch(Events, ctx, raw, encoding, timeout)
+		} else {
+			Events.Unknown.call(ctx, timeout, Events.globalMiddleware(), rawDecoder(name, raw, encoding))
+		}
 	}
 }
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	. "elaina-common"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPayload struct {
+	Value string `json:"value"`
+}
+
+// Tests that handlers receive the event's info and are cancelled once their timeout passes
+func TestEventContext(t *testing.T) {
+	event := Event[testPayload]{Name: "TEST_EVENT"}
+	infos := make(chan eventInfo, 1)
+	errs := make(chan error, 1)
+	event.Register(func(ctx context.Context, payload testPayload) error {
+		infos <- getEventInfo(ctx)
+		<-ctx.Done()
+		errs <- ctx.Err()
+		return nil
+	})
+
+	manager := newTestShardManager(baseIntents)
+	shard := manager.shards[0]
+	ctx := withEventInfo(manager.handlerCtx, eventInfo{Shard: shard.shardId, Name: event.Name, Logger: shard.logger})
+
+	// TEST CASE: The handler's context carries the shard and event name, and expires after the timeout
+	start := time.Now()
+	event.dispatch(ctx, []byte(`{"value":"a"}`), jsonEncoding{}, time.Millisecond*20, nil)
+	info := <-infos
+	assert.Equal(t, "TEST_EVENT", info.Name)
+	assert.Equal(t, 0, info.Shard)
+	assert.NotNil(t, info.Logger)
+	assert.True(t, errors.Is(<-errs, context.DeadlineExceeded))
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*20)
+
+	// TEST CASE: Contexts which don't belong to an event still have a logger
+	assert.NotNil(t, eventLogger(context.Background()))
+}
+
+// Tests that shutdown waits for running handlers, and cancels the ones which outlive the shutdown timeout
+func TestWaitHandlers(t *testing.T) {
+	// TEST CASE: Handlers finishing within the timeout are waited for
+	manager := newTestShardManager(baseIntents)
+	manager.options.shutdownTimeout = time.Second
+	var finished atomic.Bool
+	manager.handlers.Add(1)
+	go func() {
+		defer manager.handlers.Done()
+		time.Sleep(time.Millisecond * 20)
+		finished.Store(true)
+	}()
+	manager.waitHandlers()
+	assert.True(t, finished.Load())
+
+	// TEST CASE: Handlers still running after the timeout are cancelled
+	manager = newTestShardManager(baseIntents)
+	manager.options.shutdownTimeout = time.Millisecond * 20
+	cancelled := make(chan struct{})
+	manager.handlers.Add(1)
+	go func() {
+		defer manager.handlers.Done()
+		<-manager.handlerCtx.Done()
+		close(cancelled)
+	}()
+
+	start := time.Now()
+	manager.waitHandlers()
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*20)
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		require.Fail(t, "handler context was not cancelled")
+	}
+}
+
+// Tests that the generated EventDispatcher dispatches every event to the Event with the same name
+func TestEventDispatchers(t *testing.T) {
+	dispatcher := newEventDispatcher()
+	fields := reflect.ValueOf(dispatcher).Elem()
+
+	// TEST CASE: Every event has a dispatch function, and event names are unique
+	names := make(map[string]bool)
+	for i := 0; i < fields.NumField(); i++ {
+		if field := fields.Type().Field(i); field.Name == "middleware" || field.Name == "mutex" || field.Name == "Raw" || field.Name == "Unknown" {
+			continue
+		}
+		name := fields.Field(i).FieldByName("Name").String()
+		assert.False(t, names[name], "duplicate event name %s", name)
+		assert.Contains(t, eventDispatchers, name)
+		names[name] = true
+	}
+	assert.Len(t, eventDispatchers, len(names))
+
+	// TEST CASE: Dispatching by name calls the handlers of the matching Event
+	payloads := make(chan DeleteThreadPayload, 1)
+	dispatcher.DeleteThread.Register(func(ctx context.Context, payload DeleteThreadPayload) error {
+		payloads <- payload
+		return nil
+	})
+	raw := []byte(`{"id":"1","guild_id":"2","parent_id":"3","type":11}`)
+	eventDispatchers["THREAD_DELETE"](dispatcher, context.Background(), raw, jsonEncoding{}, 0)
+	payload := <-payloads
+	assert.Equal(t, Snowflake(1), payload.Id)
+	assert.Equal(t, Snowflake(3), payload.ParentId)
+
+	// TEST CASE: Events received as ETF are decoded straight into the payload
+	etf, err := JsonToEtf(raw)
+	require.NoError(t, err)
+	eventDispatchers["THREAD_DELETE"](dispatcher, context.Background(), etf, etfEncoding{}, 0)
+	payload = <-payloads
+	assert.Equal(t, Snowflake(1), payload.Id)
+	assert.Equal(t, Snowflake(3), payload.ParentId)
+}
+
+// Tests that Events.Raw receives every event and Events.Unknown receives events which aren't in the catalogue
+func TestRawEvents(t *testing.T) {
+	raws := make(chan RawEvent, 2)
+	unknowns := make(chan RawEvent, 2)
+	raw := Events.Raw.Register(func(ctx context.Context, payload RawEvent) error {
+		raws <- payload
+		return nil
+	})
+	defer raw.Unregister()
+	unknown := Events.Unknown.Register(func(ctx context.Context, payload RawEvent) error {
+		unknowns <- payload
+		return nil
+	})
+	defer unknown.Unregister()
+
+	shard := newTestShardManager(baseIntents).shards[0]
+
+	// TEST CASE: A catalogued event is passed to Events.Raw, but not to Events.Unknown
+	shard.dispatchEvent("THREAD_MEMBER_UPDATE", []byte(`{"id":"1","guild_id":"2"}`), time.Now())
+	event := <-raws
+	assert.Equal(t, "THREAD_MEMBER_UPDATE", event.Name)
+	assert.JSONEq(t, `{"id":"1","guild_id":"2"}`, string(event.Data))
+	assert.Empty(t, unknowns)
+
+	// TEST CASE: An event missing from the catalogue is passed to both
+	shard.dispatchEvent("BRAND_NEW_EVENT", []byte(`{"value":"a"}`), time.Now())
+	assert.Equal(t, "BRAND_NEW_EVENT", (<-raws).Name)
+	event = <-unknowns
+	assert.Equal(t, "BRAND_NEW_EVENT", event.Name)
+	assert.JSONEq(t, `{"value":"a"}`, string(event.Data))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	. "elaina-common"
 	"encoding/json"
 	"errors"
@@ -8,7 +9,6 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
-	"os"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -18,27 +18,35 @@ import (
 
 const defaultQueueSize = 16
 
-// Websocket Close codes as specified by https://discord.com/developers/docs/topics/opcodes-and-status-codes#gateway-gateway-close-event-codes
-// Codes not needed for responses have not been included (e.g. encoding errors)
+// Delays between reconnect attempts of a shard, see backoff
 const (
-	closeUnknownError         = 4000
-	closeNotAuthenticated     = 4003
-	closeAuthenticationFailed = 4004
-	closeInvalidSequence      = 4007
-	closeRateLimited          = 4008
-	closeTimedOut             = 4009
+	reconnectBaseDelay = time.Second
+	reconnectMaxDelay  = time.Minute * 2
 )
 
 var ErrInvalidResumeUrl = errors.New("[Gateway] invalid resume url")
 
+// gateway represents a single shard's connection to the discord gateway. Each shard tracks its own session, so they can
+// be resumed independently of each other.
 type gateway struct {
-	conn      *websocket.Conn // Websocket connection for the client. Only supports writing from one thread, use sendQueue to write to it
-	sendQueue chan []byte     // Buffer for writing to conn, can still accept items even when disconnected
+	conn           *websocket.Conn      // Websocket connection for the client. Only supports writing from one thread, use sendQueue to write to it
+	connMu         sync.Mutex           // connMu guards replacing conn, as disconnect can be called from a different goroutine
+	sendQueue      chan outgoingPayload // Buffer for writing to conn, can still accept items even when disconnected
+	heartbeatQueue chan []byte          // Heartbeats skip the send queue, so they can't be delayed by other payloads
+	clock          clock
 
-	cardiacArrest atomic.Bool    // cardiacArrest will stop all gateway child goroutines (handleWriting, heartbeat, readUntilClosed)
-	wg            sync.WaitGroup // wg will allow disconnect to block until all the child processes are finished.
+	ctx           context.Context    // Cancelled when the shard is asked to disconnect. Each connection derives its own context from it
+	cancel        context.CancelFunc // cancel is called by disconnect
+	disconnecting atomic.Bool        // disconnecting is set when the shard was asked to close and should not reconnect
+	wg            sync.WaitGroup     // wg will allow connect to block until the connection's child goroutines (handleWriting, forwardEvents, heartbeat) are finished.
 
-	intents int // gateway intents the bot will be using https://discord.com/developers/docs/events/gateway#gateway-intents
+	manager    *shardManager
+	shardId    int
+	shardCount int
+	logger     *slog.Logger
+
+	options  gatewayOptions
+	inflater *zlibStream // Only set while connected if options.compress is true
 
 	connectUrl string
 	resuming   bool // If true, gateway.connect will attempt to resuming the previous session
@@ -46,19 +54,101 @@ type gateway struct {
 	sessionId  string       // ID of gateway session, only applicable if resuming
 	sequence   atomic.Int32 // The last sequence number the client received from the gateway
 
-	heartbeatAcknowledged bool // Update to false when the client sends a sendHeartbeat. If discord doesn't acknowledge before the next sendHeartbeat, reconnect.
+	ready          atomic.Bool  // True once READY or RESUMED has been received on the current connection
+	guilds         atomic.Int32 // Number of guilds the shard received in READY, or had when its restored session was saved
+	restoredGuilds []Snowflake  // Guilds of a restored session, fetched once the session is resumed. See fillGuilds
+
+	presence   *PresenceUpdate // Latest presence set on the shard, sent again when identifying
+	presenceMu sync.Mutex
+
+	heartbeatAcknowledged atomic.Bool  // Set to false when a heartbeat is queued. If discord doesn't acknowledge it before the next one, the connection is a zombie
+	heartbeatSentAt       atomic.Int64 // Unix nanoseconds the last heartbeat was written at
+	latency               atomic.Int64 // Nanoseconds between the last heartbeat being written and discord acknowledging it
+	zombied               atomic.Bool  // Set when the connection is closed because a heartbeat wasn't acknowledged
 }
 
-// GatewayHandle represents an active gateway connection with a disconnect and error channel. If a value is passed to
-// Disconnect, the gateway will close normally. Done signals that the connection has closed. If the gateway closed
-// normally, nil will be sent. Otherwise, the error causing the close will be sent.
+// GatewayHandle represents the active gateway connections of every shard with a disconnect and error channel. If a
+// value is passed to Disconnect, every shard will close normally. Done signals that the connections have closed. If the
+// gateway closed normally, nil will be sent. Otherwise, the error causing the close will be sent.
 type GatewayHandle struct {
 	Done       <-chan error
 	Disconnect chan<- interface{}
+
+	manager *shardManager
 }
 
+// Close requests every shard to disconnect. Calling Close more than once has no additional effect.
 func (h *GatewayHandle) Close() {
-	h.Disconnect <- true
+	select {
+	case h.Disconnect <- true:
+	default:
+	}
+}
+
+// gatewayOptions configures how every shard connects to the gateway. These are chosen at startup.
+type gatewayOptions struct {
+	intents  int             // gateway intents the bot will be using https://discord.com/developers/docs/events/gateway#gateway-intents
+	compress bool            // If true, the connection will use zlib-stream transport compression
+	encoding gatewayEncoding // Encoding used for payloads sent and received over the connection
+	presence *PresenceUpdate // Optional presence to identify with
+	sessions sessionStore    // Optional store used to resume sessions across restarts
+	url      string          // Optional gateway url to connect to instead of asking discord, see gatewayOptions.gatewayInfo
+	shards   int             // Number of shards to start when url is set
+	recorder *eventRecorder  // Optional, every dispatch is written to it before being handled
+
+	workers   int // Number of event workers, defaultEventWorkers if 0
+	queueSize int // Size of each event worker's queue, defaultEventQueueSize if 0
+
+	handlerTimeout  time.Duration // How long each event handler may run before its context is cancelled, 0 for no limit
+	shutdownTimeout time.Duration // How long to wait for running event handlers on shutdown, 0 for no limit
+}
+
+// gatewayInfo returns the url and shard count every shard connects with. If options.url is set, discord isn't asked,
+// which allows connecting to a local gateway such as the one in the gatewaytest package.
+func (options gatewayOptions) gatewayInfo(ctx context.Context) (*gatewayBotPayload, error) {
+	if options.url != "" {
+		return &gatewayBotPayload{Url: options.url, Shards: max(options.shards, 1)}, nil
+	}
+	return fetchGatewayBot(ctx)
+}
+
+// SetPresence updates the presence of every shard. See gateway.setPresence
+func (h *GatewayHandle) SetPresence(presence PresenceUpdate) {
+	for _, shard := range h.manager.shards {
+		shard.setPresence(presence)
+	}
+}
+
+// Latency returns the average heartbeat round-trip time of every shard, or 0 if no heartbeat has been acknowledged yet.
+func (h *GatewayHandle) Latency() time.Duration {
+	var total time.Duration
+	measured := 0
+	for _, shard := range h.manager.shards {
+		if latency := shard.latency.Load(); latency > 0 {
+			total += time.Duration(latency)
+			measured++
+		}
+	}
+	if measured == 0 {
+		return 0
+	}
+	return total / time.Duration(measured)
+}
+
+// EventQueueStats returns the current depth of every event worker's queue, along with how many events have been handled
+// and how often shards had to wait for a full queue.
+func (h *GatewayHandle) EventQueueStats() EventQueueStats {
+	return h.manager.workers.stats()
+}
+
+// GuildCount returns the number of guilds across every shard, as of when each shard last received READY or restored a
+// saved session.
+func (h *GatewayHandle) GuildCount() int {
+	count := 0
+	for _, shard := range h.manager.shards {
+		count += int(shard.guilds.Load())
+	}
+	return count
 }
 
 type connectionProperties struct {
@@ -67,39 +157,142 @@ type connectionProperties struct {
 	Device  string `json:"device"`
 }
 
-// listenGateway initializes a gateway connection and returns a GatewayHandle for controlling it.
-func listenGateway(intents int) *GatewayHandle {
+// listenGateway fetches the recommended shard count from discord, starts a connection for each shard and returns a
+// GatewayHandle for controlling all of them.
+func listenGateway(options gatewayOptions) (*GatewayHandle, error) {
 	slog.Info("[Gateway] Initializing connection...")
 
-	done := make(chan error)
-	disconnect := make(chan interface{})
+	info, err := options.gatewayInfo(context.Background())
+	if err != nil {
+		return nil, errors.New("could not fetch gateway information: " + err.Error())
+	}
+
+	manager, err := newShardManager(info, options)
+	if err != nil {
+		return nil, err
+	}
+	State.SetMemberCaching(options.intents&IntentGuildMembers != 0) // Members are never updated without the intent
+	identifiedIntents.Store(&options.intents)
+
+	done := make(chan error, 1)
+	disconnect := make(chan interface{}, 1)
 
 	go func() {
-		context := &gateway{sendQueue: make(chan []byte, defaultQueueSize), intents: intents}
-		disconnecting := atomic.Bool{}
-
-		go func() {
-			<-disconnect
-			disconnecting.Store(true)
-			context.cardiacArrest.Store(true)
-			_ = context.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
-			context.conn.Close()
-		}()
-
-		for {
-			if reconnect, err := context.connect(); !reconnect || disconnecting.Load() {
-				if disconnecting.Load() {
-					done <- nil
-				} else {
-					done <- err
-				}
-				return
-			}
+		done <- manager.run(disconnect)
+	}()
+
+	return &GatewayHandle{Done: done, Disconnect: disconnect, manager: manager}, nil
+}
+
+// newGateway creates the connection state for a single shard. The connection is not opened until listen is called.
+func newGateway(manager *shardManager, shardId int, shardCount int, options gatewayOptions, connectUrl string) *gateway {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &gateway{
+		ctx:            ctx,
+		cancel:         cancel,
+		sendQueue:      make(chan outgoingPayload, defaultQueueSize),
+		heartbeatQueue: make(chan []byte, 1),
+		clock:          realClock{},
+		manager:        manager,
+		shardId:        shardId,
+		shardCount:     shardCount,
+		logger:         slog.With(slog.Int("shard", shardId)),
+		options:        options,
+		presence:       options.presence,
+		connectUrl:     connectUrl,
+	}
+}
+
+// listen keeps the shard connected, reconnecting or resuming as needed, until disconnect is called or the connection
+// fails in a way that can't be recovered from.
+func (gateway *gateway) listen() error {
+	delays := backoff{base: reconnectBaseDelay, max: reconnectMaxDelay}
+	for {
+		reconnect, err := gateway.connect()
+		if gateway.disconnecting.Load() {
+			return nil
+		}
+		if !reconnect {
+			return err
 		}
 
-	}()
+		if gateway.ready.Load() { // The connection worked before it closed, so this is the first failed attempt
+			delays.reset()
+		}
+		delay := delays.next()
+
+		attrs := []any{slog.Duration("delay", delay), slog.Bool("resuming", gateway.resuming)}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		gateway.logger.Warn("[Gateway] Connection closed, reconnecting", attrs...)
+
+		select {
+		case <-gateway.ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// disconnect closes the shard's connection and stops it from reconnecting. If keepSession is true, the connection is
+// closed without ending the session, so it can be resumed by the next process. Otherwise, it is closed normally.
+func (gateway *gateway) disconnect(keepSession bool) {
+	gateway.disconnecting.Store(true)
+	gateway.cancel()
 
-	return &GatewayHandle{Done: done, Disconnect: disconnect}
+	gateway.connMu.Lock()
+	defer gateway.connMu.Unlock()
+	if gateway.conn == nil {
+		return
+	}
+	code := websocket.CloseNormalClosure // Discord ends the session when closed with 1000 or 1001
+	if keepSession {
+		code = websocket.CloseServiceRestart
+	}
+	_ = gateway.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(time.Second))
+	gateway.conn.Close()
+}
+
+// session returns the state needed to resume the shard's session, or false if the shard has no session to resume.
+// Only call this while the shard isn't listening.
+func (gateway *gateway) session() (GatewaySession, bool) {
+	if gateway.sessionId == "" || gateway.resumeUrl == "" {
+		return GatewaySession{}, false
+	}
+	return GatewaySession{
+		ShardId:    gateway.shardId,
+		ShardCount: gateway.shardCount,
+		SessionId:  gateway.sessionId,
+		ResumeUrl:  gateway.resumeUrl,
+		Sequence:   gateway.sequence.Load(),
+		SavedAt:    time.Now(),
+		Guilds:     gateway.shardGuilds(),
+	}, true
+}
+
+// shardGuilds returns the ID of every guild in State belonging to the shard, including unavailable ones
+func (gateway *gateway) shardGuilds() []Snowflake {
+	guilds := make([]Snowflake, 0, gateway.guilds.Load())
+	for _, id := range State.GuildIds() {
+		if guildShard(id, gateway.shardCount) == gateway.shardId {
+			guilds = append(guilds, id)
+		}
+	}
+	return guilds
+}
+
+// restoreSession makes the shard resume the given session the next time it connects, instead of identifying. Discord
+// doesn't send the session's guilds again when resuming, so they are added to State as unavailable until fillGuilds
+// has fetched them.
+func (gateway *gateway) restoreSession(session GatewaySession) {
+	gateway.resuming = true
+	gateway.sessionId = session.SessionId
+	gateway.resumeUrl = session.ResumeUrl
+	gateway.sequence.Store(session.Sequence)
+	gateway.guilds.Store(int32(len(session.Guilds)))
+	gateway.restoredGuilds = session.Guilds
+	State.SetUnavailable(session.Guilds...)
 }
 
 // connect attempts to initialize a gateway connection. If resuming is true, the connection will attempt to resuming the
@@ -112,84 +305,139 @@ func (gateway *gateway) connect() (reconnect bool, err error) {
 	} else if err != nil {
 		return false, errors.New("could not fetch a gateway url: " + err.Error())
 	}
+	if !gateway.resuming { // Shards sharing a concurrency bucket can't identify at the same time
+		// The slot is reserved before dialing, so the reader never stops reading while waiting for it
+		if err = gateway.manager.waitIdentify(gateway.ctx, gateway.shardId); err != nil {
+			return false, nil // The shard is disconnecting
+		}
+	}
 
-	slog.Info("[Gateway] Attempting to connect:", slog.String("api_version", ApiVersion), slog.String("api_encoding", ApiEncoding))
-	gateway.conn, _, err = websocket.DefaultDialer.Dial(url, nil)
+	gateway.logger.Info("[Gateway] Attempting to connect:", slog.String("api_version", ApiVersion), slog.String("api_encoding", gateway.options.encoding.name()))
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
 	if err != nil {
-		if os.IsTimeout(err) {
-			gateway.connectUrl = ""
-			return true, err
-		}
-		return false, err
+		gateway.connectUrl = "" // The url may have gone stale, fetch a new one next attempt
+		return true, err
+	}
+
+	gateway.connMu.Lock()
+	gateway.conn = conn
+	gateway.connMu.Unlock()
+	if gateway.disconnecting.Load() { // Disconnect may have been requested while dialing
+		conn.Close()
+		return false, nil
 	}
-	slog.Info("[Gateway] Websocket initialized")
+	gateway.logger.Info("[Gateway] Websocket initialized")
 
-	var closeCode int
-	gateway.conn.SetCloseHandler(func(c int, t string) error {
-		closeCode = c
-		return nil
-	}) // Capture the close code after the wg finishes
+	if gateway.options.compress { // Each connection has its own zlib context
+		gateway.inflater = &zlibStream{}
+	}
 
-	gateway.cardiacArrest.Store(false)
-	gateway.wg.Add(1)
-	go gateway.handleWriting()
-	gateway.resuming, err = gateway.readUntilClosed(gateway.resuming)
+	gateway.ready.Store(false)
+	gateway.zombied.Store(false)
+	ctx, stop := context.WithCancel(gateway.ctx) // Stops the connection's child goroutines once the reader returns
+	backlog := make(chan pendingDispatch, dispatchBacklogSize)
+	gateway.wg.Add(2)
+	go gateway.handleWriting(ctx)
+	go gateway.forwardEvents(ctx, backlog)
+	gateway.resuming, err = gateway.readUntilClosed(ctx, gateway.resuming, backlog)
 
-	gateway.cardiacArrest.Store(true)
+	stop()
 	gateway.wg.Wait()
+	if err == nil { // The reader returned without the connection closing, close it without ending the session
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseServiceRestart, ""), time.Now().Add(time.Second))
+	}
+	conn.Close()
 
 	if err == nil { // No error means the reader received a reconnect request
 		return true, nil
 	}
+	if gateway.zombied.Load() { // The session is still valid, only the connection died
+		gateway.resuming = true
+		return true, err
+	}
 
-	if closeCode == closeUnknownError || closeCode == closeNotAuthenticated || closeCode == closeInvalidSequence ||
-		closeCode == closeRateLimited || closeCode == closeTimedOut { // If not one of these, we should be safe and not attempt to resume
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) { // The connection dropped without discord closing it
 		gateway.resuming = true
+		return true, err
 	}
 
-	return true, err
+	code := getCloseCode(closeErr.Code)
+	switch code.action {
+	case closeActionFatal:
+		return false, &GatewayCloseError{Code: closeErr.Code, Name: code.name, Reason: closeErr.Text, Shard: gateway.shardId}
+	case closeActionReidentify:
+		gateway.resuming = false
+	default:
+		gateway.resuming = true
+	}
+	return true, fmt.Errorf("closed by discord with %d (%s): %s", closeErr.Code, code.name, closeErr.Text)
 }
 
-// readUntilClosed reads all payloads from a websocket connection and dispatches them to the event handler.
+// readUntilClosed reads all payloads from a websocket connection and hands dispatches to forwardEvents through backlog.
 // No stop flag is needed as the connection will return an error when it closes anyway.
-func (gateway *gateway) readUntilClosed(resuming bool) (shouldResume bool, err error) {
+func (gateway *gateway) readUntilClosed(ctx context.Context, resuming bool, backlog chan<- pendingDispatch) (shouldResume bool, err error) {
 	for {
-		if gateway.cardiacArrest.Load() {
-			return false, nil
-		}
-		var payload gatewayPayload
-		if err = gateway.conn.ReadJSON(&payload); err != nil {
+		var payload receivedPayload
+		if err = gateway.readPayload(&payload); err != nil {
 			return false, err
 		}
+		received := gateway.clock.Now()
 
 		switch payload.Opcode {
 		case opHello:
 			var hello helloPayload
-			if err = json.Unmarshal(*payload.Data, &hello); err != nil {
+			if err = gateway.options.encoding.unmarshal(payload.Data, &hello); err != nil {
 				panic(err) // Should never be hit
 			}
 			gateway.wg.Add(1)
-			go gateway.heartbeat(time.Millisecond * time.Duration(hello.HeartbeatInterval))
+			go gateway.heartbeat(ctx, time.Millisecond*time.Duration(hello.HeartbeatInterval))
 
 			gateway.identify(resuming)
 		case opDispatch:
-			gateway.sequence.Store(*payload.SequenceNum)
-			go gateway.dispatchEvent(*payload.EventName, *payload.Data)
+			if gateway.options.recorder != nil {
+				gateway.recordEvent(*payload.EventName, *payload.SequenceNum, payload.Data)
+			}
+			gateway.interceptEvent(*payload.EventName, payload.Data)
+			select {
+			case backlog <- pendingDispatch{name: *payload.EventName, raw: payload.Data, sequence: *payload.SequenceNum, received: received}:
+			case <-ctx.Done():
+				return false, ctx.Err() // The shard is disconnecting, the event will be replayed if the session is resumed
+			}
 		case opHeartbeat:
 			gateway.sendHeartbeat()
 		case opReconnect:
-			slog.Info("[Gateway] Discord requested a reconnect")
+			gateway.logger.Info("[Gateway] Discord requested a reconnect")
 			return true, nil
 		case opInvalidSession:
-			slog.Warn("[Gateway] Discord invalidated the session")
+			gateway.logger.Warn("[Gateway] Discord invalidated the session")
 			var resume bool
-			if err = json.Unmarshal(*payload.Data, &resume); err != nil {
+			if err = gateway.options.encoding.unmarshal(payload.Data, &resume); err != nil {
 				panic(err) // Should never be hit
 			}
 			return resume, nil
 		case opHeartbeatAck:
-			gateway.heartbeatAcknowledged = true
+			gateway.acknowledgeHeartbeat()
+		}
+	}
+}
+
+// readPayload blocks until a full payload has been received from the websocket connection and decodes it into payload.
+func (gateway *gateway) readPayload(payload *receivedPayload) error {
+	for {
+		msgType, data, err := gateway.conn.ReadMessage()
+		if err != nil {
+			return err
 		}
+
+		if msgType == websocket.BinaryMessage && gateway.inflater != nil {
+			if data, err = gateway.inflater.feed(data); err != nil {
+				return err
+			} else if data == nil {
+				continue // Payload was split over multiple messages, wait for the rest of it
+			}
+		}
+		return gateway.options.encoding.decode(data, payload)
 	}
 }
 
@@ -202,17 +450,54 @@ type gatewayPayload struct {
 	EventName   *string          `json:"t,omitempty"`
 }
 
+// receivedPayload is a gatewayPayload read from the connection. Data is left in the connection's encoding, so events
+// are only decoded once their handlers' payload type is known, see gatewayEncoding.unmarshal
+type receivedPayload struct {
+	Opcode      int
+	Data        []byte
+	SequenceNum *int32
+	EventName   *string
+}
+
 // sendPayload sends the given gatewayPayload on the websocket connection if one is open, or errors if there is no valid
 // connection or the payload fails to encode
 func (gateway *gateway) sendPayload(payload *gatewayPayload) {
-	encoded, err := json.Marshal(payload)
+	encoded, err := gateway.options.encoding.encode(payload)
 	if err != nil {
 		panic(err) // Should never be hit, this state is unrecoverable
 	}
-	gateway.sendQueue <- encoded
+	gateway.sendQueue <- outgoingPayload{data: encoded, queued: gateway.clock.Now()}
 }
 
-// Identify sends either an IDENTIFY or RESUME packet depending on the value of resuming
+// sendPayloadContext sends the given gatewayPayload and blocks until it has been written, returning how long it waited
+// for the send limit. If ctx has a deadline the send limit can't be met by, ErrSendRateLimited is returned instead.
+func (gateway *gateway) sendPayloadContext(ctx context.Context, payload *gatewayPayload) (time.Duration, error) {
+	encoded, err := gateway.options.encoding.encode(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	outgoing := outgoingPayload{data: encoded, queued: gateway.clock.Now(), result: make(chan sendResult, 1)}
+	if deadline, ok := ctx.Deadline(); ok {
+		outgoing.deadline = deadline
+	}
+
+	select {
+	case gateway.sendQueue <- outgoing:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+
+	select {
+	case result := <-outgoing.result:
+		return result.waited, result.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// Identify sends either an IDENTIFY or RESUME packet depending on the value of resuming. The shard's turn to identify
+// must already have been reserved by connect.
 func (gateway *gateway) identify(resume bool) {
 	if resume {
 		id, err := json.Marshal(resumePayload{
@@ -225,74 +510,98 @@ func (gateway *gateway) identify(resume bool) {
 		}
 
 		gateway.sendPayload(&gatewayPayload{Opcode: opResume, Data: (*json.RawMessage)(&id)})
-		slog.Info("[Gateway] Resuming connection")
+		gateway.logger.Info("[Gateway] Resuming connection")
 		return
 	}
+
+	gateway.presenceMu.Lock()
+	presence := gateway.presence
+	gateway.presenceMu.Unlock()
+
 	enc, err := json.Marshal(identifyPayload{
 		Token:      CommonSecrets.BotToken,
 		Properties: connectionProperties{Os: "windows", Browser: "Elaina", Device: "Elaina"},
-		Intents:    gateway.intents,
+		Intents:    gateway.options.intents,
+		Shard:      [2]int{gateway.shardId, gateway.shardCount},
+		Presence:   presence,
 	})
 	if err != nil {
 		panic(err) // Should never be hit
 	}
 
 	gateway.sendPayload(&gatewayPayload{Opcode: opIdentify, Data: (*json.RawMessage)(&enc)})
-	slog.Info("[Gateway] Identifying connection")
-}
-
-func (gateway *gateway) sendHeartbeat() {
-	data, err := json.Marshal(gateway.sequence.Load())
-	if err != nil {
-		panic(err) // should never be hit
-	}
-
-	gateway.sendPayload(&gatewayPayload{Opcode: opHeartbeat, Data: (*json.RawMessage)(&data)})
-	gateway.heartbeatAcknowledged = false
+	gateway.logger.Info("[Gateway] Identifying connection")
 }
 
-func (gateway *gateway) heartbeat(interval time.Duration) {
+// handleWriting writes queued payloads to the connection until ctx is done, blocking while there is nothing to write.
+func (gateway *gateway) handleWriting(ctx context.Context) {
 	defer gateway.wg.Done()
+	limiter := newSendLimiter(gateway.clock) // The send limit is per connection
 
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	slog.Info("[Gateway] Starting heartbeats")
-	gateway.sendHeartbeat()
 	for {
 		select {
-		case <-ticker.C:
-			if !gateway.heartbeatAcknowledged {
-				slog.Error("[Gateway] Heartbeat not acknowledged, terminating connection and resuming")
-				gateway.resuming = true
-				_ = gateway.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseServiceRestart, ""), time.Now().Add(time.Second))
-				gateway.conn.Close()
-				return
-			}
-
-			gateway.sendHeartbeat()
-		default:
-			if gateway.cardiacArrest.Load() {
-				return
-			}
+		case <-ctx.Done():
+			return
+		case heartbeat := <-gateway.heartbeatQueue:
+			gateway.writeHeartbeat(ctx, limiter, heartbeat)
+		case payload := <-gateway.sendQueue:
+			gateway.writeLimited(ctx, limiter, payload)
 		}
 	}
 }
 
-func (gateway *gateway) handleWriting() {
-	defer gateway.wg.Done()
+// writeLimited waits until payload can be written without exceeding the send limit and writes it. Heartbeats queued
+// while waiting are written immediately using the reserved sends.
+func (gateway *gateway) writeLimited(ctx context.Context, limiter *sendLimiter, payload outgoingPayload) {
 	for {
+		wait := limiter.delay(false)
+		if wait == 0 {
+			break
+		}
+		if !payload.deadline.IsZero() && gateway.clock.Now().Add(wait).After(payload.deadline) {
+			payload.report(0, ErrSendRateLimited)
+			return
+		}
+
 		select {
-		case payload := <-gateway.sendQueue:
-			if err := gateway.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
-				slog.Error("[Gateway] Failed write to connection: " + err.Error()) // This should never be hit, but just in case
-			}
-		default:
-			if gateway.cardiacArrest.Load() {
-				return
-			}
+		case <-ctx.Done():
+			payload.report(0, ctx.Err())
+			return
+		case heartbeat := <-gateway.heartbeatQueue:
+			gateway.writeHeartbeat(ctx, limiter, heartbeat)
+		case <-gateway.clock.After(wait):
 		}
 	}
+
+	limiter.take()
+	waited := gateway.clock.Now().Sub(payload.queued)
+	if waited > time.Second {
+		gateway.logger.Warn("[Gateway] Payload was delayed by the send limit", slog.Duration("waited", waited))
+	}
+
+	err := gateway.conn.WriteMessage(gateway.options.encoding.messageType(), payload.data)
+	if err != nil {
+		gateway.logger.Error("[Gateway] Failed write to connection: " + err.Error()) // This should never be hit, but just in case
+	}
+	payload.report(waited, err)
+}
+
+// setPresence stores presence to be used when identifying and sends it to discord if the shard is currently ready.
+// Presences set while the shard is reconnecting will be sent as part of the next IDENTIFY instead.
+func (gateway *gateway) setPresence(presence PresenceUpdate) {
+	gateway.presenceMu.Lock()
+	gateway.presence = &presence
+	gateway.presenceMu.Unlock()
+
+	if !gateway.ready.Load() {
+		return
+	}
+
+	enc, err := json.Marshal(presence)
+	if err != nil {
+		panic(err) // Should never be hit
+	}
+	gateway.sendPayload(&gatewayPayload{Opcode: opPresenceUpdate, Data: (*json.RawMessage)(&enc)})
 }
 
 func (gateway *gateway) getConnectUrl() (string, error) {
@@ -300,19 +609,36 @@ func (gateway *gateway) getConnectUrl() (string, error) {
 		if gateway.resumeUrl == "" {
 			return "", ErrInvalidResumeUrl
 		}
-		return gateway.resumeUrl, nil
+		return gateway.urlWithParams(gateway.resumeUrl), nil
 	}
 	if gateway.connectUrl != "" {
-		return gateway.connectUrl, nil
+		return gateway.urlWithParams(gateway.connectUrl), nil
 	}
-	return fetchGatewayUrl()
-}
 
-func fetchGatewayUrl() (string, error) {
-	resp, err := Get(ApiUrl("gateway", "bot"))
+	info, err := gateway.options.gatewayInfo(gateway.ctx)
 	if err != nil {
 		return "", err
 	}
+	gateway.connectUrl = info.Url
+	return gateway.urlWithParams(info.Url), nil
+}
+
+// urlWithParams appends the query parameters discord expects when opening a gateway connection to url.
+func (gateway *gateway) urlWithParams(url string) string {
+	out := fmt.Sprintf("%s/?v=%s&encoding=%s", url, ApiVersion, gateway.options.encoding.name())
+	if gateway.options.compress {
+		out += "&compress=zlib-stream"
+	}
+	return out
+}
+
+// fetchGatewayBot fetches the gateway url, recommended shard count and session start limits for the bot.
+// See: https://discord.com/developers/docs/events/gateway#get-gateway-bot
+func fetchGatewayBot(ctx context.Context) (*gatewayBotPayload, error) {
+	resp, err := Get(ctx, ApiUrl("gateway", "bot"))
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
@@ -320,15 +646,15 @@ func fetchGatewayUrl() (string, error) {
 		if err != nil {
 			panic(err) // Should never be hit
 		}
-		return "", fmt.Errorf("failed to fetch gateway url: %s: %s", resp.Status, string(body))
+		return nil, fmt.Errorf("failed to fetch gateway url: %s: %s", resp.Status, string(body))
 	}
 
-	var data struct{ Url string }
+	var data gatewayBotPayload
 	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return "", err
+		return nil, err
 	}
 	if data.Url == "" {
-		return "", errors.New("could not fetch gateway url. this is probably an issue on discord's end")
+		return nil, errors.New("could not fetch gateway url. this is probably an issue on discord's end")
 	}
-	return fmt.Sprintf("%s/?v=%s&encoding=%s", data.Url, ApiVersion, ApiEncoding), nil
+	return &data, nil
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// closeAction is how a shard should respond to its connection being closed
+type closeAction int
+
+const (
+	closeActionResume     closeAction = iota // Reconnect and resume the session
+	closeActionReidentify                    // Reconnect and start a new session
+	closeActionFatal                         // Stop, reconnecting would fail the same way
+)
+
+// closeCode describes a websocket close code sent by discord
+type closeCode struct {
+	name   string
+	action closeAction
+}
+
+// Websocket close codes as specified by https://discord.com/developers/docs/topics/opcodes-and-status-codes#gateway-gateway-close-event-codes
+// Codes not listed here are treated as the connection dropping and are resumed.
+var closeCodes = map[int]closeCode{
+	1000: {"Normal closure", closeActionReidentify}, // Discord invalidates the session when it closes normally
+	1001: {"Going away", closeActionReidentify},
+	4000: {"Unknown error", closeActionResume},
+	4001: {"Unknown opcode", closeActionResume},
+	4002: {"Decode error", closeActionResume},
+	4003: {"Not authenticated", closeActionReidentify},
+	4004: {"Authentication failed", closeActionFatal},
+	4005: {"Already authenticated", closeActionResume},
+	4007: {"Invalid seq", closeActionReidentify},
+	4008: {"Rate limited", closeActionResume},
+	4009: {"Session timed out", closeActionReidentify},
+	4010: {"Invalid shard", closeActionFatal},
+	4011: {"Sharding required", closeActionFatal},
+	4012: {"Invalid API version", closeActionFatal},
+	4013: {"Invalid intent(s)", closeActionFatal},
+	4014: {"Disallowed intent(s)", closeActionFatal},
+}
+
+// getCloseCode returns the closeCode matching code. Unknown codes are resumed.
+func getCloseCode(code int) closeCode {
+	if c, exists := closeCodes[code]; exists {
+		return c
+	}
+	return closeCode{"Unknown close code", closeActionResume}
+}
+
+// GatewayCloseError is sent on GatewayHandle.Done when discord closes a connection with a code that can't be recovered
+// from, such as an invalid token or disallowed intents.
+type GatewayCloseError struct {
+	Code   int
+	Name   string
+	Reason string // Text sent by discord alongside the close code
+	Shard  int
+}
+
+func (err *GatewayCloseError) Error() string {
+	return fmt.Sprintf("shard %d closed by discord with %d (%s): %s", err.Shard, err.Code, err.Name, err.Reason)
+}
+
+// backoff calculates exponentially increasing delays between reconnect attempts, with jitter so shards reconnecting
+// at the same time spread out.
+type backoff struct {
+	base     time.Duration
+	max      time.Duration
+	attempts int
+}
+
+// next returns the delay before the next attempt. Delays are random between half and all of base * 2^attempts.
+func (b *backoff) next() time.Duration {
+	delay := b.max
+	if b.attempts < 32 && b.base<<b.attempts < b.max {
+		delay = b.base << b.attempts
+	}
+	b.attempts++
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// reset starts the delays over, called once a connection has succeeded.
+func (b *backoff) reset() {
+	b.attempts = 0
+}
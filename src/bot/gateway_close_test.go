@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests that close codes map to the action discord expects clients to take
+func TestCloseCodeActions(t *testing.T) {
+	// TEST CASE: Codes caused by the bot's configuration are fatal
+	for _, code := range []int{4004, 4010, 4011, 4012, 4013, 4014} {
+		assert.Equal(t, closeActionFatal, getCloseCode(code).action, "close code %d", code)
+	}
+
+	// TEST CASE: Codes invalidating the session start a new one
+	for _, code := range []int{1000, 4003, 4007, 4009} {
+		assert.Equal(t, closeActionReidentify, getCloseCode(code).action, "close code %d", code)
+	}
+
+	// TEST CASE: Unknown codes and abnormal closures are resumed
+	for _, code := range []int{1006, 4000, 4008, 4999} {
+		assert.Equal(t, closeActionResume, getCloseCode(code).action, "close code %d", code)
+	}
+}
+
+// Tests that reconnect delays grow exponentially up to the max, stay within their jitter range and reset
+func TestBackoff(t *testing.T) {
+	b := backoff{base: time.Second, max: time.Second * 10}
+
+	for _, expected := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		delay := b.next()
+		assert.GreaterOrEqual(t, delay, expected*time.Second/2)
+		assert.LessOrEqual(t, delay, expected*time.Second)
+	}
+
+	b.reset()
+	assert.LessOrEqual(t, b.next(), time.Second)
+}
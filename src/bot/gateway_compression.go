@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+)
+
+const inflateWindowSize = 1 << 15 // Deflate back-references can reach at most 32KiB into previous output
+
+var zlibSuffix = []byte{0x00, 0x00, 0xff, 0xff}
+
+// zlibStream inflates a zlib-stream compressed gateway connection. Discord shares a single zlib context across every
+// payload of a connection and ends each payload with a sync flush, so payloads can't be inflated on their own.
+// See: https://discord.com/developers/docs/events/gateway#zlibstream
+//
+// Between payloads the deflate stream is always byte-aligned at a block boundary, so the only state carried over is the
+// window of previous output. The inflater is reset with that window as its dictionary for every payload.
+type zlibStream struct {
+	buf      []byte        // Compressed bytes of a payload which hasn't been fully received yet
+	window   []byte        // The last inflateWindowSize bytes of inflated output
+	inflater io.ReadCloser // Reused between payloads, always reset before reading
+	started  bool          // If true, the zlib header has already been read
+}
+
+// feed appends a binary websocket message to the stream. If the message completes a payload, the inflated payload is
+// returned. If more messages are needed to complete the payload, nil is returned.
+func (z *zlibStream) feed(msg []byte) ([]byte, error) {
+	z.buf = append(z.buf, msg...)
+	if !bytes.HasSuffix(z.buf, zlibSuffix) {
+		return nil, nil
+	}
+
+	data := z.buf
+	z.buf = z.buf[:0:0] // Don't reuse the backing array, data is still being read from it
+
+	if !z.started {
+		if len(data) < 2 || data[0]&0x0f != 8 || (uint16(data[0])<<8|uint16(data[1]))%31 != 0 {
+			return nil, errors.New("invalid zlib header")
+		}
+		data = data[2:]
+		z.started = true
+	}
+
+	if z.inflater == nil {
+		z.inflater = flate.NewReader(bytes.NewReader(data))
+	}
+	if err := z.inflater.(flate.Resetter).Reset(bytes.NewReader(data), z.window); err != nil {
+		return nil, err
+	}
+
+	// The stream never reaches a final block, so running out of input mid-stream is the expected way for a read to end.
+	out, err := io.ReadAll(z.inflater)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+
+	z.window = append(z.window, out...)
+	if len(z.window) > inflateWindowSize {
+		z.window = append(z.window[:0:0], z.window[len(z.window)-inflateWindowSize:]...)
+	}
+	return out, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Frames recorded from a zlib-stream gateway connection: HELLO, HEARTBEAT_ACK and RESUMED, all sharing one zlib context
+var recordedFrames = []string{
+	"789c34c9410a83301005d0bbfc752249a9a5cc558cc8a8432ba42ac9d85242ee6e37dd3d78050a5a8f180df21fdb0ef2ce6006153c85938ec23a2cab4a7a73045dfda5fdfda089270175e8021eacf2e1afddd36c8f6c85b37a3b5ad7deee01a604bc96296d39805ce36a8fbed6130000ffff",
+	"aac665b721d86e90402d000000ffff",
+	"02ab510a720d0ef5757551022b3485a88239904c57181a181a429c01000000ffff",
+}
+
+// Tests that recorded frames inflate and decode into the expected gateway payloads
+func TestZlibStreamRecorded(t *testing.T) {
+	stream := &zlibStream{}
+
+	var payloads []gatewayPayload
+	for _, frame := range recordedFrames {
+		raw, err := hex.DecodeString(frame)
+		require.NoError(t, err)
+
+		out, err := stream.feed(raw)
+		require.NoError(t, err)
+		require.NotNil(t, out)
+
+		var payload gatewayPayload
+		require.NoError(t, json.Unmarshal(out, &payload))
+		payloads = append(payloads, payload)
+	}
+
+	// TEST CASE: HELLO is decoded with its heartbeat interval
+	assert.Equal(t, opHello, payloads[0].Opcode)
+	var hello helloPayload
+	require.NoError(t, json.Unmarshal(*payloads[0].Data, &hello))
+	assert.Equal(t, int64(41250), hello.HeartbeatInterval)
+
+	// TEST CASE: Later frames rely on the context of earlier ones
+	assert.Equal(t, opHeartbeatAck, payloads[1].Opcode)
+	assert.Equal(t, opDispatch, payloads[2].Opcode)
+	assert.Equal(t, "RESUMED", *payloads[2].EventName)
+	assert.Equal(t, int32(5), *payloads[2].SequenceNum)
+}
+
+// Tests that payloads split over multiple messages are only returned once complete, and that back-references into
+// output from previous payloads still resolve once more than a full window of output has been produced.
+func TestZlibStreamSplitAndWindow(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	stream := &zlibStream{}
+
+	content := strings.Repeat("Elaina is the best witch. ", 2000) // Well over the 32KiB window
+	for i := 0; i < 5; i++ {
+		payload := fmt.Sprintf(`{"op":0,"s":%d,"t":"MESSAGE_CREATE","d":{"content":"%s"}}`, i, content)
+		_, err := writer.Write([]byte(payload))
+		require.NoError(t, err)
+		require.NoError(t, writer.Flush()) // Flush is a sync flush, ending the frame in 00 00 ff ff like discord does
+
+		frame := bytes.Clone(compressed.Bytes())
+		compressed.Reset()
+
+		// TEST CASE: The first half of a split payload returns nothing
+		out, err := stream.feed(frame[:len(frame)/2])
+		require.NoError(t, err)
+		assert.Nil(t, out)
+
+		// TEST CASE: The second half returns the full payload
+		out, err = stream.feed(frame[len(frame)/2:])
+		require.NoError(t, err)
+		assert.Equal(t, payload, string(out))
+	}
+}
+
+// Tests that a stream not starting with a zlib header is rejected
+func TestZlibStreamInvalidHeader(t *testing.T) {
+	_, err := (&zlibStream{}).feed([]byte{0x01, 0x02, 0x00, 0x00, 0xff, 0xff})
+	assert.Error(t, err)
+}
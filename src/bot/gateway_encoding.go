@@ -0,0 +1,107 @@
+package main
+
+import (
+	. "elaina-common"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// gatewayEncoding converts payloads to and from the format requested with the encoding parameter when connecting.
+// The data of received payloads is kept in that format until a handler decodes it with unmarshal, so it is only decoded
+// once, straight into the handler's payload type.
+// See: https://discord.com/developers/docs/events/gateway#encoding-and-compression
+type gatewayEncoding interface {
+	name() string     // Value of the encoding query parameter
+	messageType() int // Websocket message type payloads are sent as
+	encode(payload *gatewayPayload) ([]byte, error)
+	decode(data []byte, payload *receivedPayload) error
+	unmarshal(data []byte, v any) error // Decodes the data of a receivedPayload into v
+	toJson(data []byte) ([]byte, error) // Transcodes the data of a receivedPayload into JSON
+}
+
+var gatewayEncodings = map[string]gatewayEncoding{
+	"json": jsonEncoding{},
+	"etf":  etfEncoding{},
+}
+
+// getGatewayEncoding returns the gatewayEncoding matching name, or an error if the encoding is not supported.
+func getGatewayEncoding(name string) (gatewayEncoding, error) {
+	if encoding, exists := gatewayEncodings[name]; exists {
+		return encoding, nil
+	}
+	return nil, fmt.Errorf("unsupported gateway encoding: %s", name)
+}
+
+type jsonEncoding struct{}
+
+func (jsonEncoding) name() string {
+	return "json"
+}
+
+func (jsonEncoding) messageType() int {
+	return websocket.TextMessage
+}
+
+func (jsonEncoding) encode(payload *gatewayPayload) ([]byte, error) {
+	return json.Marshal(payload)
+}
+
+func (jsonEncoding) decode(data []byte, payload *receivedPayload) error {
+	var wire struct {
+		Opcode      int             `json:"op"`
+		Data        json.RawMessage `json:"d"`
+		SequenceNum *int32          `json:"s"`
+		EventName   *string         `json:"t"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+	*payload = receivedPayload{Opcode: wire.Opcode, Data: wire.Data, SequenceNum: wire.SequenceNum, EventName: wire.EventName}
+	return nil
+}
+
+func (jsonEncoding) unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonEncoding) toJson(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+type etfEncoding struct{}
+
+func (etfEncoding) name() string {
+	return "etf"
+}
+
+func (etfEncoding) messageType() int {
+	return websocket.BinaryMessage
+}
+
+func (etfEncoding) encode(payload *gatewayPayload) ([]byte, error) {
+	return MarshalEtf(payload)
+}
+
+func (etfEncoding) decode(data []byte, payload *receivedPayload) error {
+	var wire struct {
+		Opcode      int     `json:"op"`
+		Data        EtfTerm `json:"d"`
+		SequenceNum *int32  `json:"s"`
+		EventName   *string `json:"t"`
+	}
+	if err := UnmarshalEtf(data, &wire); err != nil {
+		return err
+	}
+	*payload = receivedPayload{Opcode: wire.Opcode, Data: wire.Data, SequenceNum: wire.SequenceNum, EventName: wire.EventName}
+	return nil
+}
+
+func (etfEncoding) unmarshal(data []byte, v any) error {
+	return UnmarshalEtf(data, v)
+}
+
+func (etfEncoding) toJson(data []byte) ([]byte, error) {
+	return EtfToJson(data)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// heartbeat sends heartbeats every interval until ctx is done. The first heartbeat is sent after interval * jitter,
+// where jitter is random between 0 and 1, so every client reconnecting at once doesn't heartbeat at once too.
+// If discord doesn't acknowledge a heartbeat before the next one is due, the connection is closed so it can be resumed.
+// See: https://discord.com/developers/docs/events/gateway#sending-heartbeats
+func (gateway *gateway) heartbeat(ctx context.Context, interval time.Duration) {
+	defer gateway.wg.Done()
+
+	timer := time.NewTimer(time.Duration(rand.Float64() * float64(interval)))
+	defer timer.Stop()
+
+	gateway.heartbeatAcknowledged.Store(true) // Nothing has been sent yet, so there is nothing to wait for
+	gateway.logger.Info("[Gateway] Starting heartbeats", slog.Duration("interval", interval))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			if !gateway.heartbeatAcknowledged.Load() {
+				gateway.logger.Error("[Gateway] Heartbeat not acknowledged, terminating connection and resuming")
+				gateway.zombied.Store(true)
+				_ = gateway.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseServiceRestart, ""), time.Now().Add(time.Second))
+				gateway.conn.Close() // The reader will return once the connection is closed
+				return
+			}
+
+			gateway.sendHeartbeat()
+			timer.Reset(interval)
+		}
+	}
+}
+
+// sendHeartbeat queues a heartbeat containing the last sequence number received.
+func (gateway *gateway) sendHeartbeat() {
+	data, err := json.Marshal(gateway.sequence.Load())
+	if err != nil {
+		panic(err) // should never be hit
+	}
+	encoded, err := gateway.options.encoding.encode(&gatewayPayload{Opcode: opHeartbeat, Data: (*json.RawMessage)(&data)})
+	if err != nil {
+		panic(err) // should never be hit
+	}
+
+	gateway.heartbeatAcknowledged.Store(false)
+	select {
+	case gateway.heartbeatQueue <- encoded:
+	default: // A heartbeat is already waiting to be sent, which will carry the latest sequence anyway
+	}
+}
+
+// writeHeartbeat writes a heartbeat to the connection, recording when it was written to measure latency.
+func (gateway *gateway) writeHeartbeat(ctx context.Context, limiter *sendLimiter, heartbeat []byte) {
+	if wait := limiter.delay(true); wait > 0 { // Only possible if discord requests heartbeats far more often than it should
+		select {
+		case <-ctx.Done():
+			return
+		case <-gateway.clock.After(wait):
+		}
+	}
+	limiter.take()
+
+	gateway.heartbeatSentAt.Store(gateway.clock.Now().UnixNano())
+	if err := gateway.conn.WriteMessage(gateway.options.encoding.messageType(), heartbeat); err != nil {
+		gateway.logger.Error("[Gateway] Failed write to connection: " + err.Error())
+	}
+}
+
+// acknowledgeHeartbeat is called when discord acknowledges a heartbeat, updating the measured latency.
+func (gateway *gateway) acknowledgeHeartbeat() {
+	gateway.heartbeatAcknowledged.Store(true)
+	if sent := gateway.heartbeatSentAt.Load(); sent > 0 {
+		gateway.latency.Store(gateway.clock.Now().UnixNano() - sent)
+	}
+}
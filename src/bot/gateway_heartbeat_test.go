@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestConn opens a websocket connection to a server which reads and discards everything sent to it.
+func newTestConn(t *testing.T) *websocket.Conn {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, _, err = conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// Tests that a connection which never acknowledges heartbeats is detected as a zombie and closed
+func TestHeartbeatZombie(t *testing.T) {
+	gateway := newGateway(nil, 0, 1, gatewayOptions{encoding: jsonEncoding{}}, "")
+	gateway.conn = newTestConn(t)
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	gateway.wg.Add(2)
+	go gateway.handleWriting(ctx)
+	go gateway.heartbeat(ctx, time.Millisecond*20)
+
+	// TEST CASE: heartbeat returns by itself after the second interval without an acknowledgement
+	done := make(chan struct{})
+	go func() {
+		gateway.wg.Wait()
+		close(done)
+	}()
+	time.Sleep(time.Millisecond * 100)
+	stop() // Only the writer should still be running at this point
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("gateway goroutines did not stop")
+	}
+	assert.True(t, gateway.zombied.Load())
+
+	// TEST CASE: The connection was closed
+	_, _, err := gateway.conn.ReadMessage()
+	assert.Error(t, err)
+}
+
+// Tests that latency is measured from a heartbeat being written until it is acknowledged
+func TestHeartbeatLatency(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	shard := newGateway(nil, 0, 1, gatewayOptions{encoding: jsonEncoding{}}, "")
+	shard.clock = clock
+	shard.conn = newTestConn(t)
+
+	shard.sendHeartbeat()
+	assert.False(t, shard.heartbeatAcknowledged.Load())
+
+	shard.writeHeartbeat(context.Background(), newSendLimiter(clock), <-shard.heartbeatQueue)
+	clock.advance(time.Millisecond * 42)
+	shard.acknowledgeHeartbeat()
+
+	assert.True(t, shard.heartbeatAcknowledged.Load())
+	assert.Equal(t, time.Millisecond*42, time.Duration(shard.latency.Load()))
+
+	handle := &GatewayHandle{manager: &shardManager{shards: []*gateway{shard}}}
+	assert.Equal(t, time.Millisecond*42, handle.Latency())
+}
@@ -0,0 +1,51 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cpuTime(t *testing.T) time.Duration {
+	var usage syscall.Rusage
+	require.NoError(t, syscall.Getrusage(syscall.RUSAGE_SELF, &usage))
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// Tests that an idle connection's writer and heartbeat goroutines block instead of spinning
+func TestGatewayIdleCpu(t *testing.T) {
+	gateway := newGateway(nil, 0, 1, gatewayOptions{encoding: jsonEncoding{}}, "")
+	gateway.conn = newTestConn(t)
+
+	ctx, stop := context.WithCancel(context.Background())
+	gateway.wg.Add(2)
+	go gateway.handleWriting(ctx)
+	go gateway.heartbeat(ctx, time.Hour)
+
+	time.Sleep(time.Millisecond * 50) // Let both goroutines start
+	start := cpuTime(t)
+	time.Sleep(time.Millisecond * 500)
+	used := cpuTime(t) - start
+
+	// TEST CASE: Idling for 500ms uses a tiny fraction of a core. A spinning goroutine would use all of it.
+	assert.Less(t, used, time.Millisecond*50)
+
+	// TEST CASE: Both goroutines exit once the connection's context is cancelled
+	stop()
+	done := make(chan struct{})
+	go func() {
+		gateway.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("gateway goroutines did not stop")
+	}
+}
@@ -27,6 +27,8 @@ type identifyPayload struct {
 	Token      string               `json:"token"`
 	Properties connectionProperties `json:"properties"`
 	Intents    int                  `json:"intents"`
+	Shard      [2]int               `json:"shard"`              // [shard_id, num_shards]
+	Presence   *PresenceUpdate      `json:"presence,omitempty"` // Optional
 }
 
 // resumePayload is a non-standard event, it doesn't have a type. Opcode 6 instead
@@ -39,10 +41,37 @@ type resumePayload struct {
 // readyPayload is sent by discord after the client has successfully identified itself and is ready to receive events.
 // https://discord.com/developers/docs/events/gateway-events#ready
 type readyPayload struct {
-	ApiVersion       int         `json:"v"`
-	User             User        `json:"user"`
-	Guilds           []Guild     `json:"guilds"`
-	SessionId        string      `json:"session_id"`
-	ResumeGatewayUrl string      `json:"resume_gateway_url"`
-	Application      Application `json:"application"`
+	ApiVersion       int                `json:"v"`
+	User             User               `json:"user"`
+	Guilds           []UnavailableGuild `json:"guilds"` // Each guild is sent in a GUILD_CREATE once it becomes available
+	SessionId        string             `json:"session_id"`
+	ResumeGatewayUrl string             `json:"resume_gateway_url"`
+	Application      Application        `json:"application"`
+}
+
+// gatewayBotPayload is returned by discord when requesting the gateway url for a bot.
+// https://discord.com/developers/docs/events/gateway#get-gateway-bot
+type gatewayBotPayload struct {
+	Url               string            `json:"url"`
+	Shards            int               `json:"shards"`
+	SessionStartLimit sessionStartLimit `json:"session_start_limit"`
+}
+
+// sessionStartLimit represents https://discord.com/developers/docs/events/gateway#session-start-limit-object
+type sessionStartLimit struct {
+	Total          int `json:"total"`
+	Remaining      int `json:"remaining"`
+	ResetAfter     int `json:"reset_after"` // Milliseconds
+	MaxConcurrency int `json:"max_concurrency"`
+}
+
+// requestGuildMembersPayload is a non-standard event, it doesn't have a type. Opcode 8 instead
+// https://discord.com/developers/docs/events/gateway-events#request-guild-members
+type requestGuildMembersPayload struct {
+	GuildId   Snowflake   `json:"guild_id"`
+	Query     *string     `json:"query,omitempty"` // One of Query or UserIds is required
+	Limit     int         `json:"limit"`
+	Presences bool        `json:"presences,omitempty"`
+	UserIds   []Snowflake `json:"user_ids,omitempty"`
+	Nonce     string      `json:"nonce,omitempty"`
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// Discord closes connections which send more than gatewaySendLimit payloads within gatewaySendWindow
+// See: https://discord.com/developers/docs/events/gateway#rate-limiting
+const (
+	gatewaySendLimit  = 120
+	gatewaySendWindow = time.Minute
+)
+
+// heartbeatReserve is the number of sends other payloads can't use, so heartbeats can always be sent. Discord's
+// heartbeat interval allows for ~2 per minute, with room left for heartbeats discord requests with opHeartbeat.
+const heartbeatReserve = 4
+
+var ErrSendRateLimited = errors.New("[Gateway] payload can't be sent before its deadline without exceeding the send limit")
+
+// clock abstracts time so the send limiter can be tested without waiting for real time to pass
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// sendLimiter is a sliding window log of when each payload within the last gatewaySendWindow was sent. A payload may be
+// sent while fewer than gatewaySendLimit were sent within the window, so no window of that length, wherever it starts,
+// ever holds more than the limit. It is only used by the writer goroutine of a single connection, so it is not safe
+// for concurrent use.
+type sendLimiter struct {
+	clock clock
+	sent  []time.Time // Oldest first
+}
+
+func newSendLimiter(clock clock) *sendLimiter {
+	return &sendLimiter{clock: clock, sent: make([]time.Time, 0, gatewaySendLimit)}
+}
+
+// prune forgets sends which have left the window.
+func (l *sendLimiter) prune(now time.Time) {
+	expired := 0
+	for expired < len(l.sent) && now.Sub(l.sent[expired]) >= gatewaySendWindow {
+		expired++
+	}
+	l.sent = append(l.sent[:0], l.sent[expired:]...)
+}
+
+// delay returns how long until a payload can be sent. Heartbeats may use the reserved sends, other payloads may not.
+func (l *sendLimiter) delay(heartbeat bool) time.Duration {
+	now := l.clock.Now()
+	l.prune(now)
+
+	allowed := gatewaySendLimit - heartbeatReserve
+	if heartbeat {
+		allowed = gatewaySendLimit
+	}
+	if len(l.sent) < allowed {
+		return 0
+	}
+	// Enough of the oldest sends must leave the window for one fewer than allowed to remain
+	return l.sent[len(l.sent)-allowed].Add(gatewaySendWindow).Sub(now)
+}
+
+// take records a payload being sent. delay should be 0 before calling take.
+func (l *sendLimiter) take() {
+	now := l.clock.Now()
+	l.prune(now)
+	l.sent = append(l.sent, now)
+}
+
+// outgoingPayload is an encoded payload waiting in the send queue
+type outgoingPayload struct {
+	data     []byte
+	queued   time.Time
+	deadline time.Time       // If not zero, the payload is rejected with ErrSendRateLimited if it can't be sent before then
+	result   chan sendResult // Optional, must be buffered
+}
+
+// sendResult reports how long a payload waited in the send queue before being written, or why it wasn't written.
+type sendResult struct {
+	waited time.Duration
+	err    error
+}
+
+func (p *outgoingPayload) report(waited time.Duration, err error) {
+	if p.result != nil {
+		p.result <- sendResult{waited: waited, err: err}
+	}
+}
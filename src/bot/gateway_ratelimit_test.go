@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock only moves forward when advance is called. Channels returned by After fire once the clock passes them.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := fakeTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, timer)
+	return timer.c
+}
+
+func (c *fakeClock) waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	remaining := c.timers[:0]
+	for _, timer := range c.timers {
+		if !timer.at.After(c.now) {
+			timer.c <- c.now
+		} else {
+			remaining = append(remaining, timer)
+		}
+	}
+	c.timers = remaining
+}
+
+// sendFor takes every send the limiter allows right now, returning how many were sent
+func sendFor(limiter *sendLimiter, heartbeat bool) int {
+	sent := 0
+	for limiter.delay(heartbeat) == 0 {
+		limiter.take()
+		sent++
+	}
+	return sent
+}
+
+// Tests that the limiter allows bursts up to the limit, keeps heartbeat headroom and frees sends as they leave the window
+func TestSendLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limiter := newSendLimiter(clock)
+
+	// TEST CASE: Regular payloads can burst until only the heartbeat reserve is left
+	assert.Equal(t, gatewaySendLimit-heartbeatReserve, sendFor(limiter, false))
+	assert.Equal(t, gatewaySendWindow, limiter.delay(false))
+
+	// TEST CASE: Heartbeats can still use the reserve
+	assert.Equal(t, heartbeatReserve, sendFor(limiter, true))
+	assert.Equal(t, gatewaySendWindow, limiter.delay(true))
+
+	// TEST CASE: Nothing is freed until the sends leave the window, unlike a continuously refilled bucket
+	clock.advance(gatewaySendWindow / 2)
+	assert.Equal(t, gatewaySendWindow/2, limiter.delay(false))
+	clock.advance(gatewaySendWindow / 2)
+	assert.Equal(t, gatewaySendLimit-heartbeatReserve, sendFor(limiter, false))
+}
+
+// Tests that bursts on both sides of a window boundary never put more than the limit into any window
+func TestSendLimiterWindowBoundary(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	limiter := newSendLimiter(clock)
+	var sent []time.Time
+	burst := func(heartbeat bool) {
+		for limiter.delay(heartbeat) == 0 {
+			limiter.take()
+			sent = append(sent, clock.Now())
+		}
+	}
+
+	burst(false)
+	clock.advance(gatewaySendWindow - time.Second)
+	burst(true) // The reserve is all that's left before the boundary
+	clock.advance(time.Second)
+	burst(false) // The first burst has left the window
+	clock.advance(time.Second * 30)
+	burst(true)
+
+	// TEST CASE: Sends continue across the boundary once earlier ones leave the window. The reserve used just before
+	// the boundary is still in the window at the end, so one reserve's worth is missing from the second window.
+	assert.Equal(t, 2*gatewaySendLimit-heartbeatReserve, len(sent))
+
+	// TEST CASE: No window of gatewaySendWindow, wherever it starts, holds more than the limit
+	for i, start := range sent {
+		inWindow := 0
+		for _, at := range sent[i:] {
+			if at.Sub(start) < gatewaySendWindow {
+				inWindow++
+			}
+		}
+		require.LessOrEqual(t, inWindow, gatewaySendLimit, "window starting at %s", start)
+	}
+}
+
+// Tests that payloads which can't be sent before their deadline are rejected, and that waits are reported
+func TestSendLimiterDeadline(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	gateway := newGateway(nil, 0, 1, gatewayOptions{encoding: jsonEncoding{}}, "")
+	gateway.clock = clock
+	gateway.conn = newTestConn(t)
+
+	limiter := newSendLimiter(clock)
+	sendFor(limiter, false) // Full as far as regular payloads are concerned
+
+	// TEST CASE: Deadline before a send is available rejects the payload without using a send
+	payload := outgoingPayload{queued: clock.Now(), deadline: clock.Now().Add(time.Millisecond * 100), result: make(chan sendResult, 1)}
+	gateway.writeLimited(context.Background(), limiter, payload)
+	result := <-payload.result
+	assert.ErrorIs(t, result.err, ErrSendRateLimited)
+	assert.Len(t, limiter.sent, gatewaySendLimit-heartbeatReserve)
+
+	// TEST CASE: A payload whose deadline can be met waits for the window and is written
+	payload = outgoingPayload{data: []byte(`{"op":3}`), queued: clock.Now(), deadline: clock.Now().Add(gatewaySendWindow * 2), result: make(chan sendResult, 1)}
+	go gateway.writeLimited(context.Background(), limiter, payload)
+	require.Eventually(t, func() bool { return clock.waiting() == 1 }, time.Second, time.Millisecond)
+	clock.advance(gatewaySendWindow)
+
+	result = <-payload.result
+	assert.NoError(t, result.err)
+	assert.Equal(t, gatewaySendWindow, result.waited)
+	assert.Len(t, limiter.sent, 1)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"elaina-bot/gatewaytest"
+	. "elaina-common"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startTestGateway starts a fake gateway and connects the bot to it, returning the first connection it opened.
+func startTestGateway(t *testing.T, server *gatewaytest.Server) (*GatewayHandle, *gatewaytest.Conn) {
+	handle, err := listenGateway(gatewayOptions{intents: baseIntents, encoding: jsonEncoding{}, url: server.URL})
+	require.NoError(t, err)
+	t.Cleanup(handle.Close)
+
+	conn, err := server.Accept()
+	require.NoError(t, err)
+	return handle, conn
+}
+
+// waitDone waits for the gateway to stop and returns the error it stopped with
+func waitDone(t *testing.T, handle *GatewayHandle) error {
+	select {
+	case err := <-handle.Done:
+		return err
+	case <-time.After(gatewaytest.DefaultTimeout):
+		t.Fatal("gateway did not stop")
+		return nil
+	}
+}
+
+// Tests that the bot identifies, receives events and closes the session normally when asked to
+func TestGatewayIdentify(t *testing.T) {
+	server := gatewaytest.NewServer()
+	defer server.Close()
+
+	messages := make(chan CreateMessagePayload, 1)
+	received := make(chan time.Time, 1)
+	registration := Events.CreateMessage.Register(func(ctx context.Context, payload CreateMessagePayload) error {
+		received <- getEventInfo(ctx).Received
+		messages <- payload
+		return nil
+	})
+	defer registration.Unregister()
+
+	handle, conn := startTestGateway(t, server)
+	shard := handle.manager.shards[0]
+	assert.Equal(t, []string{ApiVersion}, conn.Query["v"])
+	assert.Equal(t, []string{"json"}, conn.Query["encoding"])
+
+	// TEST CASE: IDENTIFY is sent after HELLO with the bot's token, intents and shard
+	require.NoError(t, conn.Hello(time.Second*45))
+	var identify identifyPayload
+	require.NoError(t, conn.Expect(gatewaytest.OpIdentify, &identify))
+	assert.Equal(t, CommonSecrets.BotToken, identify.Token)
+	assert.Equal(t, baseIntents, identify.Intents)
+	assert.Equal(t, [2]int{0, 1}, identify.Shard)
+
+	// TEST CASE: READY starts the session
+	sessionId, err := conn.Ready("1", "2")
+	require.NoError(t, err)
+	assert.Eventually(t, shard.ready.Load, time.Second, time.Millisecond*5)
+	assert.Equal(t, 2, handle.GuildCount())
+
+	// TEST CASE: Dispatched events are delivered to registered handlers along with when they were received
+	sent := time.Now()
+	sequence, err := conn.Dispatch("MESSAGE_CREATE", map[string]any{"id": "10", "channel_id": "20", "content": "Hello"})
+	require.NoError(t, err)
+	select {
+	case message := <-messages:
+		assert.Equal(t, "Hello", message.Content)
+		assert.Equal(t, Snowflake(20), message.ChannelId)
+		assert.WithinRange(t, <-received, sent, time.Now())
+	case <-time.After(time.Second):
+		t.Fatal("event was not delivered")
+	}
+	assert.Equal(t, sequence, shard.sequence.Load())
+	assert.Equal(t, sessionId, shard.sessionId)
+
+	// TEST CASE: Closing the handle ends the session with a normal closure
+	handle.Close()
+	code, err := conn.WaitClosed()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.CloseNormalClosure, code)
+	assert.NoError(t, waitDone(t, handle))
+}
+
+// Tests that the bot resumes its session when discord asks it to, and stops on fatal close codes
+func TestGatewayResume(t *testing.T) {
+	server := gatewaytest.NewServer()
+	defer server.Close()
+
+	handle, conn := startTestGateway(t, server)
+	opcode, sessionId, err := conn.Handshake(time.Second * 45)
+	require.NoError(t, err)
+	require.Equal(t, gatewaytest.OpIdentify, opcode)
+	sequence, err := conn.Dispatch("TYPING_START", map[string]any{})
+	require.NoError(t, err)
+
+	// TEST CASE: RECONNECT closes the connection without ending the session and resumes from the last sequence
+	assert.Eventually(t, func() bool { return handle.manager.shards[0].sequence.Load() == sequence }, time.Second, time.Millisecond*5)
+	require.NoError(t, conn.Reconnect())
+	code, err := conn.WaitClosed()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.CloseServiceRestart, code)
+
+	conn, err = server.Accept()
+	require.NoError(t, err)
+	require.NoError(t, conn.Hello(time.Second*45))
+	var resume resumePayload
+	require.NoError(t, conn.Expect(gatewaytest.OpResume, &resume))
+	assert.Equal(t, sessionId, resume.SessionId)
+	assert.Equal(t, sequence, resume.SequenceNum)
+	require.NoError(t, conn.Resumed(resume.SequenceNum))
+
+	// TEST CASE: A resumable INVALID_SESSION resumes on a new connection
+	require.NoError(t, conn.InvalidSession(true))
+	conn, err = server.Accept()
+	require.NoError(t, err)
+	opcode, _, err = conn.Handshake(time.Second * 45)
+	require.NoError(t, err)
+	assert.Equal(t, gatewaytest.OpResume, opcode)
+
+	// TEST CASE: Fatal close codes stop the gateway with a GatewayCloseError
+	require.NoError(t, conn.CloseWith(4004, "Authentication failed."))
+	var closeErr *GatewayCloseError
+	require.True(t, errors.As(waitDone(t, handle), &closeErr))
+	assert.Equal(t, 4004, closeErr.Code)
+	assert.Equal(t, "Authentication failed.", closeErr.Reason)
+}
+
+// Tests that heartbeats are sent at the interval from HELLO, and that missing acknowledgements cause a resume
+func TestGatewayHeartbeat(t *testing.T) {
+	server := gatewaytest.NewServer()
+	defer server.Close()
+
+	// TEST CASE: Acknowledged heartbeats are sent repeatedly and measure latency
+	handle, conn := startTestGateway(t, server)
+	_, _, err := conn.Handshake(time.Millisecond * 50)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, conn.WaitHeartbeats(ctx, 3))
+	assert.Greater(t, handle.Latency(), time.Duration(0))
+
+	// TEST CASE: The bot resumes on a new connection once a heartbeat isn't acknowledged
+	server.AckHeartbeats = false
+	require.NoError(t, conn.Reconnect())
+	conn, err = server.Accept()
+	require.NoError(t, err)
+	opcode, _, err := conn.Handshake(time.Millisecond * 50)
+	require.NoError(t, err)
+	require.Equal(t, gatewaytest.OpResume, opcode)
+
+	code, err := conn.WaitClosed()
+	require.NoError(t, err)
+	assert.Equal(t, websocket.CloseServiceRestart, code)
+
+	conn, err = server.Accept()
+	require.NoError(t, err)
+	require.NoError(t, conn.Hello(time.Second*45))
+	assert.NoError(t, conn.Expect(gatewaytest.OpResume, nil))
+}
+
+// Tests that waiting for an identify slot gives up once the shard is asked to disconnect
+func TestWaitIdentifyCancelled(t *testing.T) {
+	manager := newManager(1, 1, gatewayOptions{})
+	require.NoError(t, manager.waitIdentify(context.Background(), 0)) // Reserves the slot for identifyInterval
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*20, cancel)
+
+	// TEST CASE: The wait returns as soon as ctx is cancelled, instead of after identifyInterval
+	start := time.Now()
+	err := manager.waitIdentify(ctx, 0)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Less(t, time.Since(start), identifyInterval/2)
+}
+
+// Tests that a shard waits for its identify slot before connecting, so the connection is never left unread meanwhile
+func TestIdentifySlotBeforeDial(t *testing.T) {
+	server := gatewaytest.NewServer()
+	defer server.Close()
+
+	handle, conn := startTestGateway(t, server)
+	_, _, err := conn.Handshake(time.Second * 45)
+	require.NoError(t, err)
+
+	slot := time.Now().Add(time.Millisecond * 1500)
+	bucket := &handle.manager.buckets[0]
+	bucket.mu.Lock()
+	bucket.next = slot
+	bucket.mu.Unlock()
+
+	// TEST CASE: The new connection is only opened once the slot is free, and IDENTIFY follows HELLO immediately
+	require.NoError(t, conn.InvalidSession(false))
+	conn, err = server.Accept()
+	require.NoError(t, err)
+	assert.False(t, time.Now().Before(slot), "connected before the identify slot")
+
+	require.NoError(t, conn.Hello(time.Second*45))
+	start := time.Now()
+	require.NoError(t, conn.Expect(gatewaytest.OpIdentify, nil))
+	assert.Less(t, time.Since(start), time.Millisecond*250)
+}
+
+// Tests that the reader keeps answering heartbeat requests while the event workers are full, and that the sequence only
+// advances once an event has been queued
+func TestGatewayBackpressure(t *testing.T) {
+	server := gatewaytest.NewServer()
+	defer server.Close()
+
+	release := make(chan struct{})
+	var handled atomic.Int32
+	registration := Events.CreateMessage.Register(func(ctx context.Context, payload CreateMessagePayload) error {
+		<-release
+		handled.Add(1)
+		return nil
+	})
+	defer registration.Unregister()
+
+	handle, err := listenGateway(gatewayOptions{intents: baseIntents, encoding: jsonEncoding{}, url: server.URL, workers: 1, queueSize: 1})
+	require.NoError(t, err)
+	t.Cleanup(handle.Close)
+	conn, err := server.Accept()
+	require.NoError(t, err)
+	_, _, err = conn.Handshake(time.Second * 45)
+	require.NoError(t, err)
+	shard := handle.manager.shards[0]
+
+	// TEST CASE: Heartbeat requests are answered while the worker and its queue are full
+	var sequences []int32
+	for range 4 {
+		sequence, err := conn.Dispatch("MESSAGE_CREATE", map[string]any{"id": "10", "channel_id": "20"})
+		require.NoError(t, err)
+		sequences = append(sequences, sequence)
+	}
+	require.Eventually(t, func() bool { return handle.EventQueueStats().Blocked > 0 }, time.Second, time.Millisecond*5)
+
+	heartbeats := conn.Heartbeats()
+	require.NoError(t, conn.SendOp(gatewaytest.OpHeartbeat, nil))
+	assert.Eventually(t, func() bool { return conn.Heartbeats() > heartbeats }, time.Second, time.Millisecond*5)
+	assert.Less(t, shard.sequence.Load(), sequences[len(sequences)-1], "events still waiting for a worker must be replayed on resume")
+
+	// TEST CASE: Every event is handled once the worker catches up
+	close(release)
+	assert.Eventually(t, func() bool { return handled.Load() == int32(len(sequences)) }, time.Second, time.Millisecond*5)
+	assert.Equal(t, sequences[len(sequences)-1], shard.sequence.Load())
+}
+
+// Tests that a restored session brings back its guilds, as resuming doesn't send READY or GUILD_CREATE
+func TestGatewayRestoreSession(t *testing.T) {
+	server := gatewaytest.NewServer()
+	defer server.Close()
+	discord := startFakeDiscord(t)
+	discord.AddGuild(Guild{Id: 7000, Name: "Restored"})
+	discord.AddRole(7000, Role{Id: 7000, Permissions: PermSendMessages})
+	discord.AddChannel(Channel{Id: 7010, GuildId: 7000})
+	defer State.RemoveGuild(7000)
+	defer State.RemoveGuild(7001)
+
+	store := fileSessionStore{path: filepath.Join(t.TempDir(), "sessions.json")}
+	require.NoError(t, store.save([]GatewaySession{{ShardId: 0, ShardCount: 1, SessionId: "a", ResumeUrl: server.URL, Sequence: 5, SavedAt: time.Now(), Guilds: []Snowflake{7000, 7001}}}))
+	handle, err := listenGateway(gatewayOptions{intents: baseIntents, encoding: jsonEncoding{}, url: server.URL, sessions: store})
+	require.NoError(t, err)
+	t.Cleanup(handle.Close)
+
+	// TEST CASE: The saved guilds are counted and stored as unavailable before the session is resumed
+	assert.Equal(t, 2, handle.GuildCount())
+	assert.True(t, State.IsUnavailable(7000))
+
+	conn, err := server.Accept()
+	require.NoError(t, err)
+	require.NoError(t, conn.Hello(time.Second*45))
+	var resume resumePayload
+	require.NoError(t, conn.Expect(gatewaytest.OpResume, &resume))
+	assert.Equal(t, "a", resume.SessionId)
+	require.NoError(t, conn.Resumed(resume.SequenceNum))
+
+	// TEST CASE: Once resumed, the guilds are fetched over REST, and those that can't be stay unavailable
+	require.Eventually(t, func() bool { return State.Guild(7000) != nil }, time.Second, time.Millisecond*5)
+	assert.Equal(t, "Restored", State.Guild(7000).Name)
+	assert.NotNil(t, State.Role(7000, 7000))
+	assert.NotNil(t, State.Channel(7010))
+	assert.True(t, State.IsUnavailable(7001))
+	assert.Equal(t, 2, handle.GuildCount())
+
+	// TEST CASE: The guilds are saved with the session again on shutdown
+	handle.Close()
+	require.NoError(t, waitDone(t, handle))
+	sessions, err := store.load()
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Subset(t, sessions[0].Guilds, []Snowflake{7000, 7001}) // State is shared with the other tests
+}
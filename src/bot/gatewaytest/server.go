@@ -0,0 +1,330 @@
+// Package gatewaytest runs a local websocket server speaking the discord gateway protocol, so the bot's gateway
+// connection can be tested without connecting to discord. Tests script the server through Conn: sending HELLO, READY,
+// dispatches, RECONNECT, INVALID_SESSION and close codes, and asserting the payloads the bot sends back.
+// Only the json encoding without transport compression is supported.
+package gatewaytest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const ( // Payload Opcodes as specified by https://discord.com/developers/docs/topics/opcodes-and-status-codes#gateway-gateway-opcodes
+	OpDispatch       = 0
+	OpHeartbeat      = 1
+	OpIdentify       = 2
+	OpPresenceUpdate = 3
+	OpResume         = 6
+	OpReconnect      = 7
+	OpRequestMembers = 8
+	OpInvalidSession = 9
+	OpHello          = 10
+	OpHeartbeatAck   = 11
+)
+
+// DefaultTimeout is how long Server and Conn wait for the bot before giving up
+const DefaultTimeout = time.Second * 5
+
+var ErrTimeout = errors.New("[GatewayTest] timed out waiting for the bot")
+
+// Payload is a single gateway payload sent or received by the server
+type Payload struct {
+	Opcode      int             `json:"op"`
+	Data        json.RawMessage `json:"d"`
+	SequenceNum *int32          `json:"s"`
+	EventName   *string         `json:"t"`
+}
+
+// Server is a fake gateway accepting any number of connections. Every connection is handed to the test through Accept.
+type Server struct {
+	URL string // Websocket url of the server, pass this to the bot in place of discord's gateway url
+
+	// AckHeartbeats controls whether heartbeats are acknowledged automatically. Set it to false before the bot connects
+	// to simulate a zombied connection.
+	AckHeartbeats bool
+
+	http     *httptest.Server
+	conns    chan *Conn
+	sessions atomic.Int32
+}
+
+// NewServer starts a fake gateway. Call Close once the test is done with it.
+func NewServer() *Server {
+	server := &Server{AckHeartbeats: true, conns: make(chan *Conn, 16)}
+	server.http = httptest.NewServer(http.HandlerFunc(server.upgrade))
+	server.URL = "ws" + strings.TrimPrefix(server.http.URL, "http")
+	return server
+}
+
+// Close closes every connection and stops the server
+func (s *Server) Close() {
+	s.http.CloseClientConnections()
+	s.http.Close()
+}
+
+// Accept blocks until the bot opens a new connection, or DefaultTimeout has passed
+func (s *Server) Accept() (*Conn, error) {
+	select {
+	case conn := <-s.conns:
+		return conn, nil
+	case <-time.After(DefaultTimeout):
+		return nil, ErrTimeout
+	}
+}
+
+func (s *Server) upgrade(w http.ResponseWriter, r *http.Request) {
+	ws, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	conn := &Conn{
+		Query:    r.URL.Query(),
+		server:   s,
+		ws:       ws,
+		ack:      s.AckHeartbeats,
+		payloads: make(chan Payload, 64),
+		closed:   make(chan struct{}),
+	}
+	go conn.read()
+	s.conns <- conn
+}
+
+// Conn is a single connection opened by the bot
+type Conn struct {
+	Query map[string][]string // Query parameters the bot connected with
+
+	server  *Server
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+	ack     bool
+
+	sequence   int32
+	heartbeats atomic.Int32
+	payloads   chan Payload // Every payload except heartbeats, which are handled by read
+
+	closed    chan struct{}
+	closeCode int // Close code sent by the bot, only set once closed is closed
+	closeErr  error
+}
+
+// read handles every payload sent by the bot until the connection closes
+func (c *Conn) read() {
+	defer close(c.closed)
+	for {
+		_, msg, err := c.ws.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				c.closeCode = closeErr.Code
+			}
+			c.closeErr = err
+			return
+		}
+
+		var payload Payload
+		if err = json.Unmarshal(msg, &payload); err != nil {
+			c.closeErr = fmt.Errorf("bot sent an invalid payload: %w", err)
+			c.ws.Close()
+			return
+		}
+
+		if payload.Opcode == OpHeartbeat {
+			c.heartbeats.Add(1)
+			if c.ack {
+				_ = c.Send(Payload{Opcode: OpHeartbeatAck})
+			}
+			continue
+		}
+		c.payloads <- payload
+	}
+}
+
+// Send writes a raw payload to the bot
+func (c *Conn) Send(payload Payload) error {
+	if payload.Data == nil {
+		payload.Data = json.RawMessage("null")
+	}
+	enc, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.ws.WriteMessage(websocket.TextMessage, enc)
+}
+
+// SendOp writes a payload with the given opcode and data, which is encoded as json
+func (c *Conn) SendOp(opcode int, data any) error {
+	enc, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return c.Send(Payload{Opcode: opcode, Data: enc})
+}
+
+// Hello sends HELLO with the given heartbeat interval
+func (c *Conn) Hello(interval time.Duration) error {
+	return c.SendOp(OpHello, map[string]any{"heartbeat_interval": interval.Milliseconds()})
+}
+
+// Dispatch sends an event to the bot with the next sequence number and returns the sequence number used
+func (c *Conn) Dispatch(name string, data any) (int32, error) {
+	enc, err := json.Marshal(data)
+	if err != nil {
+		return 0, err
+	}
+	c.sequence++
+	sequence := c.sequence
+	return sequence, c.Send(Payload{Opcode: OpDispatch, Data: enc, SequenceNum: &sequence, EventName: &name})
+}
+
+// Ready dispatches READY for a new session. The session id is returned, and the server's own url is sent as the resume
+// url so resuming connects to the same server.
+func (c *Conn) Ready(guilds ...string) (string, error) {
+	sessionId := fmt.Sprintf("session-%d", c.server.sessions.Add(1))
+	unavailable := make([]map[string]any, 0, len(guilds))
+	for _, guild := range guilds {
+		unavailable = append(unavailable, map[string]any{"id": guild, "unavailable": true})
+	}
+
+	_, err := c.Dispatch("READY", map[string]any{
+		"v":                  10,
+		"user":               map[string]any{"id": "1", "username": "Elaina", "bot": true},
+		"guilds":             unavailable,
+		"session_id":         sessionId,
+		"resume_gateway_url": c.server.URL,
+		"application":        map[string]any{"id": "1"},
+	})
+	return sessionId, err
+}
+
+// Resumed dispatches RESUMED, continuing from the given sequence number
+func (c *Conn) Resumed(sequence int32) error {
+	c.sequence = sequence
+	_, err := c.Dispatch("RESUMED", map[string]any{})
+	return err
+}
+
+// Reconnect asks the bot to reconnect and resume
+func (c *Conn) Reconnect() error {
+	return c.Send(Payload{Opcode: OpReconnect})
+}
+
+// InvalidSession tells the bot its session is invalid, and whether it may resume it
+func (c *Conn) InvalidSession(resumable bool) error {
+	return c.SendOp(OpInvalidSession, resumable)
+}
+
+// CloseWith closes the connection with the given close code and reason
+func (c *Conn) CloseWith(code int, reason string) error {
+	c.writeMu.Lock()
+	err := c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+	c.ws.Close()
+	return err
+}
+
+// Next blocks until the bot sends a payload other than a heartbeat
+func (c *Conn) Next() (Payload, error) {
+	select {
+	case payload := <-c.payloads:
+		return payload, nil
+	case <-c.closed:
+		select { // Payloads sent right before closing are still delivered
+		case payload := <-c.payloads:
+			return payload, nil
+		default:
+			return Payload{}, c.closeErr
+		}
+	case <-time.After(DefaultTimeout):
+		return Payload{}, ErrTimeout
+	}
+}
+
+// Expect blocks until the bot sends a payload, and fails if its opcode isn't the given one. If v isn't nil, the
+// payload's data is decoded into it.
+func (c *Conn) Expect(opcode int, v any) error {
+	payload, err := c.Next()
+	if err != nil {
+		return err
+	}
+	if payload.Opcode != opcode {
+		return fmt.Errorf("expected opcode %d, got %d: %s", opcode, payload.Opcode, payload.Data)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(payload.Data, v)
+}
+
+// Handshake sends HELLO, waits for the bot to identify or resume and answers with READY or RESUMED. Returns the opcode
+// the bot sent, and the session id if a new session was started.
+func (c *Conn) Handshake(interval time.Duration) (opcode int, sessionId string, err error) {
+	if err = c.Hello(interval); err != nil {
+		return 0, "", err
+	}
+	payload, err := c.Next()
+	if err != nil {
+		return 0, "", err
+	}
+
+	switch payload.Opcode {
+	case OpIdentify:
+		sessionId, err = c.Ready()
+		return OpIdentify, sessionId, err
+	case OpResume:
+		var resume struct {
+			Sequence int32 `json:"seq"`
+		}
+		if err = json.Unmarshal(payload.Data, &resume); err != nil {
+			return 0, "", err
+		}
+		return OpResume, "", c.Resumed(resume.Sequence)
+	default:
+		return 0, "", fmt.Errorf("expected IDENTIFY or RESUME, got %d", payload.Opcode)
+	}
+}
+
+// Heartbeats returns the number of heartbeats the bot has sent on the connection
+func (c *Conn) Heartbeats() int {
+	return int(c.heartbeats.Load())
+}
+
+// WaitHeartbeats blocks until the bot has sent at least n heartbeats on the connection
+func (c *Conn) WaitHeartbeats(ctx context.Context, n int) error {
+	ticker := time.NewTicker(time.Millisecond * 5)
+	defer ticker.Stop()
+	for c.Heartbeats() < n {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.closed:
+			return c.closeErr
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// WaitClosed blocks until the bot closes the connection, then returns the close code it sent. If the connection dropped
+// without a close frame, 0 is returned.
+func (c *Conn) WaitClosed() (int, error) {
+	select {
+	case <-c.closed:
+		return c.closeCode, nil
+	case <-time.After(DefaultTimeout):
+		return 0, ErrTimeout
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	. "elaina-common"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+)
+
+const maxMemberRequestIds = 100 // Discord rejects requests for more user IDs than this
+
+var ErrShardNotReady = errors.New("[Gateway] shard is not ready")
+
+// MemberRequest describes the members to request from a guild with GatewayHandle.RequestGuildMembers. If UserIds is
+// empty, Query is used instead. An empty Query with a Limit of 0 requests every member of the guild, which requires
+// IntentGuildMembers. Presences requires IntentGuildPresences.
+// See: https://discord.com/developers/docs/events/gateway-events#request-guild-members
+type MemberRequest struct {
+	GuildId   Snowflake
+	Query     string      // Members whose username starts with Query are returned
+	Limit     int         // Max number of members to return when using Query, 0 for no limit
+	UserIds   []Snowflake // Max 100
+	Presences bool
+}
+
+// memberRequest is a member request waiting for its chunks to arrive. Chunks are delivered by the shard's reader, so
+// they are queued instead of waiting for the requester to take them.
+type memberRequest struct {
+	mu       sync.Mutex
+	chunks   []GuildMembersChunkPayload // Delivered chunks the requester hasn't taken yet
+	received chan struct{}              // Signalled when chunks are added
+}
+
+// take returns every chunk delivered since the last call
+func (r *memberRequest) take() []GuildMembersChunkPayload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	chunks := r.chunks
+	r.chunks = nil
+	return chunks
+}
+
+// memberRequests routes GUILD_MEMBERS_CHUNK events to the request they belong to, using the nonce of the request.
+type memberRequests struct {
+	mu      sync.Mutex
+	pending map[string]*memberRequest
+	nonce   uint64
+}
+
+func (r *memberRequests) add() (string, *memberRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending == nil {
+		r.pending = make(map[string]*memberRequest)
+	}
+	r.nonce++
+	nonce := strconv.FormatUint(r.nonce, 36)
+	request := &memberRequest{received: make(chan struct{}, 1)}
+	r.pending[nonce] = request
+	return nonce, request
+}
+
+func (r *memberRequests) remove(nonce string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, nonce)
+}
+
+// deliver hands the chunk to the request waiting for it without blocking. Chunks with an unknown nonce are dropped.
+func (r *memberRequests) deliver(chunk GuildMembersChunkPayload) {
+	r.mu.Lock()
+	request := r.pending[chunk.Nonce]
+	r.mu.Unlock()
+
+	if request == nil {
+		return
+	}
+	request.mu.Lock()
+	request.chunks = append(request.chunks, chunk)
+	request.mu.Unlock()
+
+	select {
+	case request.received <- struct{}{}:
+	default: // The requester hasn't caught up with the last signal yet, it will take this chunk along with it
+	}
+}
+
+// RequestGuildMembers sends a Request Guild Members payload and collects every chunk of the response. Use ctx to set
+// a timeout, as discord never responds to requests it considers invalid.
+func (h *GatewayHandle) RequestGuildMembers(ctx context.Context, request MemberRequest) ([]GuildMember, error) {
+	var members []GuildMember
+	err := h.StreamGuildMembers(ctx, request, func(chunk GuildMembersChunkPayload) error {
+		members = append(members, chunk.Members...)
+		return nil
+	})
+	return members, err
+}
+
+// StreamGuildMembers sends a Request Guild Members payload and calls handler for every chunk of the response as it
+// arrives. Chunks may arrive out of order. If handler returns an error, no more chunks are read and it is returned.
+// Chunks don't go through the event workers, so this can be called from an event handler of the same guild.
+func (h *GatewayHandle) StreamGuildMembers(ctx context.Context, request MemberRequest, handler func(GuildMembersChunkPayload) error) error {
+	shard := h.manager.shardFor(request.GuildId)
+	if err := shard.validateMemberRequest(request); err != nil {
+		return err
+	}
+
+	nonce, pending := h.manager.memberRequests.add()
+	defer h.manager.memberRequests.remove(nonce)
+
+	payload := requestGuildMembersPayload{
+		GuildId:   request.GuildId,
+		Limit:     request.Limit,
+		Presences: request.Presences,
+		UserIds:   request.UserIds,
+		Nonce:     nonce,
+	}
+	if len(request.UserIds) == 0 {
+		payload.Query = &request.Query
+	}
+
+	enc, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err = shard.sendPayloadContext(ctx, &gatewayPayload{Opcode: opRequestMembers, Data: (*json.RawMessage)(&enc)}); err != nil {
+		return err
+	}
+
+	received := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-pending.received:
+		}
+
+		for _, chunk := range pending.take() {
+			if err = handler(chunk); err != nil {
+				return err
+			}
+			if received++; received >= chunk.ChunkCount {
+				return nil
+			}
+		}
+	}
+}
+
+// validateMemberRequest checks request can be sent by this shard, as discord closes the connection for some invalid
+// requests and silently ignores others.
+func (gateway *gateway) validateMemberRequest(request MemberRequest) error {
+	if !gateway.ready.Load() {
+		return ErrShardNotReady
+	}
+	if len(request.UserIds) > maxMemberRequestIds {
+		return errors.New("can't request more than " + strconv.Itoa(maxMemberRequestIds) + " members by ID")
+	}
+	if request.Presences && gateway.options.intents&IntentGuildPresences == 0 {
+		return errors.New("requesting presences requires the guild presences intent")
+	}
+	if len(request.UserIds) == 0 && request.Query == "" && request.Limit == 0 && gateway.options.intents&IntentGuildMembers == 0 {
+		return errors.New("requesting every guild member requires the guild members intent")
+	}
+	return nil
+}
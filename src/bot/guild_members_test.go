@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"elaina-bot/gatewaytest"
+	. "elaina-common"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestShardManager(intents int) *shardManager {
+	options := gatewayOptions{intents: intents, encoding: jsonEncoding{}}
+	manager := newManager(1, 1, options)
+	manager.shards[0] = newGateway(manager, 0, 1, options, "")
+	return manager
+}
+
+// Tests that chunks of a member request are aggregated by nonce and returned once every chunk has arrived
+func TestRequestGuildMembers(t *testing.T) {
+	manager := newTestShardManager(IntentGuildMembers)
+	shard := manager.shards[0]
+	shard.ready.Store(true)
+	handle := &GatewayHandle{manager: manager}
+
+	go func() {
+		outgoing := <-shard.sendQueue
+		outgoing.report(0, nil)
+
+		var payload gatewayPayload
+		if !assert.NoError(t, json.Unmarshal(outgoing.data, &payload)) {
+			return // The request below fails once its context is done
+		}
+		assert.Equal(t, opRequestMembers, payload.Opcode)
+
+		var request requestGuildMembersPayload
+		if !assert.NoError(t, json.Unmarshal(*payload.Data, &request)) {
+			return
+		}
+
+		manager.memberRequests.deliver(GuildMembersChunkPayload{Nonce: "not-this-one", ChunkCount: 1, Members: []GuildMember{{Nick: "Saya"}}})
+		manager.memberRequests.deliver(GuildMembersChunkPayload{Nonce: request.Nonce, ChunkIndex: 1, ChunkCount: 2, Members: []GuildMember{{Nick: "Fran"}}})
+		manager.memberRequests.deliver(GuildMembersChunkPayload{Nonce: request.Nonce, ChunkIndex: 0, ChunkCount: 2, Members: []GuildMember{{Nick: "Elaina"}}})
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// TEST CASE: Both chunks with the matching nonce are collected, the other one is ignored
+	members, err := handle.RequestGuildMembers(ctx, MemberRequest{GuildId: 1})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []GuildMember{{Nick: "Fran"}, {Nick: "Elaina"}}, members)
+	assert.Empty(t, manager.memberRequests.pending)
+}
+
+// Tests that a member request gives up once its context is done and invalid requests are rejected before sending
+func TestRequestGuildMembersErrors(t *testing.T) {
+	manager := newTestShardManager(0)
+	handle := &GatewayHandle{manager: manager}
+
+	// TEST CASE: Shards which aren't ready can't send requests
+	_, err := handle.RequestGuildMembers(context.Background(), MemberRequest{GuildId: 1, Query: "ela"})
+	assert.ErrorIs(t, err, ErrShardNotReady)
+
+	// TEST CASE: Requesting every member without the members intent is rejected
+	manager.shards[0].ready.Store(true)
+	_, err = handle.RequestGuildMembers(context.Background(), MemberRequest{GuildId: 1})
+	assert.Error(t, err)
+
+	// TEST CASE: Requests time out when discord doesn't respond
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err = handle.RequestGuildMembers(ctx, MemberRequest{GuildId: 1, Query: "ela", Limit: 10})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, manager.memberRequests.pending)
+}
+
+// Tests that an event handler can request members of its own guild, whose chunks would otherwise be queued behind it
+func TestRequestGuildMembersFromHandler(t *testing.T) {
+	server := gatewaytest.NewServer()
+	defer server.Close()
+	handle, conn := startTestGateway(t, server)
+
+	results := make(chan []GuildMember, 1)
+	errs := make(chan error, 1)
+	registration := Events.CreateMessage.Register(func(ctx context.Context, payload CreateMessagePayload) error {
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		members, err := handle.RequestGuildMembers(ctx, MemberRequest{GuildId: payload.GuildId, Query: "ela", Limit: 10})
+		errs <- err
+		results <- members
+		return nil
+	})
+	defer registration.Unregister()
+
+	_, _, err := conn.Handshake(time.Second * 45)
+	require.NoError(t, err)
+	require.Eventually(t, handle.manager.shards[0].ready.Load, time.Second, time.Millisecond*5)
+
+	_, err = conn.Dispatch("MESSAGE_CREATE", map[string]any{"id": "10", "channel_id": "20", "guild_id": "30"})
+	require.NoError(t, err)
+
+	var request requestGuildMembersPayload
+	require.NoError(t, conn.Expect(gatewaytest.OpRequestMembers, &request))
+	_, err = conn.Dispatch("GUILD_MEMBERS_CHUNK", map[string]any{
+		"guild_id": "30", "nonce": request.Nonce, "chunk_index": 0, "chunk_count": 1,
+		"members": []map[string]any{{"nick": "Elaina"}},
+	})
+	require.NoError(t, err)
+
+	// TEST CASE: The chunk reaches the handler waiting for it on the guild's worker
+	require.NoError(t, <-errs)
+	assert.Equal(t, []GuildMember{{Nick: "Elaina"}}, <-results)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	. "elaina-common"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// baseIntents are always identified with, IntentGuilds keeps State up to date
+const baseIntents = IntentGuilds
+
+// privilegedIntents must be enabled for the application in the developer portal, and need approval once the bot is in
+// 100 or more guilds. Identifying with a disabled privileged intent closes the gateway with 4014.
+// https://discord.com/developers/docs/events/gateway#privileged-intents
+var privilegedIntents = []struct {
+	intent int
+	name   string
+}{
+	{IntentGuildMembers, "GUILD_MEMBERS"},
+	{IntentGuildPresences, "GUILD_PRESENCES"},
+	{IntentMessageContent, "MESSAGE_CONTENT"},
+}
+
+var ErrPrivilegedIntents = errors.New("registered handlers need privileged intents")
+
+var ErrMissingIntents = errors.New("the gateway didn't identify with the intents needed to receive the event")
+
+// identifiedIntents holds the intents the gateway identified with once it has been started, see missingIntents
+var identifiedIntents atomic.Pointer[int]
+
+// gatewayIntents returns the intents to identify with, baseIntents and the intents needed by every registered handler.
+// Handlers registered after the gateway connects only receive events covered by these intents, see missingIntents.
+func gatewayIntents() int {
+	return baseIntents | Events.requiredIntents()
+}
+
+// missingIntents returns the intents needed to receive the event, along with extra, which the gateway didn't identify
+// with. Returns 0 if the gateway hasn't been started yet, as its intents will include those of every registered handler.
+func (event *Event[T]) missingIntents(extra int) int {
+	identified := identifiedIntents.Load()
+	if identified == nil {
+		return 0
+	}
+	return (event.intents | extra) &^ *identified
+}
+
+// checkPrivilegedIntents warns about every privileged intent in intents, or returns ErrPrivilegedIntents naming them
+// if deny is set.
+func checkPrivilegedIntents(intents int, deny bool) error {
+	var names []string
+	for _, privileged := range privilegedIntents {
+		if intents&privileged.intent != 0 {
+			names = append(names, privileged.name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	if deny {
+		return fmt.Errorf("%w: %s", ErrPrivilegedIntents, strings.Join(names, ", "))
+	}
+	for _, name := range names {
+		slog.Warn("[Gateway] Identifying with a privileged intent, it must be enabled in the developer portal", slog.String("intent", name))
+	}
+	return nil
+}
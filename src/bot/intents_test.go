@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	. "elaina-common"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests that intents are only requested for events with handlers, including the intents added by WithIntents
+func TestRequiredIntents(t *testing.T) {
+	dispatcher := newEventDispatcher()
+	noop := func(ctx context.Context, payload ReactionAddPayload) error { return nil }
+
+	// TEST CASE: Built-in handlers don't request intents
+	assert.Zero(t, dispatcher.requiredIntents())
+
+	// TEST CASE: Registering a handler requests the event's intents
+	registration := dispatcher.ReactionAdd.Register(noop)
+	assert.Equal(t, IntentGuildMessageReactions, dispatcher.requiredIntents())
+
+	// TEST CASE: WithIntents adds to the event's intents, and is combined with other events
+	dispatcher.CreateMessage.Register(func(ctx context.Context, payload CreateMessagePayload) error { return nil }, WithIntents(IntentMessageContent))
+	assert.Equal(t, IntentGuildMessageReactions|IntentGuildMessages|IntentMessageContent, dispatcher.requiredIntents())
+
+	// TEST CASE: Unregistering the last handler stops requesting the event's intents
+	registration.Unregister()
+	assert.Equal(t, IntentGuildMessages|IntentMessageContent, dispatcher.requiredIntents())
+}
+
+// Tests that privileged intents are allowed unless denied
+func TestCheckPrivilegedIntents(t *testing.T) {
+	// TEST CASE: Intents which aren't privileged always pass
+	assert.NoError(t, checkPrivilegedIntents(IntentGuilds|IntentGuildMessages, true))
+
+	// TEST CASE: Privileged intents only warn by default
+	assert.NoError(t, checkPrivilegedIntents(IntentGuilds|IntentMessageContent, false))
+
+	// TEST CASE: Denied privileged intents fail, naming every privileged intent requested
+	err := checkPrivilegedIntents(IntentGuildMembers|IntentMessageContent, true)
+	assert.True(t, errors.Is(err, ErrPrivilegedIntents))
+	assert.ErrorContains(t, err, "GUILD_MEMBERS, MESSAGE_CONTENT")
+}
+
+// Tests that handlers and WaitFor calls added after the gateway started are checked against the identified intents
+func TestMissingIntents(t *testing.T) {
+	event := Event[testPayload]{Name: "TEST_EVENT", intents: IntentGuildMessages}
+	identified := baseIntents | IntentGuildMessages
+	defer identifiedIntents.Store(identifiedIntents.Load())
+
+	// TEST CASE: Nothing is missing before the gateway starts, as it will identify with every handler's intents
+	identifiedIntents.Store(nil)
+	assert.Zero(t, event.missingIntents(IntentMessageContent))
+
+	// TEST CASE: Intents the gateway didn't identify with are missing, including those added with WithIntents
+	identifiedIntents.Store(&identified)
+	assert.Zero(t, event.missingIntents(0))
+	assert.Equal(t, IntentMessageContent, event.missingIntents(IntentMessageContent))
+
+	// TEST CASE: WaitFor fails right away if the event's intents are missing
+	event.intents = IntentGuildMembers
+	_, err := event.WaitFor(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrMissingIntents)
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	. "elaina-common"
-	"encoding/json"
+	"elaina-common/restapi"
+	"time"
 )
 
 // https://discord.com/developers/docs/interactions/receiving-and-responding#interaction-response-object-interaction-callback-type
@@ -15,37 +16,34 @@ const (
 	RespTypeAutocomplete           = 8
 )
 
-func SendInteractionResponse(response InteractionResponse, id Snowflake, token string) error {
-	encResponse, err := json.Marshal(response)
-	if err != nil {
-		return err
-	}
-	resp, err := Post(ApiUrl("interactions", id.String(), token, "callback"), bytes.NewReader(encResponse))
-	if err != nil {
-		return err
-	}
-	_ = resp.Body.Close()
-	return nil
-}
+// interactionResponseWindow is how long after an interaction is created discord accepts its initial response
+const interactionResponseWindow = time.Second * 3
 
-func SendInteractionMessageResponse(message Message, id Snowflake, token string) error {
-	return SendInteractionResponse(InteractionResponse{Type: RespTypeChannelMessage, Data: message}, id, token)
+// interactionDeadline returns when discord stops accepting the initial response to the interaction handled in ctx. The
+// window is counted from when the shard received INTERACTION_CREATE rather than from the interaction's snowflake,
+// as the host's clock may be skewed from discord's. Returns false outside an event, as there is nothing to count from.
+func interactionDeadline(ctx context.Context) (time.Time, bool) {
+	received := getEventInfo(ctx).Received
+	return received.Add(interactionResponseWindow), !received.IsZero()
 }
 
-func EditInteractionResponse(content string, token string) error {
-	body, err := json.Marshal(struct {
-		Content string `json:"content"`
-	}{content})
-	if err != nil {
-		return err
+// SendInteractionResponse sends the initial response to an interaction. When called from the interaction's event, it
+// gives up once the response window has passed, as discord would reject it anyway; use a deferred response for anything
+// slower. It always gives up once ctx is done.
+func SendInteractionResponse(ctx context.Context, response InteractionResponse, id Snowflake, token string) error {
+	var options []restapi.RequestOption
+	if deadline, ok := interactionDeadline(ctx); ok {
+		options = append(options, restapi.WithDeadline(deadline))
 	}
+	return restapi.CreateInteractionResponse(ctx, id, token, response, options...)
+}
 
-	resp, err := Patch(ApiUrl("webhooks", CommonSecrets.Id, token, "messages", "@original"), body)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	return nil
+func SendInteractionMessageResponse(ctx context.Context, message Message, id Snowflake, token string) error {
+	return SendInteractionResponse(ctx, InteractionResponse{Type: RespTypeChannelMessage, Data: message}, id, token)
+}
+
+func EditInteractionResponse(ctx context.Context, content string, token string) error {
+	return restapi.EditOriginalInteractionResponse(ctx, token, content)
 }
 
 type InteractionResponse struct {
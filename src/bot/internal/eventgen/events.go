@@ -0,0 +1,560 @@
+package main
+
+// event declares a gateway event dispatched through the EventDispatcher, and the payload type it is decoded into.
+type event struct {
+	Field   string   // Name of the EventDispatcher field
+	Name    string   // Name discord sends the event with, also used to link to its documentation
+	Payload string   // Name of the payload type generated in elaina-common
+	Doc     string   // Payload doc comment, following the payload's name
+	Alias   string   // If set, the payload is an alias of this type and Fields is ignored
+	Fields  []string // Payload fields as "Name Type json_key // Comment", or "Type" to embed a type
+	Intents []string // Intents requested once the event has handlers. Events also sent in DMs only request the guild intent
+	Builtin []string // Built-in handlers, see builtin_event_handlers.go
+}
+
+// events is the catalogue of gateway events the bot can handle, grouped as in discord's documentation.
+// READY, RESUMED and GUILD_MEMBERS_CHUNK are handled by the gateway itself and aren't included.
+// https://discord.com/developers/docs/events/gateway-events#receive-events
+var events = []event{
+	// Messages
+	{
+		Field: "CreateMessage", Name: "MESSAGE_CREATE", Payload: "CreateMessagePayload",
+		Intents: []string{"IntentGuildMessages"},
+		Doc:     "is sent by discord when a message is created.",
+		Fields: []string{
+			"Message",
+			"GuildId Snowflake guild_id // Optional",
+			"Member *GuildMember member // Optional",
+			"Mentions []User mentions",
+		},
+	},
+	{
+		Field: "UpdateMessage", Name: "MESSAGE_UPDATE", Payload: "UpdateMessagePayload",
+		Intents: []string{"IntentGuildMessages"},
+		Doc:     "is sent by discord when a message is edited/updated.",
+		Fields: []string{
+			"Message",
+			"GuildId Snowflake guild_id // Optional",
+			"Member *GuildMember member // Optional",
+			"Mentions []User mentions",
+		},
+		Builtin: []string{"updateMessageEvent"},
+	},
+	{
+		Field: "DeleteMessage", Name: "MESSAGE_DELETE", Payload: "DeleteMessagePayload",
+		Intents: []string{"IntentGuildMessages"},
+		Doc:     "is sent by discord when a single message is deleted.",
+		Fields: []string{
+			"Id Snowflake id",
+			"ChannelId Snowflake channel_id",
+			"GuildId Snowflake guild_id // Optional",
+		},
+		Builtin: []string{"deleteMessageEvent"},
+	},
+	{
+		Field: "BulkDeleteMessage", Name: "MESSAGE_DELETE_BULK", Payload: "BulkDeleteMessagePayload",
+		Intents: []string{"IntentGuildMessages"},
+		Doc:     "is sent by discord when a multiple messages are deleted.",
+		Fields: []string{
+			"Ids []Snowflake ids",
+			"ChannelId Snowflake channel_id",
+			"GuildId Snowflake guild_id // Optional",
+		},
+	},
+	{
+		Field: "ReactionAdd", Name: "MESSAGE_REACTION_ADD", Payload: "ReactionAddPayload",
+		Intents: []string{"IntentGuildMessageReactions"},
+		Doc:     "is sent by discord when a user adds a reaction to a message.",
+		Fields: []string{
+			"UserId Snowflake user_id",
+			"ChannelId Snowflake channel_id",
+			"MessageId Snowflake message_id",
+			"GuildId Snowflake guild_id // Optional",
+			"Member *GuildMember member // Optional",
+			"Emoji Emoji emoji",
+			"MessageAuthorId Snowflake message_author_id // Optional",
+			"Burst bool burst",
+			"BurstColors []string burst_colors // Optional",
+			"Type int type",
+		},
+	},
+	{
+		Field: "ReactionRemove", Name: "MESSAGE_REACTION_REMOVE", Payload: "ReactionRemovePayload",
+		Intents: []string{"IntentGuildMessageReactions"},
+		Doc:     "is sent by discord when a user removes a reaction from a message.",
+		Fields: []string{
+			"UserId Snowflake user_id",
+			"ChannelId Snowflake channel_id",
+			"MessageId Snowflake message_id",
+			"GuildId Snowflake guild_id // Optional",
+			"Emoji Emoji emoji",
+			"Burst bool burst",
+			"Type int type",
+		},
+	},
+	{
+		Field: "ReactionRemoveAll", Name: "MESSAGE_REACTION_REMOVE_ALL", Payload: "ReactionRemoveAllPayload",
+		Intents: []string{"IntentGuildMessageReactions"},
+		Doc:     "is sent by discord when every reaction is removed from a message.",
+		Fields: []string{
+			"ChannelId Snowflake channel_id",
+			"MessageId Snowflake message_id",
+			"GuildId Snowflake guild_id // Optional",
+		},
+	},
+	{
+		Field: "ReactionRemoveEmoji", Name: "MESSAGE_REACTION_REMOVE_EMOJI", Payload: "ReactionRemoveEmojiPayload",
+		Intents: []string{"IntentGuildMessageReactions"},
+		Doc:     "is sent by discord when every reaction of a single emoji is removed from a message.",
+		Fields: []string{
+			"ChannelId Snowflake channel_id",
+			"GuildId Snowflake guild_id // Optional",
+			"MessageId Snowflake message_id",
+			"Emoji Emoji emoji // Partial",
+		},
+	},
+	{
+		Field: "AddPollVote", Name: "MESSAGE_POLL_VOTE_ADD", Payload: "AddPollVotePayload",
+		Intents: []string{"IntentGuildMessagePolls"},
+		Doc:     "is sent by discord when a user votes on a poll.",
+		Fields: []string{
+			"UserId Snowflake user_id",
+			"ChannelId Snowflake channel_id",
+			"MessageId Snowflake message_id",
+			"GuildId Snowflake guild_id // Optional",
+			"AnswerId int answer_id",
+		},
+	},
+	{
+		Field: "RemovePollVote", Name: "MESSAGE_POLL_VOTE_REMOVE", Payload: "RemovePollVotePayload",
+		Intents: []string{"IntentGuildMessagePolls"},
+		Doc:     "is sent by discord when a user removes their vote on a poll.",
+		Alias:   "AddPollVotePayload",
+	},
+	{
+		Field: "StartTyping", Name: "TYPING_START", Payload: "StartTypingPayload",
+		Intents: []string{"IntentGuildMessageTyping"},
+		Doc:     "is sent by discord when a user starts typing in a channel.",
+		Fields: []string{
+			"ChannelId Snowflake channel_id",
+			"GuildId Snowflake guild_id // Optional",
+			"UserId Snowflake user_id",
+			"Timestamp int64 timestamp // Unix time in seconds",
+			"Member *GuildMember member // Optional",
+		},
+	},
+	{
+		Field: "UpdateChannelPins", Name: "CHANNEL_PINS_UPDATE", Payload: "UpdateChannelPinsPayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a message is pinned or unpinned.",
+		Fields: []string{
+			"GuildId Snowflake guild_id // Optional",
+			"ChannelId Snowflake channel_id",
+			"LastPinTimestamp string last_pin_timestamp // Optional, nullable",
+		},
+	},
+
+	// Interactions
+	{
+		Field: "InteractionCreate", Name: "INTERACTION_CREATE", Payload: "InteractionCreatePayload",
+		Doc:     "is sent by discord when a user creates an interaction (e.g. via a slash command).",
+		Alias:   "Interaction",
+		Builtin: []string{"interactionCreateEvent"},
+	},
+
+	// Channels
+	{
+		Field: "CreateChannel", Name: "CHANNEL_CREATE", Payload: "CreateChannelPayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a guild channel is created.",
+		Alias:   "Channel",
+		Builtin: []string{"createChannelEvent"},
+	},
+	{
+		Field: "UpdateChannel", Name: "CHANNEL_UPDATE", Payload: "UpdateChannelPayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a guild channel is updated.",
+		Alias:   "Channel",
+		Builtin: []string{"updateChannelEvent"},
+	},
+	{
+		Field: "DeleteChannel", Name: "CHANNEL_DELETE", Payload: "DeleteChannelPayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a guild channel is deleted.",
+		Alias:   "Channel",
+		Builtin: []string{"deleteChannelEvent"},
+	},
+
+	// Threads
+	{
+		Field: "CreateThread", Name: "THREAD_CREATE", Payload: "CreateThreadPayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a thread is created, or the bot is added to a private thread.",
+		Fields: []string{
+			"Channel",
+			"NewlyCreated bool newly_created // Optional",
+		},
+		Builtin: []string{"createThreadEvent"},
+	},
+	{
+		Field: "UpdateThread", Name: "THREAD_UPDATE", Payload: "UpdateThreadPayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a thread is updated.",
+		Alias:   "Channel",
+		Builtin: []string{"updateThreadEvent"},
+	},
+	{
+		Field: "DeleteThread", Name: "THREAD_DELETE", Payload: "DeleteThreadPayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a thread is deleted.",
+		Fields: []string{
+			"Id Snowflake id",
+			"GuildId Snowflake guild_id",
+			"ParentId Snowflake parent_id",
+			"Type int type",
+		},
+		Builtin: []string{"deleteThreadEvent"},
+	},
+	{
+		Field: "SyncThreadList", Name: "THREAD_LIST_SYNC", Payload: "SyncThreadListPayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when the bot gains access to a channel, with the active threads in it.",
+		Fields: []string{
+			"GuildId Snowflake guild_id",
+			"ChannelIds []Snowflake channel_ids // Optional, every channel of the guild is being synced if missing",
+			"Threads []Channel threads",
+			"Members []ThreadMember members // Only the bot's own thread memberships",
+		},
+	},
+	{
+		Field: "UpdateThreadMember", Name: "THREAD_MEMBER_UPDATE", Payload: "UpdateThreadMemberPayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when the bot's thread membership is updated.",
+		Fields: []string{
+			"ThreadMember",
+			"GuildId Snowflake guild_id",
+		},
+	},
+	{
+		Field: "UpdateThreadMembers", Name: "THREAD_MEMBERS_UPDATE", Payload: "UpdateThreadMembersPayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when users are added to or removed from a thread. Requires IntentGuildMembers to receive other users.",
+		Fields: []string{
+			"Id Snowflake id",
+			"GuildId Snowflake guild_id",
+			"MemberCount int member_count // Stops counting at 50",
+			"AddedMembers []ThreadMember added_members // Optional",
+			"RemovedMemberIds []Snowflake removed_member_ids // Optional",
+		},
+	},
+
+	// Guilds
+	{
+		Field: "CreateGuild", Name: "GUILD_CREATE", Payload: "CreateGuildPayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when the bot connects, a guild becomes available again or the bot joins a guild.",
+		Fields: []string{
+			"Guild",
+			"Roles []Role roles",
+			"Unavailable bool unavailable // If true, none of the other fields are sent",
+			"MemberCount int member_count",
+			"Members []GuildMember members // Only includes every member with IntentGuildMembers",
+			"Channels []Channel channels",
+			"Threads []Channel threads",
+		},
+		Builtin: []string{"createGuildEvent"},
+	},
+	{
+		Field: "UpdateGuild", Name: "GUILD_UPDATE", Payload: "UpdateGuildPayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a guild is updated.",
+		Alias:   "Guild",
+		Builtin: []string{"updateGuildEvent"},
+	},
+	{
+		Field: "DeleteGuild", Name: "GUILD_DELETE", Payload: "DeleteGuildPayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a guild is created, becomes unavailable or the user leaves a guild.",
+		Alias:   "UnavailableGuild",
+		Builtin: []string{"deleteGuildEvent"},
+	},
+	{
+		Field: "AddGuildBan", Name: "GUILD_BAN_ADD", Payload: "AddGuildBanPayload",
+		Intents: []string{"IntentGuildModeration"},
+		Doc:     "is sent by discord when a user is banned from a guild.",
+		Fields: []string{
+			"GuildId Snowflake guild_id",
+			"User User user",
+		},
+	},
+	{
+		Field: "RemoveGuildBan", Name: "GUILD_BAN_REMOVE", Payload: "RemoveGuildBanPayload",
+		Intents: []string{"IntentGuildModeration"},
+		Doc:     "is sent by discord when a user is unbanned from a guild.",
+		Alias:   "AddGuildBanPayload",
+	},
+	{
+		Field: "UpdateGuildEmojis", Name: "GUILD_EMOJIS_UPDATE", Payload: "UpdateGuildEmojisPayload",
+		Intents: []string{"IntentGuildExpressions"},
+		Doc:     "is sent by discord when a guild's emojis are updated.",
+		Fields: []string{
+			"GuildId Snowflake guild_id",
+			"Emojis []Emoji emojis",
+		},
+	},
+	{
+		Field: "UpdateGuildStickers", Name: "GUILD_STICKERS_UPDATE", Payload: "UpdateGuildStickersPayload",
+		Intents: []string{"IntentGuildExpressions"},
+		Doc:     "is sent by discord when a guild's stickers are updated.",
+		Fields: []string{
+			"GuildId Snowflake guild_id",
+			"Stickers []Sticker stickers",
+		},
+	},
+	{
+		Field: "UpdateGuildIntegrations", Name: "GUILD_INTEGRATIONS_UPDATE", Payload: "UpdateGuildIntegrationsPayload",
+		Intents: []string{"IntentGuildIntegrations"},
+		Doc:     "is sent by discord when a guild's integrations are updated.",
+		Fields: []string{
+			"GuildId Snowflake guild_id",
+		},
+	},
+	{
+		Field: "CreateAuditLogEntry", Name: "GUILD_AUDIT_LOG_ENTRY_CREATE", Payload: "CreateAuditLogEntryPayload",
+		Intents: []string{"IntentGuildModeration"},
+		Doc:     "is sent by discord when an entry is added to a guild's audit log. Requires the VIEW_AUDIT_LOG permission.",
+		Fields: []string{
+			"AuditLogEntry",
+			"GuildId Snowflake guild_id",
+		},
+	},
+
+	// Roles
+	{
+		Field: "CreateRole", Name: "GUILD_ROLE_CREATE", Payload: "CreateRolePayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a guild role is created.",
+		Alias:   "UpdateRolePayload",
+		Builtin: []string{"createRoleEvent"},
+	},
+	{
+		Field: "UpdateRole", Name: "GUILD_ROLE_UPDATE", Payload: "UpdateRolePayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a guild role is updated.",
+		Fields: []string{
+			"GuildId Snowflake guild_id",
+			"Role Role role",
+		},
+		Builtin: []string{"updateRoleEvent"},
+	},
+	{
+		Field: "DeleteRole", Name: "GUILD_ROLE_DELETE", Payload: "DeleteRolePayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a guild role is deleted.",
+		Fields: []string{
+			"GuildId Snowflake guild_id",
+			"RoleId Snowflake role_id",
+		},
+		Builtin: []string{"deleteRoleEvent"},
+	},
+
+	// Members
+	{
+		Field: "AddGuildMember", Name: "GUILD_MEMBER_ADD", Payload: "AddGuildMemberPayload",
+		Intents: []string{"IntentGuildMembers"},
+		Doc:     "is sent by discord when a user joins a guild. Requires IntentGuildMembers.",
+		Fields: []string{
+			"GuildMember",
+			"GuildId Snowflake guild_id",
+		},
+		Builtin: []string{"addGuildMemberEvent"},
+	},
+	{
+		Field: "UpdateGuildMember", Name: "GUILD_MEMBER_UPDATE", Payload: "UpdateGuildMemberPayload",
+		Intents: []string{"IntentGuildMembers"},
+		Doc:     "is sent by discord when a guild member is updated. Requires IntentGuildMembers.",
+		Fields: []string{
+			"GuildMember",
+			"GuildId Snowflake guild_id",
+		},
+		Builtin: []string{"updateGuildMemberEvent"},
+	},
+	{
+		Field: "RemoveGuildMember", Name: "GUILD_MEMBER_REMOVE", Payload: "RemoveGuildMemberPayload",
+		Intents: []string{"IntentGuildMembers"},
+		Doc:     "is sent by discord when a user leaves or is removed from a guild. Requires IntentGuildMembers.",
+		Fields: []string{
+			"GuildId Snowflake guild_id",
+			"User User user",
+		},
+		Builtin: []string{"removeGuildMemberEvent"},
+	},
+	{
+		Field: "UpdatePresence", Name: "PRESENCE_UPDATE", Payload: "UpdatePresencePayload",
+		Intents: []string{"IntentGuildPresences"},
+		Doc:     "is sent by discord when a user's presence is updated, and as part of GuildMembersChunkPayload.",
+		Fields: []string{
+			"User User user // Partial, only the ID is guaranteed",
+			"GuildId Snowflake guild_id",
+			"Status string status",
+			"Activities []Activity activities",
+			"ClientStatus ClientStatus client_status",
+		},
+	},
+
+	// Scheduled events
+	{
+		Field: "CreateScheduledEvent", Name: "GUILD_SCHEDULED_EVENT_CREATE", Payload: "CreateScheduledEventPayload",
+		Intents: []string{"IntentGuildScheduledEvents"},
+		Doc:     "is sent by discord when a guild scheduled event is created.",
+		Alias:   "GuildScheduledEvent",
+	},
+	{
+		Field: "UpdateScheduledEvent", Name: "GUILD_SCHEDULED_EVENT_UPDATE", Payload: "UpdateScheduledEventPayload",
+		Intents: []string{"IntentGuildScheduledEvents"},
+		Doc:     "is sent by discord when a guild scheduled event is updated.",
+		Alias:   "GuildScheduledEvent",
+	},
+	{
+		Field: "DeleteScheduledEvent", Name: "GUILD_SCHEDULED_EVENT_DELETE", Payload: "DeleteScheduledEventPayload",
+		Intents: []string{"IntentGuildScheduledEvents"},
+		Doc:     "is sent by discord when a guild scheduled event is deleted.",
+		Alias:   "GuildScheduledEvent",
+	},
+	{
+		Field: "AddScheduledEventUser", Name: "GUILD_SCHEDULED_EVENT_USER_ADD", Payload: "AddScheduledEventUserPayload",
+		Intents: []string{"IntentGuildScheduledEvents"},
+		Doc:     "is sent by discord when a user subscribes to a guild scheduled event.",
+		Fields: []string{
+			"GuildScheduledEventId Snowflake guild_scheduled_event_id",
+			"UserId Snowflake user_id",
+			"GuildId Snowflake guild_id",
+		},
+	},
+	{
+		Field: "RemoveScheduledEventUser", Name: "GUILD_SCHEDULED_EVENT_USER_REMOVE", Payload: "RemoveScheduledEventUserPayload",
+		Intents: []string{"IntentGuildScheduledEvents"},
+		Doc:     "is sent by discord when a user unsubscribes from a guild scheduled event.",
+		Alias:   "AddScheduledEventUserPayload",
+	},
+
+	// Auto moderation
+	{
+		Field: "CreateAutoModerationRule", Name: "AUTO_MODERATION_RULE_CREATE", Payload: "CreateAutoModerationRulePayload",
+		Intents: []string{"IntentAutoModConfig"},
+		Doc:     "is sent by discord when an auto moderation rule is created.",
+		Alias:   "AutoModerationRule",
+	},
+	{
+		Field: "UpdateAutoModerationRule", Name: "AUTO_MODERATION_RULE_UPDATE", Payload: "UpdateAutoModerationRulePayload",
+		Intents: []string{"IntentAutoModConfig"},
+		Doc:     "is sent by discord when an auto moderation rule is updated.",
+		Alias:   "AutoModerationRule",
+	},
+	{
+		Field: "DeleteAutoModerationRule", Name: "AUTO_MODERATION_RULE_DELETE", Payload: "DeleteAutoModerationRulePayload",
+		Intents: []string{"IntentAutoModConfig"},
+		Doc:     "is sent by discord when an auto moderation rule is deleted.",
+		Alias:   "AutoModerationRule",
+	},
+	{
+		Field: "ExecuteAutoModerationAction", Name: "AUTO_MODERATION_ACTION_EXECUTION", Payload: "ExecuteAutoModerationActionPayload",
+		Intents: []string{"IntentAutoModExec"},
+		Doc:     "is sent by discord when an auto moderation rule is triggered and an action is executed.",
+		Fields: []string{
+			"GuildId Snowflake guild_id",
+			"Action AutoModerationAction action",
+			"RuleId Snowflake rule_id",
+			"RuleTriggerType int rule_trigger_type",
+			"UserId Snowflake user_id",
+			"ChannelId Snowflake channel_id // Optional",
+			"MessageId Snowflake message_id // Optional",
+			"AlertSystemMessageId Snowflake alert_system_message_id // Optional",
+			"Content string content // Requires IntentMessageContent",
+			"MatchedKeyword string matched_keyword // Nullable",
+			"MatchedContent string matched_content // Nullable, requires IntentMessageContent",
+		},
+	},
+
+	// Invites
+	{
+		Field: "CreateInvite", Name: "INVITE_CREATE", Payload: "CreateInvitePayload",
+		Intents: []string{"IntentGuildInvites"},
+		Doc:     "is sent by discord when an invite to a channel is created.",
+		Fields: []string{
+			"ChannelId Snowflake channel_id",
+			"Code string code",
+			"CreatedAt string created_at",
+			"GuildId Snowflake guild_id // Optional",
+			"Inviter *User inviter // Optional",
+			"MaxAge int max_age // Seconds, 0 if the invite never expires",
+			"MaxUses int max_uses",
+			"TargetType int target_type // Optional",
+			"TargetUser *User target_user // Optional",
+			"Temporary bool temporary",
+			"Uses int uses",
+			"ExpiresAt string expires_at // Nullable",
+		},
+	},
+	{
+		Field: "DeleteInvite", Name: "INVITE_DELETE", Payload: "DeleteInvitePayload",
+		Intents: []string{"IntentGuildInvites"},
+		Doc:     "is sent by discord when an invite is deleted.",
+		Fields: []string{
+			"ChannelId Snowflake channel_id",
+			"GuildId Snowflake guild_id // Optional",
+			"Code string code",
+		},
+	},
+
+	// Voice
+	{
+		Field: "UpdateVoiceState", Name: "VOICE_STATE_UPDATE", Payload: "UpdateVoiceStatePayload",
+		Intents: []string{"IntentGuildVoiceStates"},
+		Doc:     "is sent by discord when a user joins, leaves or moves between voice channels, or their voice state changes.",
+		Alias:   "VoiceState",
+	},
+	{
+		Field: "UpdateVoiceServer", Name: "VOICE_SERVER_UPDATE", Payload: "UpdateVoiceServerPayload",
+		Doc: "is sent by discord when a guild's voice server changes.",
+		Fields: []string{
+			"Token string token",
+			"GuildId Snowflake guild_id",
+			"Endpoint string endpoint // Nullable, the voice server is unavailable if null",
+		},
+	},
+	{
+		Field: "CreateStageInstance", Name: "STAGE_INSTANCE_CREATE", Payload: "CreateStageInstancePayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a stage instance is created.",
+		Alias:   "StageInstance",
+	},
+	{
+		Field: "UpdateStageInstance", Name: "STAGE_INSTANCE_UPDATE", Payload: "UpdateStageInstancePayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a stage instance is updated.",
+		Alias:   "StageInstance",
+	},
+	{
+		Field: "DeleteStageInstance", Name: "STAGE_INSTANCE_DELETE", Payload: "DeleteStageInstancePayload",
+		Intents: []string{"IntentGuilds"},
+		Doc:     "is sent by discord when a stage instance is deleted or closed.",
+		Alias:   "StageInstance",
+	},
+
+	// Misc
+	{
+		Field: "UpdateWebhooks", Name: "WEBHOOKS_UPDATE", Payload: "UpdateWebhooksPayload",
+		Intents: []string{"IntentGuildWebhooks"},
+		Doc:     "is sent by discord when a channel's webhooks are created, updated or deleted.",
+		Fields: []string{
+			"GuildId Snowflake guild_id",
+			"ChannelId Snowflake channel_id",
+		},
+	},
+	{
+		Field: "UpdateUser", Name: "USER_UPDATE", Payload: "UpdateUserPayload",
+		Doc:   "is sent by discord when the bot's user is updated.",
+		Alias: "User",
+	},
+}
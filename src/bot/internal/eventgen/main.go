@@ -0,0 +1,174 @@
+// Command eventgen generates the gateway event payloads of elaina-common, and the EventDispatcher dispatching them,
+// from the catalogue in events.go. It is run by go generate from the bot's directory.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+)
+
+const docsUrl = "https://discord.com/developers/docs/events/gateway-events#"
+
+func main() {
+	dispatcherPath := flag.String("dispatcher", "events_gen.go", "path of the generated EventDispatcher")
+	payloadsPath := flag.String("payloads", "../common/payloads_gen.go", "path of the generated payload types")
+	flag.Parse()
+
+	if err := generate(*dispatcherPath, renderDispatcher); err != nil {
+		log.Fatal(err)
+	}
+	if err := generate(*payloadsPath, renderPayloads); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func generate(path string, render func() ([]byte, error)) error {
+	src, err := render()
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return os.WriteFile(path, src, 0644)
+}
+
+// field is a parsed payload field, see event.Fields
+type field struct {
+	Name    string // Empty for embedded types
+	Type    string
+	Json    string
+	Comment string
+}
+
+// parseField parses a field declared as "Name Type json_key // Comment" or "Type"
+func parseField(decl string) (field, error) {
+	var f field
+	decl, f.Comment, _ = strings.Cut(decl, "//")
+	f.Comment = strings.TrimSpace(f.Comment)
+
+	parts := strings.Fields(decl)
+	switch len(parts) {
+	case 1:
+		f.Type = parts[0]
+	case 3:
+		f.Name, f.Type, f.Json = parts[0], parts[1], parts[2]
+	default:
+		return f, fmt.Errorf("invalid field %q", decl)
+	}
+	return f, nil
+}
+
+func (e event) DocsUrl() string {
+	return docsUrl + strings.ReplaceAll(strings.ToLower(e.Name), "_", "-")
+}
+
+func (e event) ParsedFields() ([]field, error) {
+	fields := make([]field, len(e.Fields))
+	for i, decl := range e.Fields {
+		f, err := parseField(decl)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Payload, err)
+		}
+		fields[i] = f
+	}
+	return fields, nil
+}
+
+var payloadsTemplate = template.Must(template.New("payloads").Parse(`// Code generated by eventgen from bot/internal/eventgen/events.go. DO NOT EDIT.
+
+package common
+{{range .}}
+// {{.Payload}} {{.Doc}}
+// {{.DocsUrl}}
+{{- if .Alias}}
+type {{.Payload}} = {{.Alias}}
+{{else}}
+type {{.Payload}} struct {
+{{- range .ParsedFields}}
+	{{if .Name}}{{.Name}} {{.Type}} ` + "`json:\"{{.Json}}\"`" + `{{else}}{{.Type}}{{end}}{{if .Comment}} // {{.Comment}}{{end}}
+{{- end}}
+}
+{{end}}{{end}}`))
+
+var dispatcherTemplate = template.Must(template.New("dispatcher").Parse(`// Code generated by eventgen from internal/eventgen/events.go. DO NOT EDIT.
+
+package main
+
+import (
+	"context"
+	. "elaina-common"
+	"sync"
+	"time"
+)
+
+type EventDispatcher struct {
+	middleware []Middleware
+	mutex      sync.RWMutex // Guards middleware, which can change while events are dispatched
+
+	Raw     Event[RawEvent] // Receives every event before it is handled, including those handled by the gateway
+	Unknown Event[RawEvent] // Receives events which aren't in the catalogue, such as newly released events
+{{range .}}
+	{{.Field}} Event[{{.Payload}}]
+{{- end}}
+}
+
+// newEventDispatcher returns an EventDispatcher with every event named, and with its built-in handlers
+func newEventDispatcher() *EventDispatcher {
+	return &EventDispatcher{
+		Raw:     Event[RawEvent]{Name: "RAW"},
+		Unknown: Event[RawEvent]{Name: "UNKNOWN"},
+{{- range .}}
+		{{.Field}}: Event[{{.Payload}}]{Name: "{{.Name}}"
+		{{- if .Intents}}, intents: {{range $i, $intent := .Intents}}{{if $i}} | {{end}}{{$intent}}{{end}}{{end}}
+		{{- if .Builtin}}, builtin: []EventHandler[{{.Payload}}]{ {{- range $i, $h := .Builtin}}{{if $i}}, {{end}}{{$h}}{{end -}} }{{end -}} },
+{{- end}}
+	}
+}
+
+// requiredIntents returns the intents needed by every event with handlers, see Event.requiredIntents
+func (dispatcher *EventDispatcher) requiredIntents() int {
+	return dispatcher.Raw.requiredIntents() |
+		dispatcher.Unknown.requiredIntents()
+{{- range .}} |
+		dispatcher.{{.Field}}.requiredIntents()
+{{- end}}
+}
+
+// eventDispatchers maps the name of every event to the function dispatching it, see Event.dispatch
+var eventDispatchers = map[string]eventDispatchFunc{
+{{- range .}}
+	"{{.Name}}": func(dispatcher *EventDispatcher, ctx context.Context, raw []byte, encoding gatewayEncoding, timeout time.Duration) {
+		dispatcher.{{.Field}}.dispatch(ctx, raw, encoding, timeout, dispatcher.globalMiddleware())
+	},
+{{- end}}
+}
+
+// eventWaiters maps the name of every event to the function passing it to WaitFor, see Event.deliverWaiters
+var eventWaiters = map[string]eventWaitFunc{
+{{- range .}}
+	"{{.Name}}": func(dispatcher *EventDispatcher, raw []byte, encoding gatewayEncoding) error {
+		return dispatcher.{{.Field}}.deliverWaiters(raw, encoding)
+	},
+{{- end}}
+}
+`))
+
+func render(tmpl *template.Template) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, events); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+func renderPayloads() ([]byte, error) {
+	return render(payloadsTemplate)
+}
+
+func renderDispatcher() ([]byte, error) {
+	return render(dispatcherTemplate)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests that every declared event, field and payload is unique and every payload field can be parsed
+func TestEventsUnique(t *testing.T) {
+	names := make(map[string]bool)
+	fields := make(map[string]bool)
+	payloads := make(map[string]bool)
+
+	// TEST CASE: No event name, EventDispatcher field or payload type is declared twice
+	for _, e := range events {
+		assert.False(t, names[e.Name], "duplicate event name %s", e.Name)
+		assert.False(t, fields[e.Field], "duplicate field %s", e.Field)
+		assert.False(t, payloads[e.Payload], "duplicate payload %s", e.Payload)
+		names[e.Name], fields[e.Field], payloads[e.Payload] = true, true, true
+	}
+
+	// TEST CASE: Payloads are either an alias or a struct with valid fields
+	for _, e := range events {
+		if e.Alias != "" {
+			assert.Empty(t, e.Fields, "%s is an alias but declares fields", e.Payload)
+			continue
+		}
+		assert.NotEmpty(t, e.Fields, "%s has no fields", e.Payload)
+		_, err := e.ParsedFields()
+		assert.NoError(t, err)
+	}
+}
+
+// Tests that the generated files match the catalogue, so go generate was run after it was changed
+func TestGeneratedUpToDate(t *testing.T) {
+	for path, render := range map[string]func() ([]byte, error){
+		"../../events_gen.go":             renderDispatcher,
+		"../../../common/payloads_gen.go": renderPayloads,
+	} {
+		// TEST CASE: The file on disk is what eventgen would write
+		want, err := render()
+		require.NoError(t, err)
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(got), "%s is out of date, run go generate", path)
+	}
+}
@@ -1,21 +1,26 @@
 package main
 
 import (
+	"context"
 	. "elaina-common"
 	"flag"
 	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
+	"sync/atomic"
 	"syscall"
 )
 
-const intents = IntentGuildMessages | IntentMessageContent
+// gatewayHandle is set once the gateway has been started in bot mode. Handlers may already be running by then, so it
+// is read atomically and may be nil.
+var gatewayHandle atomic.Pointer[GatewayHandle]
 
 var botSecrets struct {
 	dbUser     string
 	dbPassword string
 	dbAddress  string
+	production bool // Whether the secrets are the production ones, rather than the development ones of ELAINA_DEBUG
 }
 
 func main() {
@@ -28,14 +33,20 @@ func main() {
 	registerEvents()
 	registerCommands()
 
-	deploy := flag.String("mode", "", "Update the running mode:\n- deploy_commands: Deploys application toDeploy\n- deploy_db: Deploys/updates database schemas")
+	deploy := flag.String("mode", "", "Update the running mode:\n- deploy_commands: Deploys application toDeploy\n- deploy_db: Deploys/updates database schemas\n- bot: Runs the bot\n- replay: Replays the events recorded in --replay, with REST requests sent to a stub")
 	commands := flag.String("commands", "", "A comma separated list of commands to deploy")
+	compress := flag.Bool("compress", false, "Enables zlib-stream transport compression for the gateway connection")
+	encoding := flag.String("encoding", ApiEncoding, "Encoding used for gateway payloads: json or etf")
+	record := flag.String("record", "", "Appends every gateway event received to the given file as newline-delimited JSON")
+	replay := flag.String("replay", "", "The recording replayed by --mode=replay")
+	sessionStoreName := flag.String("session-store", "", "Saves gateway sessions on shutdown to resume them on the next start: file or db. Disabled if empty")
+	denyPrivileged := flag.Bool("deny-privileged-intents", false, "Fails to start if registered handlers need privileged intents, instead of warning about them")
 	flag.Parse()
 
 	switch *deploy {
 	case "deploy_commands":
 		if *commands == "" {
-			DeployCommands(Commands)
+			DeployCommands(context.Background(), Commands)
 			return
 		}
 		names := strings.Split(*commands, ",")
@@ -49,7 +60,7 @@ func main() {
 			}
 			toDeploy = append(toDeploy, cmd)
 		}
-		DeployCommands(toDeploy) // TODO: Both the deploy command and deploy db functions are fundamentally incompatible with sharding. These should be built into a separate util application
+		DeployCommands(context.Background(), toDeploy) // TODO: Both the deploy command and deploy db functions are fundamentally incompatible with sharding. These should be built into a separate util application
 	case "deploy_db":
 		DeployDatabase(botSecrets.dbUser, botSecrets.dbPassword, botSecrets.dbAddress)
 	case "bot":
@@ -58,7 +69,54 @@ func main() {
 		db := ConnectDatabase(botSecrets.dbUser, botSecrets.dbPassword, botSecrets.dbAddress)
 		defer db.Close()
 
-		handle := listenGateway(intents)
+		gatewayEncoding, err := getGatewayEncoding(*encoding)
+		if err != nil {
+			slog.Error("[Elaina] Failed to start gateway: " + err.Error())
+			return
+		}
+
+		sessions, err := getSessionStore(*sessionStoreName)
+		if err != nil {
+			slog.Error("[Elaina] Failed to start gateway: " + err.Error())
+			return
+		}
+
+		intents := gatewayIntents()
+		if err := checkPrivilegedIntents(intents, *denyPrivileged); err != nil {
+			slog.Error("[Elaina] Failed to start gateway: " + err.Error())
+			return
+		}
+
+		options := gatewayOptions{
+			intents:         intents,
+			compress:        *compress,
+			encoding:        gatewayEncoding,
+			presence:        initialPresence(),
+			sessions:        sessions,
+			handlerTimeout:  getConfigSeconds(HandlerTimeout),
+			shutdownTimeout: getConfigSeconds(ShutdownTimeout),
+		}
+		if *record != "" {
+			recorder, err := newEventRecorder(*record)
+			if err != nil {
+				slog.Error("[Elaina] Failed to open event recording: " + err.Error())
+				return
+			}
+			defer recorder.Close()
+			options.recorder = recorder
+		}
+
+		handle, err := listenGateway(options)
+		if err != nil {
+			slog.Error("[Elaina] Failed to start gateway: " + err.Error())
+			return
+		}
+
+		gatewayHandle.Store(handle)
+
+		stopPresence := make(chan struct{})
+		defer close(stopPresence)
+		go rotatePresence(handle, stopPresence)
 
 		// Wait for a SIGINT or SIGTERM signal to gracefully shut down
 		sigChan := make(chan os.Signal, 1)
@@ -78,6 +136,26 @@ func main() {
 				return
 			}
 		}
+	case "replay":
+		if *replay == "" {
+			slog.Error("[Elaina] No recording given to replay, use --replay")
+			return
+		}
+		if botSecrets.production {
+			// Replayed handlers write to the database, which must not change data of the running bot
+			slog.Error("[Elaina] Refusing to replay against the production database, set ELAINA_DEBUG=true to use the development database")
+			return
+		}
+		db := ConnectDatabase(botSecrets.dbUser, botSecrets.dbPassword, botSecrets.dbAddress)
+		defer db.Close()
+		SetHttpTransport(restStub{})
+
+		count, err := replayEvents(newReplayShard(), *replay)
+		if err != nil {
+			slog.Error("[Elaina] Failed to replay events: " + err.Error())
+			return
+		}
+		slog.Info("[Elaina] Replay finished:", slog.Int("events", count))
 	default:
 		slog.Error("[Elaina] Unknown execution mode: " + *deploy)
 	}
@@ -85,7 +163,7 @@ func main() {
 
 func registerCommands() {
 	Commands = []*ApplicationCommand{
-		&echoCommand, &macroCommand, &editMacroCommand, &honeypotCommand, &banCommand, &unbanCommand, &timeoutCommand,
+		&echoCommand, &pingCommand, &macroCommand, &editMacroCommand, &honeypotCommand, &banCommand, &unbanCommand, &timeoutCommand,
 	}
 }
 
@@ -107,6 +185,7 @@ func loadSecrets() {
 		botSecrets.dbUser = dockerSecret("elaina-db-username")
 		botSecrets.dbPassword = dockerSecret("elaina-db-password")
 		botSecrets.dbAddress = dockerSecret("elaina-db-address")
+		botSecrets.production = true
 	}
 }
 
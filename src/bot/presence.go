@@ -0,0 +1,123 @@
+package main
+
+import (
+	. "elaina-common"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const minStatusInterval = time.Second * 15 // Presence updates count towards the gateway send limit, don't spam them
+
+var activityTypeNames = map[string]ActivityType{
+	"playing":   ActivityPlaying,
+	"listening": ActivityListening,
+	"watching":  ActivityWatching,
+	"custom":    ActivityCustom,
+	"competing": ActivityCompeting,
+}
+
+// statusEntry is a single status of the StatusRotation config value
+type statusEntry struct {
+	Type ActivityType
+	Text string
+}
+
+// parseStatusRotation parses a semicolon separated list of "type:text" statuses, e.g. "watching:{guilds} guilds".
+func parseStatusRotation(value string) ([]statusEntry, error) {
+	var entries []statusEntry
+	for _, part := range strings.Split(value, ";") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+
+		name, text, found := strings.Cut(part, ":")
+		if !found {
+			return nil, errors.New("status is missing an activity type: \"" + part + "\"")
+		}
+		activityType, exists := activityTypeNames[strings.ToLower(strings.TrimSpace(name))]
+		if !exists {
+			return nil, errors.New("unknown activity type: \"" + name + "\"")
+		}
+		entries = append(entries, statusEntry{Type: activityType, Text: strings.TrimSpace(text)})
+	}
+	return entries, nil
+}
+
+// presence creates the PresenceUpdate showing this status, replacing {guilds} with the given guild count.
+func (s statusEntry) presence(guilds int) PresenceUpdate {
+	text := strings.ReplaceAll(s.Text, "{guilds}", strconv.Itoa(guilds))
+
+	activity := Activity{Name: text, Type: s.Type}
+	if s.Type == ActivityCustom { // Custom statuses display State instead of Name
+		activity = Activity{Name: "Custom Status", Type: s.Type, State: text}
+	}
+	return PresenceUpdate{Status: StatusOnline, Activities: []Activity{activity}}
+}
+
+// initialPresence returns the presence to identify with. The guild count isn't known before READY, so the first
+// status not depending on it is used.
+func initialPresence() *PresenceUpdate {
+	entries, err := parseStatusRotation(getConfig(StatusRotation))
+	if err != nil {
+		slog.Error("[Elaina] Invalid status rotation config: " + err.Error())
+		return nil
+	}
+	for _, entry := range entries {
+		if !strings.Contains(entry.Text, "{guilds}") {
+			presence := entry.presence(0)
+			return &presence
+		}
+	}
+	return nil
+}
+
+// statusRotation renders the configured statuses in turn. The guild count is read each time a status is rendered, so
+// it follows guilds being joined and left.
+type statusRotation struct {
+	entries    []statusEntry
+	next       int
+	guildCount func() int
+}
+
+// advance returns the presence for the next status, wrapping around after the last one.
+func (r *statusRotation) advance() PresenceUpdate {
+	presence := r.entries[r.next].presence(r.guildCount())
+	r.next = (r.next + 1) % len(r.entries)
+	return presence
+}
+
+// rotatePresence cycles through the statuses in the StatusRotation config value until stop is closed. {guilds} shows
+// the number of guilds in State.
+func rotatePresence(handle *GatewayHandle, stop <-chan struct{}) {
+	entries, err := parseStatusRotation(getConfig(StatusRotation))
+	if err != nil {
+		slog.Error("[Elaina] Invalid status rotation config: " + err.Error())
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	seconds, err := strconv.Atoi(getConfig(StatusInterval))
+	if err != nil {
+		slog.Error("[Elaina] Invalid status interval config: " + err.Error())
+		return
+	}
+	interval := max(time.Second*time.Duration(seconds), minStatusInterval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	rotation := statusRotation{entries: entries, guildCount: State.GuildCount}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			handle.SetPresence(rotation.advance())
+		}
+	}
+}
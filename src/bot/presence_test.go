@@ -0,0 +1,49 @@
+package main
+
+import (
+	. "elaina-common"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests that status rotation config values are parsed and rendered into presences
+func TestStatusRotation(t *testing.T) {
+	entries, err := parseStatusRotation("watching:{guilds} guilds; custom:Travelling the world;")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	// TEST CASE: {guilds} is replaced with the guild count
+	presence := entries[0].presence(42)
+	assert.Equal(t, StatusOnline, presence.Status)
+	assert.Equal(t, Activity{Name: "42 guilds", Type: ActivityWatching}, presence.Activities[0])
+
+	// TEST CASE: Custom statuses put their text in State
+	presence = entries[1].presence(42)
+	assert.Equal(t, Activity{Name: "Custom Status", Type: ActivityCustom, State: "Travelling the world"}, presence.Activities[0])
+
+	// TEST CASE: Unknown activity types and missing types are rejected
+	_, err = parseStatusRotation("sleeping:zzz")
+	assert.Error(t, err)
+	_, err = parseStatusRotation("no type here")
+	assert.Error(t, err)
+}
+
+// Tests that rotating statuses show the current guild count as guilds are joined and left
+func TestStatusRotationGuildCount(t *testing.T) {
+	entries, err := parseStatusRotation("watching:{guilds} guilds")
+	require.NoError(t, err)
+	state := NewGuildState()
+	rotation := statusRotation{entries: entries, guildCount: state.GuildCount}
+
+	// TEST CASE: The count is read each time the status is rendered
+	state.AddGuild(CreateGuildPayload{Guild: Guild{Id: 1}})
+	assert.Equal(t, "1 guilds", rotation.advance().Activities[0].Name)
+
+	state.AddGuild(CreateGuildPayload{Guild: Guild{Id: 2}})
+	assert.Equal(t, "2 guilds", rotation.advance().Activities[0].Name)
+
+	state.RemoveGuild(1)
+	assert.Equal(t, "1 guilds", rotation.advance().Activities[0].Name)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	. "elaina-common"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// recordedEvent is a single dispatch written by eventRecorder. Recordings are newline-delimited JSON, one event per line.
+type recordedEvent struct {
+	Shard     int             `json:"shard"`
+	Name      string          `json:"name"`
+	Sequence  int32           `json:"sequence"`
+	Timestamp time.Time       `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// eventRecorder writes every dispatch received by the gateway to a file, so misbehaving event handlers can be
+// reproduced with replayEvents. It is safe to use from every shard at once.
+type eventRecorder struct {
+	mu     sync.Mutex
+	file   *os.File
+	enc    *json.Encoder
+	failed bool // Only the first write error is logged, so a full disk doesn't flood the logs
+}
+
+// newEventRecorder opens the recording at path, appending to it if it already exists.
+func newEventRecorder(path string) (*eventRecorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0660)
+	if err != nil {
+		return nil, err
+	}
+	return &eventRecorder{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// record writes a dispatch to the recording. Failing to write is logged rather than returned, as it shouldn't stop the
+// event from being handled.
+func (r *eventRecorder) record(shard int, name string, sequence int32, payload []byte) {
+	event := recordedEvent{Shard: shard, Name: name, Sequence: sequence, Timestamp: time.Now(), Payload: payload}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(event); err != nil && !r.failed {
+		r.failed = true
+		slog.Error("[Recorder] Failed to record event: " + err.Error())
+	}
+}
+
+// recordEvent writes a dispatch received by the shard to its recorder. Recordings are always JSON, so payloads received
+// as ETF are transcoded first.
+func (gateway *gateway) recordEvent(name string, sequence int32, data []byte) {
+	payload, err := gateway.options.encoding.toJson(data)
+	if err != nil {
+		gateway.logger.Error("[Recorder] Failed to record event: " + err.Error())
+		return
+	}
+	gateway.options.recorder.record(gateway.shardId, name, sequence, payload)
+}
+
+// Close closes the recording file. Events recorded after closing are dropped.
+func (r *eventRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failed = true // The file is closed, so further writes are expected to fail
+	return r.file.Close()
+}
+
+// readRecording calls handler with every event in the recording at path, in the order they were recorded.
+func readRecording(path string, handler func(event recordedEvent) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dec := json.NewDecoder(file)
+	for {
+		var event recordedEvent
+		if err = dec.Decode(&event); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err = handler(event); err != nil {
+			return err
+		}
+	}
+}
+
+// replayEvents feeds every event in the recording at path through shard's interceptEvent and dispatchEvent. Events are dispatched one at
+// a time, in the order they were recorded, so a replay always runs handlers the same way. Returns the number of events
+// replayed.
+func replayEvents(shard *gateway, path string) (int, error) {
+	count := 0
+	err := readRecording(path, func(event recordedEvent) error {
+		shard.sequence.Store(event.Sequence)
+		shard.interceptEvent(event.Name, event.Payload)
+		shard.dispatchEvent(event.Name, event.Payload, time.Now())
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// newReplayShard creates a shard which is never connected, for events to be replayed on.
+func newReplayShard() *gateway {
+	options := gatewayOptions{intents: gatewayIntents(), encoding: jsonEncoding{}}
+	State.SetMemberCaching(options.intents&IntentGuildMembers != 0)
+	manager := newManager(1, 1, options)
+	manager.shards[0] = newGateway(manager, 0, 1, options, "")
+	return manager.shards[0]
+}
+
+// restStub answers every HTTP request with an empty successful response instead of sending it, so handlers can be
+// replayed without calling discord. Each request is logged so the calls a handler would have made can be inspected.
+// Responses carry rate limit headers for a bucket per route which is never exhausted, so the rate limiter learns
+// buckets like it does against discord.
+type restStub struct{}
+
+func (restStub) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+	}
+	slog.Info("[Replay] REST request:", slog.String("method", req.Method), slog.String("url", req.URL.String()), slog.String("body", string(body)))
+
+	resp := &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     restStubHeaders(req),
+		Body:       io.NopCloser(bytes.NewReader([]byte("{}"))),
+		Request:    req,
+	}
+	if req.Method == http.MethodDelete || req.Method == http.MethodPut {
+		resp.Status = "204 No Content"
+		resp.StatusCode = http.StatusNoContent
+		resp.Body = io.NopCloser(bytes.NewReader(nil))
+	}
+	return resp, nil
+}
+
+// restStubHeaders returns the headers of a stubbed response to req, with rate limits for a bucket named after its
+// method and path.
+func restStubHeaders(req *http.Request) http.Header {
+	bucket := fnv.New64a()
+	bucket.Write([]byte(req.Method + " " + req.URL.Path))
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("X-RateLimit-Bucket", strconv.FormatUint(bucket.Sum64(), 16))
+	header.Set("X-RateLimit-Limit", "50")
+	header.Set("X-RateLimit-Remaining", "49")
+	header.Set("X-RateLimit-Reset-After", "1.000")
+	header.Set("X-RateLimit-Scope", "user")
+	return header
+}
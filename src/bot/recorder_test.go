@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests that recorded events are read back in order, and that replaying them dispatches each one
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	recorder, err := newEventRecorder(path)
+	require.NoError(t, err)
+
+	ready := `{"v":10,"user":{"id":"1"},"guilds":[{"id":"2","unavailable":true}],"session_id":"abc","resume_gateway_url":"wss://resume.discord.gg"}`
+	recorder.record(0, "READY", 1, []byte(ready))
+	recorder.record(0, "TYPING_START", 2, []byte(`{"channel_id":"3"}`))
+	require.NoError(t, recorder.Close())
+
+	// TEST CASE: Events are read back in the order they were recorded with their payloads unchanged
+	var events []recordedEvent
+	require.NoError(t, readRecording(path, func(event recordedEvent) error {
+		events = append(events, event)
+		return nil
+	}))
+	require.Len(t, events, 2)
+	assert.Equal(t, "READY", events[0].Name)
+	assert.EqualValues(t, 1, events[0].Sequence)
+	assert.JSONEq(t, ready, string(events[0].Payload))
+	assert.Equal(t, "TYPING_START", events[1].Name)
+	assert.False(t, events[1].Timestamp.IsZero())
+
+	// TEST CASE: Replaying dispatches every event on the shard
+	shard := newReplayShard()
+	count, err := replayEvents(shard, path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.True(t, shard.ready.Load())
+	assert.Equal(t, "abc", shard.sessionId)
+	assert.EqualValues(t, 2, shard.sequence.Load())
+	assert.EqualValues(t, 1, shard.guilds.Load())
+
+	// TEST CASE: Recording to an existing file appends to it
+	recorder, err = newEventRecorder(path)
+	require.NoError(t, err)
+	recorder.record(0, "TYPING_START", 3, []byte(`{}`))
+	require.NoError(t, recorder.Close())
+	count, err = replayEvents(newReplayShard(), path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+// Tests that the REST stub answers requests without sending them
+func TestRestStub(t *testing.T) {
+	client := http.Client{Transport: restStub{}}
+
+	// TEST CASE: Requests with a response body get an empty JSON object
+	resp, err := client.Get("https://discord.com/api/v10/channels/1")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, json.Valid(body))
+
+	// TEST CASE: Responses carry rate limits for a bucket per route
+	bucket := resp.Header.Get("X-RateLimit-Bucket")
+	assert.NotEmpty(t, bucket)
+	assert.Equal(t, "49", resp.Header.Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, resp.Header.Get("X-RateLimit-Reset-After"))
+	resp, err = client.Get("https://discord.com/api/v10/channels/2")
+	require.NoError(t, err)
+	assert.NotEqual(t, bucket, resp.Header.Get("X-RateLimit-Bucket"))
+
+	// TEST CASE: Requests which discord answers without content get no content
+	req, err := http.NewRequest(http.MethodDelete, "https://discord.com/api/v10/guilds/1/bans/2", nil)
+	require.NoError(t, err)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	. "elaina-common"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+const sessionFilePath = "data/gateway_sessions.json"
+
+// sessionStore saves gateway sessions when the bot shuts down, so the next process can resume them instead of
+// identifying again. Events sent while the bot was restarting are then replayed by discord.
+type sessionStore interface {
+	load() ([]GatewaySession, error)
+	save(sessions []GatewaySession) error // Saving no sessions clears the store
+}
+
+// getSessionStore returns the sessionStore matching name. An empty name disables session persistence.
+func getSessionStore(name string) (sessionStore, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "file":
+		return fileSessionStore{path: sessionFilePath}, nil
+	case "db":
+		return databaseSessionStore{}, nil
+	default:
+		return nil, errors.New("unknown session store: " + name)
+	}
+}
+
+// fileSessionStore saves sessions as a JSON file
+type fileSessionStore struct {
+	path string
+}
+
+func (s fileSessionStore) load() ([]GatewaySession, error) {
+	file, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var sessions []GatewaySession
+	if err = json.Unmarshal(file, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (s fileSessionStore) save(sessions []GatewaySession) error {
+	if len(sessions) == 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	enc, err := json.MarshalIndent(sessions, "", "	")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(s.path), 0770); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, enc, 0660)
+}
+
+// databaseSessionStore saves sessions in the gateway_session table
+type databaseSessionStore struct{}
+
+func (databaseSessionStore) load() ([]GatewaySession, error) {
+	return GetGatewaySessions()
+}
+
+func (databaseSessionStore) save(sessions []GatewaySession) error {
+	return SaveGatewaySessions(sessions)
+}
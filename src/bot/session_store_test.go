@@ -0,0 +1,84 @@
+package main
+
+import (
+	. "elaina-common"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests that the file store saves sessions, loads them back and is cleared by saving no sessions
+func TestFileSessionStore(t *testing.T) {
+	store := fileSessionStore{path: filepath.Join(t.TempDir(), "data", "sessions.json")}
+
+	// TEST CASE: Loading before anything was saved returns no sessions
+	sessions, err := store.load()
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	// TEST CASE: Saved sessions are loaded back unchanged
+	saved := []GatewaySession{
+		{ShardId: 0, ShardCount: 2, SessionId: "a", ResumeUrl: "wss://a.discord.gg", Sequence: 42, SavedAt: time.UnixMilli(1000).UTC(), Guilds: []Snowflake{10, 12}},
+		{ShardId: 1, ShardCount: 2, SessionId: "b", ResumeUrl: "wss://b.discord.gg", Sequence: 7, SavedAt: time.UnixMilli(2000).UTC()},
+	}
+	require.NoError(t, store.save(saved))
+	sessions, err = store.load()
+	require.NoError(t, err)
+	assert.Equal(t, saved, sessions)
+
+	// TEST CASE: Saving no sessions clears the store
+	require.NoError(t, store.save(nil))
+	sessions, err = store.load()
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+// Tests that saved sessions are restored to matching shards and saved again on shutdown
+func TestRestoreSessions(t *testing.T) {
+	store := fileSessionStore{path: filepath.Join(t.TempDir(), "sessions.json")}
+	info := &gatewayBotPayload{Url: "wss://gateway.discord.gg", Shards: 2}
+
+	// TEST CASE: Sessions saved with a different shard count are ignored
+	require.NoError(t, store.save([]GatewaySession{{ShardId: 0, ShardCount: 1, SessionId: "a", ResumeUrl: "wss://a.discord.gg"}}))
+	manager, err := newShardManager(info, gatewayOptions{encoding: jsonEncoding{}, sessions: store})
+	require.NoError(t, err)
+	assert.False(t, manager.shards[0].resuming)
+	assert.False(t, manager.shards[1].resuming)
+
+	// TEST CASE: Sessions older than maxSessionAge are ignored
+	require.NoError(t, store.save([]GatewaySession{{ShardId: 1, ShardCount: 2, SessionId: "b", ResumeUrl: "wss://b.discord.gg", SavedAt: time.Now().Add(-maxSessionAge * 2)}}))
+	manager, err = newShardManager(info, gatewayOptions{encoding: jsonEncoding{}, sessions: store})
+	require.NoError(t, err)
+	assert.False(t, manager.shards[1].resuming)
+
+	// TEST CASE: Matching sessions are resumed and the store is cleared
+	require.NoError(t, store.save([]GatewaySession{{ShardId: 1, ShardCount: 2, SessionId: "b", ResumeUrl: "wss://b.discord.gg", Sequence: 7, SavedAt: time.Now()}}))
+	manager, err = newShardManager(info, gatewayOptions{encoding: jsonEncoding{}, sessions: store})
+	require.NoError(t, err)
+	assert.False(t, manager.shards[0].resuming)
+	assert.True(t, manager.shards[1].resuming)
+	assert.Equal(t, "b", manager.shards[1].sessionId)
+	assert.Equal(t, "wss://b.discord.gg", manager.shards[1].resumeUrl)
+	assert.EqualValues(t, 7, manager.shards[1].sequence.Load())
+
+	sessions, err := store.load()
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	// TEST CASE: Resuming shards don't need a session start
+	require.NoError(t, store.save([]GatewaySession{{ShardId: 1, ShardCount: 2, SessionId: "b", ResumeUrl: "wss://b.discord.gg", SavedAt: time.Now()}}))
+	limited := &gatewayBotPayload{Url: info.Url, Shards: 2, SessionStartLimit: sessionStartLimit{Total: 1000, Remaining: 1}}
+	_, err = newShardManager(limited, gatewayOptions{encoding: jsonEncoding{}, sessions: store})
+	assert.NoError(t, err)
+
+	// TEST CASE: Only shards with a session are saved
+	manager.saveSessions()
+	sessions, err = store.load()
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, 1, sessions[0].ShardId)
+	assert.Equal(t, "b", sessions[0].SessionId)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	. "elaina-common"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// identifyInterval is how long a concurrency bucket must wait between IDENTIFY payloads.
+// See: https://discord.com/developers/docs/events/gateway#sharding-max-concurrency
+const identifyInterval = time.Second * 5
+
+// maxSessionAge is how long a saved session is resumed for. Discord only keeps a disconnected session alive for a few
+// minutes, so resuming an older one is rejected with an INVALID_SESSION after using up an identify attempt.
+const maxSessionAge = time.Minute * 3
+
+// shardManager owns every shard's gateway connection and makes sure they identify within discord's session limits.
+type shardManager struct {
+	shards   []*gateway
+	buckets  []identifyBucket // One bucket per max_concurrency, shards use bucket shard_id % max_concurrency
+	sessions sessionStore     // Optional, sessions are saved here on a graceful shutdown
+
+	memberRequests memberRequests
+
+	options      gatewayOptions
+	workers      *eventWorkers      // Shared by every shard, so the number of running handlers is bounded
+	handlers     sync.WaitGroup     // Queued or running events which haven't been handled yet
+	handlerCtx   context.Context    // Parent of every event handler's context
+	stopHandlers context.CancelFunc // Cancels handlerCtx once the bot stops waiting for handlers on shutdown
+}
+
+// identifyBucket tracks when the shards sharing a rate limit key are next allowed to identify.
+type identifyBucket struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// newShardManager creates a gateway for every shard recommended by discord. No connections are opened until run is called.
+func newShardManager(info *gatewayBotPayload, options gatewayOptions) (*shardManager, error) {
+	count := max(info.Shards, 1)
+	limit := info.SessionStartLimit
+
+	manager := newManager(count, max(limit.MaxConcurrency, 1), options)
+	for i := range manager.shards {
+		manager.shards[i] = newGateway(manager, i, count, options, info.Url)
+	}
+	resumed := manager.restoreSessions()
+
+	starting := count - resumed                        // Resuming doesn't use a session start
+	if limit.Total > 0 && limit.Remaining < starting { // Total is only 0 if discord didn't send a limit at all
+		reset := time.Duration(limit.ResetAfter) * time.Millisecond
+		return nil, fmt.Errorf("not enough session starts remaining to start %d shards (%d remaining), limit resets in %s", starting, limit.Remaining, reset)
+	}
+
+	slog.Info("[Gateway] Starting shards:", slog.Int("shards", count), slog.Int("resuming", resumed), slog.Int("max_concurrency", len(manager.buckets)), slog.Int("remaining_sessions", limit.Remaining))
+	return manager, nil
+}
+
+// newManager creates a shardManager with room for the given number of shards, without creating them.
+func newManager(shards int, concurrency int, options gatewayOptions) *shardManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &shardManager{
+		shards:       make([]*gateway, shards),
+		buckets:      make([]identifyBucket, concurrency),
+		sessions:     options.sessions,
+		options:      options,
+		workers:      newEventWorkers(cmp.Or(options.workers, defaultEventWorkers), cmp.Or(options.queueSize, defaultEventQueueSize), dispatchJob),
+		handlerCtx:   ctx,
+		stopHandlers: cancel,
+	}
+}
+
+// restoreSessions loads the sessions saved by the last process and makes the matching shards resume them, unless they
+// are older than maxSessionAge. The store is cleared afterward, as a session can only be resumed once. Returns the
+// number of shards that will resume.
+func (m *shardManager) restoreSessions() int {
+	if m.sessions == nil {
+		return 0
+	}
+
+	sessions, err := m.sessions.load()
+	if err != nil {
+		slog.Warn("[Gateway] Failed to load saved sessions, identifying instead: " + err.Error())
+		return 0
+	}
+	if err = m.sessions.save(nil); err != nil {
+		slog.Warn("[Gateway] Failed to clear saved sessions: " + err.Error())
+	}
+
+	resumed := 0
+	for _, session := range sessions {
+		if session.ShardCount != len(m.shards) || session.ShardId < 0 || session.ShardId >= len(m.shards) {
+			continue // The shard count changed since the session was saved, so its guilds belong to a different shard
+		}
+		if age := time.Since(session.SavedAt); age > maxSessionAge {
+			slog.Info("[Gateway] Saved session is too old to resume, identifying instead:", slog.Int("shard", session.ShardId), slog.Duration("age", age))
+			continue
+		}
+		m.shards[session.ShardId].restoreSession(session)
+		resumed++
+	}
+	return resumed
+}
+
+// saveSessions saves every shard's session so the next process can resume them. Only call this once every shard has
+// stopped listening.
+func (m *shardManager) saveSessions() {
+	sessions := make([]GatewaySession, 0, len(m.shards))
+	for _, shard := range m.shards {
+		if session, ok := shard.session(); ok {
+			sessions = append(sessions, session)
+		}
+	}
+
+	if err := m.sessions.save(sessions); err != nil {
+		slog.Error("[Gateway] Failed to save sessions: " + err.Error())
+		return
+	}
+	slog.Info("[Gateway] Saved sessions:", slog.Int("sessions", len(sessions)))
+}
+
+// run starts every shard and blocks until a value is received on disconnect or a shard fails. All other shards are
+// closed if any single shard fails, and the error of the first failing shard is returned. If a session store is set,
+// a disconnect keeps every session alive and saves them. Running event handlers are waited for before returning.
+func (m *shardManager) run(disconnect <-chan interface{}) error {
+	m.workers.start(&m.handlers)
+	results := make(chan error, len(m.shards))
+	for _, shard := range m.shards {
+		go func() {
+			results <- shard.listen()
+		}()
+	}
+
+	var err error
+	remaining := len(m.shards)
+	graceful := false
+
+	select {
+	case <-disconnect:
+		graceful = true
+	case err = <-results:
+		remaining--
+		if err == nil {
+			break
+		}
+		slog.Error("[Gateway] Shard failed, closing remaining shards: " + err.Error())
+	}
+
+	keepSessions := graceful && m.sessions != nil
+	for _, shard := range m.shards {
+		shard.disconnect(keepSessions)
+	}
+	for ; remaining > 0; remaining-- {
+		if shardErr := <-results; err == nil {
+			err = shardErr
+		}
+	}
+
+	if keepSessions {
+		m.saveSessions()
+	}
+	m.waitHandlers()
+	return err
+}
+
+// waitHandlers blocks until every queued event has been handled, or options.shutdownTimeout has passed. The context of
+// handlers still running after that is cancelled, and they are left to return by themselves. The event workers are
+// stopped afterward, so nothing can be queued once waitHandlers is called.
+func (m *shardManager) waitHandlers() {
+	defer m.workers.stop()
+	defer m.stopHandlers()
+
+	done := make(chan struct{})
+	go func() {
+		m.handlers.Wait()
+		close(done)
+	}()
+
+	var timeout <-chan time.Time // Never fires if there is no shutdown timeout
+	if m.options.shutdownTimeout > 0 {
+		timeout = time.After(m.options.shutdownTimeout)
+	}
+
+	select {
+	case <-done:
+	case <-timeout:
+		slog.Warn("[Gateway] Event handlers did not finish before the shutdown timeout, cancelling them")
+	}
+}
+
+// shardFor returns the shard receiving events for the given guild.
+// See: https://discord.com/developers/docs/events/gateway#sharding-sharding-formula
+func (m *shardManager) shardFor(guild Snowflake) *gateway {
+	return m.shards[guildShard(guild, len(m.shards))]
+}
+
+// guildShard returns the ID of the shard receiving events for the given guild out of shards
+func guildShard(guild Snowflake, shards int) int {
+	return int((uint64(guild) >> 22) % uint64(shards))
+}
+
+// waitIdentify blocks until the given shard is allowed to send an IDENTIFY payload, then reserves the slot for it.
+// Returns ctx's error without reserving the slot if ctx is done first.
+func (m *shardManager) waitIdentify(ctx context.Context, shard int) error {
+	bucket := &m.buckets[shard%len(m.buckets)]
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	if wait := time.Until(bucket.next); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	bucket.next = time.Now().Add(identifyInterval)
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	. "elaina-common"
 	"elaina-common/restapi"
 	"errors"
@@ -13,26 +14,29 @@ import (
 
 var customEmojiRegex = regexp.MustCompile("^<a?:.{2,}?:\\d{18,20}>$")
 
-func getMemberPerms(guild Guild, member GuildMember, user Snowflake) (Permissions, error) {
+// getMemberPerms calculates the member's guild-wide permissions. Roles are read from State, they are only fetched from
+// discord if the guild isn't stored.
+func getMemberPerms(ctx context.Context, guild Guild, member GuildMember, user Snowflake) (Permissions, error) {
 	if guild.OwnerId == user {
 		return 1<<64 - 1, nil
 	}
 
-	everyone, err := restapi.GetRole(guild.Id, guild.Id)
-	if err != nil {
-		return 0, err
+	roles := State.Roles(guild.Id)
+	if roles == nil {
+		var err error
+		if roles, err = fetchMemberRoles(ctx, guild.Id, member); err != nil {
+			return 0, err
+		}
 	}
-	if everyone == nil {
+
+	everyone, exists := roles[guild.Id]
+	if !exists {
 		return 0, errors.New("no @everyone role was found for guild: " + guild.Id.String())
 	}
 
 	perms := everyone.Permissions
 	for _, roleId := range member.Roles {
-		role, err := restapi.GetRole(guild.Id, roleId)
-		if err != nil {
-			return 0, err
-		}
-		perms |= role.Permissions
+		perms |= roles[roleId].Permissions // Roles deleted since the member was fetched grant nothing
 	}
 
 	if perms&PermAdministrator == PermAdministrator {
@@ -42,8 +46,23 @@ func getMemberPerms(guild Guild, member GuildMember, user Snowflake) (Permission
 	return perms, nil
 }
 
-func getMemberPermsInChannel(guild Guild, member GuildMember, user Snowflake, channel Channel) (Permissions, error) {
-	perms, err := getMemberPerms(guild, member, user)
+// fetchMemberRoles fetches @everyone and the member's roles from discord, for guilds which aren't stored in State
+func fetchMemberRoles(ctx context.Context, guild Snowflake, member GuildMember) (map[Snowflake]Role, error) {
+	roles := make(map[Snowflake]Role, len(member.Roles)+1)
+	for _, roleId := range append([]Snowflake{guild}, member.Roles...) {
+		role, err := restapi.GetRole(ctx, guild, roleId)
+		if err != nil {
+			return nil, err
+		}
+		if role != nil {
+			roles[roleId] = *role
+		}
+	}
+	return roles, nil
+}
+
+func getMemberPermsInChannel(ctx context.Context, guild Guild, member GuildMember, user Snowflake, channel Channel) (Permissions, error) {
+	perms, err := getMemberPerms(ctx, guild, member, user)
 	if err != nil {
 		return 0, err
 	}
@@ -78,46 +97,50 @@ func getMemberPermsInChannel(guild Guild, member GuildMember, user Snowflake, ch
 	return perms, nil
 }
 
-func banUser(guild Snowflake, user User, reason string, deleteMessages int) error {
+func banUser(ctx context.Context, guild Snowflake, user User, reason string, deleteMessages int) error {
 	banMsg := "You have been banned.\nReason: " + reason
 
-	if dm, err := restapi.CreateDM(user.Id); err != nil { // Unlike timeout, the user MUST be notified before they leave the server, or the bot can't send a DM
+	if dm, err := restapi.CreateDM(ctx, user.Id); err != nil { // Unlike timeout, the user MUST be notified before they leave the server, or the bot can't send a DM
 		slog.Error("[Elaina] Failed to notify user of ban:", slog.String("user", user.Username), slog.String("error", err.Error()))
-	} else if _, err = restapi.CreateMessage(dm.Id, banMsg, false); err != nil {
+	} else if _, err = restapi.CreateMessage(ctx, dm.Id, banMsg, false); errors.Is(err, restapi.ErrCannotMessageUser) {
+		slog.Info("[Elaina] User doesn't accept DMs, they weren't notified of their ban:", slog.String("user", user.Username))
+	} else if err != nil {
 		slog.Error("[Elaina] Failed to notify user of ban:", slog.String("user", user.Username), slog.String("error", err.Error()))
 	}
 
-	if err := restapi.CreateBan(guild, user.Id, deleteMessages); err != nil {
-		return errors.New("failed to create ban: " + err.Error())
+	if err := restapi.CreateBan(ctx, guild, user.Id, deleteMessages, restapi.WithReason(reason)); err != nil {
+		return fmt.Errorf("failed to create ban: %w", err)
 	}
 
 	slog.Info("[Elaina] Banned user:", slog.String("id", user.Id.String()), slog.String("reason", reason))
 	return nil
 }
 
-func unbanUser(guild Snowflake, user Snowflake) error {
-	if err := restapi.DeleteBan(guild, user); err != nil { // Unban can't create a DM because we can't assume the user still shares a guild with Elaina
-		return errors.New("failed to unban user: " + err.Error())
+func unbanUser(ctx context.Context, guild Snowflake, user Snowflake, reason string) error {
+	if err := restapi.DeleteBan(ctx, guild, user, restapi.WithReason(reason)); err != nil { // Unban can't create a DM because we can't assume the user still shares a guild with Elaina
+		return fmt.Errorf("failed to unban user: %w", err)
 	}
-	slog.Info("[Elaina] Unbanned user: " + user.String())
+	slog.Info("[Elaina] Unbanned user:", slog.String("id", user.String()), slog.String("reason", reason))
 	return nil
 }
 
-func timeoutUser(guild Snowflake, user User, duration time.Duration, reason string) error {
+func timeoutUser(ctx context.Context, guild Snowflake, user User, duration time.Duration, reason string) error {
 	expires := time.Now().Add(duration)
 
-	go func() {
+	go func(ctx context.Context) { // The DM is sent in the background, so it isn't cancelled when the caller returns
 		timeoutMsg := fmt.Sprintf("You have been timed out until <t:%d>.\nReason: %s", expires.Unix(), reason)
 
-		if dm, err := restapi.CreateDM(user.Id); err != nil {
+		if dm, err := restapi.CreateDM(ctx, user.Id); err != nil {
 			slog.Error("[Elaina] Failed to notify user of timeout:", slog.String("user", user.Username), slog.String("error", err.Error()))
-		} else if _, err = restapi.CreateMessage(dm.Id, timeoutMsg, false); err != nil {
+		} else if _, err = restapi.CreateMessage(ctx, dm.Id, timeoutMsg, false); errors.Is(err, restapi.ErrCannotMessageUser) {
+			slog.Info("[Elaina] User doesn't accept DMs, they weren't notified of their timeout:", slog.String("user", user.Username))
+		} else if err != nil {
 			slog.Error("[Elaina] Failed to notify user of timeout:", slog.String("user", user.Username), slog.String("error", err.Error()))
 		}
-	}()
+	}(context.WithoutCancel(ctx))
 
-	if err := restapi.ModifyGuildMember(guild, user.Id, ModifyGuildMemberPayload{CommunicationDisabledUntil: &Nullable[time.Time]{Value: expires}}); err != nil {
-		return errors.New("failed to modify guild member: " + err.Error())
+	if err := restapi.ModifyGuildMember(ctx, guild, user.Id, ModifyGuildMemberPayload{CommunicationDisabledUntil: &Nullable[time.Time]{Value: expires}}, restapi.WithReason(reason)); err != nil {
+		return fmt.Errorf("failed to modify guild member: %w", err)
 	}
 	slog.Info("[Elaina] User timed out:", slog.String("id", user.Id.String()), slog.Float64("duration", duration.Seconds()), slog.String("reason", reason))
 	return nil
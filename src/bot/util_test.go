@@ -0,0 +1,89 @@
+package main
+
+import (
+	. "elaina-common"
+	"elaina-common/restapi"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingTransport fails every HTTP request, for asserting that no REST calls are made
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("unexpected REST request: " + req.Method + " " + req.URL.String())
+}
+
+// Tests that guild events populate State, and that permissions are calculated from it without REST calls
+func TestMemberPermsFromState(t *testing.T) {
+	SetHttpTransport(failingTransport{})
+	defer SetHttpTransport(nil)
+
+	shard := newReplayShard()
+	shard.interceptEvent("READY", []byte(`{"guilds":[{"id":"5000","unavailable":true}],"session_id":"a"}`))
+	shard.dispatchEvent("GUILD_CREATE", []byte(`{
+		"id": "5000", "owner_id": "1",
+		"roles": [{"id": "5000", "permissions": "2048"}, {"id": "5001", "permissions": "1099511627776"}],
+		"channels": [{"id": "5010", "type": 0, "permission_overwrites": [{"id": "5000", "type": 0, "allow": "0", "deny": "2048"}]}]
+	}`), time.Now())
+	shard.dispatchEvent("GUILD_ROLE_CREATE", []byte(`{"guild_id": "5000", "role": {"id": "5002", "permissions": "8192"}}`), time.Now())
+
+	// TEST CASE: Guild and channel lookups are served from State
+	guild, err := restapi.GetGuild(t.Context(), 5000)
+	require.NoError(t, err)
+	channel, err := restapi.GetChannel(t.Context(), 5010)
+	require.NoError(t, err)
+	assert.Equal(t, Snowflake(5000), channel.GuildId)
+
+	// TEST CASE: Permissions combine @everyone, member roles and overwrites from State
+	member := GuildMember{Roles: []Snowflake{5001, 5002}}
+	perms, err := getMemberPerms(t.Context(), *guild, member, 2)
+	require.NoError(t, err)
+	assert.Equal(t, Permissions(PermSendMessages|PermModerateMembers|PermManageMessages), perms)
+
+	perms, err = getMemberPermsInChannel(t.Context(), *guild, member, 2, *channel)
+	require.NoError(t, err)
+	assert.Equal(t, Permissions(PermModerateMembers|PermManageMessages), perms)
+
+	// TEST CASE: Deleted roles no longer grant permissions, without being fetched over REST
+	shard.dispatchEvent("GUILD_ROLE_DELETE", []byte(`{"guild_id": "5000", "role_id": "5002"}`), time.Now())
+	perms, err = getMemberPerms(t.Context(), *guild, member, 2)
+	require.NoError(t, err)
+	assert.Equal(t, Permissions(PermSendMessages|PermModerateMembers), perms)
+
+	// TEST CASE: Deleted channels are no longer served from State
+	shard.dispatchEvent("CHANNEL_DELETE", []byte(`{"id": "5010", "guild_id": "5000"}`), time.Now())
+	_, err = restapi.GetChannel(t.Context(), 5010)
+	assert.Error(t, err)
+}
+
+// Tests that permissions of stored guilds never reach discord, and that guilds missing from State are fetched instead
+func TestMemberPermsWithoutRest(t *testing.T) {
+	server := startFakeDiscord(t)
+	guild := Guild{Id: 6000, OwnerId: 1}
+	server.AddGuild(guild)
+	server.AddRole(6000, Role{Id: 6000, Permissions: PermSendMessages})
+	server.AddRole(6000, Role{Id: 6001, Permissions: PermAdministrator})
+	member := GuildMember{Roles: []Snowflake{6001}}
+
+	// TEST CASE: Guilds which aren't stored are fetched from discord
+	perms, err := getMemberPerms(t.Context(), guild, member, 2)
+	require.NoError(t, err)
+	assert.Equal(t, Permissions(1<<64-1), perms)
+	assert.NotEmpty(t, server.Requests())
+
+	// TEST CASE: Once stored, State is the only source, even for roles discord still has
+	State.AddGuild(CreateGuildPayload{Guild: guild, Roles: []Role{{Id: 6000, Permissions: PermSendMessages}}})
+	defer State.RemoveGuild(6000)
+	requests := len(server.Requests())
+
+	perms, err = getMemberPerms(t.Context(), guild, member, 2)
+	require.NoError(t, err)
+	assert.Equal(t, Permissions(PermSendMessages), perms)
+	assert.Len(t, server.Requests(), requests, "permissions of a stored guild must not make REST calls")
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,7 +21,8 @@ var idToOptTypeName = map[CommandOptionType]string{
 	CmdOptAttachment:      "Attachment",
 }
 
-type CommandHandler = func(params CommandParams) error
+// CommandHandler handles an application command. ctx is cancelled once the interaction's event handler times out.
+type CommandHandler = func(ctx context.Context, params CommandParams) error
 
 type CommandParams struct {
 	GuildId          Snowflake
@@ -129,3 +129,24 @@ const (
 	CmdOptFloat64
 	CmdOptAttachment
 )
+
+// ActivityType as specified by https://discord.com/developers/docs/events/gateway-events#activity-object-activity-types
+type ActivityType int
+
+const (
+	ActivityPlaying ActivityType = iota
+	ActivityStreaming
+	ActivityListening
+	ActivityWatching
+	ActivityCustom
+	ActivityCompeting
+)
+
+// Presence status as specified by https://discord.com/developers/docs/events/gateway-events#update-presence-status-types
+const (
+	StatusOnline    = "online"
+	StatusDnd       = "dnd"
+	StatusIdle      = "idle"
+	StatusInvisible = "invisible"
+	StatusOffline   = "offline"
+)
@@ -2,6 +2,7 @@ package common
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -157,3 +158,53 @@ func DeleteMacro(guild Snowflake, key string) (bool, error) {
 	i, err := res.RowsAffected()
 	return i > 0, err
 }
+
+// GetGatewaySessions returns every gateway session saved by SaveGatewaySessions.
+func GetGatewaySessions() ([]GatewaySession, error) {
+	rows, err := dbConn.Query(`SELECT shard_id, shard_count, session_id, resume_url, sequence, saved_at, guilds FROM gateway_session`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []GatewaySession
+	for rows.Next() {
+		var session GatewaySession
+		var savedAt int64
+		var guilds []byte
+		if err = rows.Scan(&session.ShardId, &session.ShardCount, &session.SessionId, &session.ResumeUrl, &session.Sequence, &savedAt, &guilds); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(guilds, &session.Guilds); err != nil {
+			return nil, err
+		}
+		session.SavedAt = time.UnixMilli(savedAt)
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// SaveGatewaySessions replaces every saved gateway session with sessions. Saving no sessions clears them.
+func SaveGatewaySessions(sessions []GatewaySession) error {
+	tx, err := dbConn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM gateway_session`); err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		guilds, err := json.Marshal(session.Guilds) // Stored as a JSON array, they are only ever read back all at once
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO gateway_session VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			session.ShardId, session.ShardCount, session.SessionId, session.ResumeUrl, session.Sequence, session.SavedAt.UnixMilli(), guilds)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,755 @@
+// Package discordtest implements a fake discord REST API for tests. It keeps guilds, channels, messages, members and
+// bans in memory, records an audit log of moderation actions, implements the routes used by restapi and the bot's interactions, and sends rate limit headers like
+// discord does. Point REST calls at it with SetApiUrl(server.URL).
+package discordtest
+
+import (
+	. "elaina-common"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// JSON error codes returned by the fake, see https://discord.com/developers/docs/topics/opcodes-and-status-codes#json
+const (
+	CodeUnknownChannel     = 10003
+	CodeUnknownGuild       = 10004
+	CodeUnknownMember      = 10007
+	CodeUnknownMessage     = 10008
+	CodeUnknownRole        = 10011
+	CodeUnknownBan         = 10026
+	CodeUnknownInteraction = 10062
+	CodeUnknownCommand     = 10063
+	CodeCannotMessageUser  = 50007
+	CodeMissingPermissions = 50013
+)
+
+// Audit log action types recorded by the fake, see https://discord.com/developers/docs/resources/audit-log#audit-log-entry-object-audit-log-events
+const (
+	AuditMemberKick      = 20
+	AuditMemberBanAdd    = 22
+	AuditMemberBanRemove = 23
+	AuditMemberUpdate    = 24
+)
+
+// maxAuditLogReason is the most characters discord accepts in X-Audit-Log-Reason
+const maxAuditLogReason = 512
+
+// Request is a request received by the fake, in the order they were received
+type Request struct {
+	Method  string
+	Pattern string // Route the request matched, e.g. "PUT /guilds/{guild}/bans/{user}"
+	Path    string
+	Header  http.Header
+	Body    []byte
+	Reason  string // Decoded X-Audit-Log-Reason, empty if none was sent
+}
+
+// Error is a JSON error response, sent instead of handling a request. See Server.FailNext
+type Error struct {
+	Status  int             `json:"-"`
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Errors  json.RawMessage `json:"errors,omitempty"` // Optional nested field errors, sent as is
+}
+
+type guildState struct {
+	guild    Guild
+	roles    map[Snowflake]Role
+	members  map[Snowflake]GuildMember
+	bans     map[Snowflake]GuildBan
+	auditLog []AuditLogEntry
+}
+
+type bucketState struct {
+	count int
+	reset time.Time
+}
+
+// Server is a fake discord REST API. Its state can be set up and inspected with its methods while requests are sent.
+type Server struct {
+	URL string // Base API URL, pass it to SetApiUrl
+
+	// Every route and major parameter is limited to Limit requests per Window, exceeding it responds with a 429
+	Limit  int
+	Window time.Duration
+
+	server *httptest.Server
+
+	mu           sync.Mutex
+	nextId       Snowflake
+	guilds       map[Snowflake]*guildState
+	channels     map[Snowflake]Channel
+	messages     map[Snowflake][]Message // Channel ID to messages in the order they were sent
+	reactions    map[Snowflake][]string  // Message ID to emojis
+	dms          map[Snowflake]Snowflake // User ID to DM channel ID
+	commands     map[Snowflake]ApplicationCommand
+	interactions []Request
+	requests     []Request
+	failures     map[string][]Error // Route pattern to errors sent to its next requests
+	buckets      map[string]*bucketState
+}
+
+// NewServer starts a fake discord with no state
+func NewServer() *Server {
+	s := &Server{
+		Limit:     50,
+		Window:    time.Second,
+		nextId:    1_000_000,
+		guilds:    make(map[Snowflake]*guildState),
+		channels:  make(map[Snowflake]Channel),
+		messages:  make(map[Snowflake][]Message),
+		reactions: make(map[Snowflake][]string),
+		dms:       make(map[Snowflake]Snowflake),
+		commands:  make(map[Snowflake]ApplicationCommand),
+		failures:  make(map[string][]Error),
+		buckets:   make(map[string]*bucketState),
+	}
+
+	mux := http.NewServeMux()
+	s.handle(mux, "POST /applications/{application}/commands", s.createCommand)
+	s.handle(mux, "DELETE /applications/{application}/commands/{command}", s.deleteCommand)
+
+	s.handle(mux, "GET /channels/{channel}", s.getChannel)
+	s.handle(mux, "GET /channels/{channel}/messages/{message}", s.getMessage)
+	s.handle(mux, "POST /channels/{channel}/messages", s.createMessage)
+	s.handle(mux, "DELETE /channels/{channel}/messages/{message}", s.deleteMessage)
+	s.handle(mux, "PUT /channels/{channel}/messages/{message}/reactions/{emoji}/@me", s.createReaction)
+	s.handle(mux, "POST /users/@me/channels", s.createDM)
+
+	s.handle(mux, "GET /guilds/{guild}", s.getGuild)
+	s.handle(mux, "GET /guilds/{guild}/channels", s.getGuildChannels)
+	s.handle(mux, "GET /guilds/{guild}/roles/{role}", s.getRole)
+	s.handle(mux, "GET /guilds/{guild}/members/{user}", s.getMember)
+	s.handle(mux, "PATCH /guilds/{guild}/members/{user}", s.modifyMember)
+	s.handle(mux, "DELETE /guilds/{guild}/members/{user}", s.kickMember)
+	s.handle(mux, "PUT /guilds/{guild}/bans/{user}", s.createBan)
+	s.handle(mux, "DELETE /guilds/{guild}/bans/{user}", s.deleteBan)
+
+	s.handle(mux, "POST /interactions/{interaction}/{token}/callback", s.interactionCallback)
+	s.handle(mux, "PATCH /webhooks/{application}/{token}/messages/@original", s.interactionCallback)
+
+	s.server = httptest.NewServer(mux)
+	s.URL = s.server.URL
+	return s
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// handle registers handler for pattern, wrapped to record the request, apply rate limits and send queued failures
+func (s *Server) handle(mux *http.ServeMux, pattern string, handler func(r *http.Request, body []byte) (any, *Error)) {
+	mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		reason, invalidReason := auditLogReason(r)
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, Pattern: pattern, Path: r.URL.Path, Header: r.Header.Clone(), Body: body, Reason: reason})
+		limited := s.rateLimit(w, r, pattern)
+		var failure *Error
+		if queued := s.failures[pattern]; len(queued) > 0 && !limited {
+			failure = &queued[0]
+			s.failures[pattern] = queued[1:]
+		}
+		s.mu.Unlock()
+
+		if limited {
+			return
+		}
+		if failure != nil {
+			writeJson(w, failure.Status, failure)
+			return
+		}
+		if invalidReason != nil {
+			writeJson(w, invalidReason.Status, invalidReason)
+			return
+		}
+
+		resp, fail := handler(r, body)
+		switch {
+		case fail != nil:
+			writeJson(w, fail.Status, fail)
+		case resp == nil:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeJson(w, http.StatusOK, resp)
+		}
+	})
+}
+
+// rateLimit sets the rate limit headers of the request's bucket, or responds with a 429 and returns true if it is
+// exhausted. s.mu must be held.
+func (s *Server) rateLimit(w http.ResponseWriter, r *http.Request, pattern string) bool {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(pattern))
+	bucketHash := strconv.FormatUint(hash.Sum64(), 16)
+
+	major := r.PathValue("channel") + r.PathValue("guild") + r.PathValue("application")
+	key := bucketHash + ":" + major
+
+	now := time.Now()
+	bucket := s.buckets[key]
+	if bucket == nil || now.After(bucket.reset) {
+		bucket = &bucketState{reset: now.Add(s.Window)}
+		s.buckets[key] = bucket
+	}
+	bucket.count++
+	resetAfter := bucket.reset.Sub(now).Seconds()
+
+	w.Header().Set("X-RateLimit-Bucket", bucketHash)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(max(s.Limit-bucket.count, 0)))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatFloat(float64(bucket.reset.UnixMilli())/1000, 'f', 3, 64))
+	w.Header().Set("X-RateLimit-Reset-After", strconv.FormatFloat(resetAfter, 'f', 3, 64))
+
+	if bucket.count <= s.Limit {
+		return false
+	}
+	w.Header().Set("X-RateLimit-Scope", "user")
+	w.Header().Set("Retry-After", strconv.FormatFloat(resetAfter, 'f', 3, 64))
+	writeJson(w, http.StatusTooManyRequests, struct {
+		Message    string  `json:"message"`
+		RetryAfter float64 `json:"retry_after"`
+		Global     bool    `json:"global"`
+	}{"You are being rate limited.", resetAfter, false})
+	return true
+}
+
+func writeJson(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func notFound(code int, message string) *Error {
+	return &Error{Status: http.StatusNotFound, Code: code, Message: message}
+}
+
+func badRequest(err error) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: 50035, Message: "Invalid Form Body: " + err.Error()}
+}
+
+// auditLogReason decodes the URL encoded X-Audit-Log-Reason of a request, or returns an error if it is invalid
+func auditLogReason(r *http.Request) (string, *Error) {
+	reason, err := url.PathUnescape(r.Header.Get("X-Audit-Log-Reason"))
+	if err != nil {
+		return "", badRequest(fmt.Errorf("X-Audit-Log-Reason is not URL encoded: %w", err))
+	}
+	if len([]rune(reason)) > maxAuditLogReason {
+		return "", badRequest(fmt.Errorf("X-Audit-Log-Reason must be %d or fewer in length", maxAuditLogReason))
+	}
+	return reason, nil
+}
+
+// audit records an action taken by the request on target in the guild's audit log. s.mu must be held.
+func (s *Server) audit(guild *guildState, r *http.Request, action int, target Snowflake) {
+	reason, _ := auditLogReason(r) // Invalid reasons were already rejected
+	guild.auditLog = append(guild.auditLog, AuditLogEntry{Id: s.newId(), TargetId: target.String(), ActionType: action, Reason: reason})
+}
+
+// pathId parses the snowflake in the named path segment, or returns 0 if it isn't one
+func pathId(r *http.Request, name string) Snowflake {
+	id, _ := strconv.ParseUint(r.PathValue(name), 10, 64)
+	return Snowflake(id)
+}
+
+// newId returns a new unique snowflake. s.mu must be held.
+func (s *Server) newId() Snowflake {
+	s.nextId++
+	return s.nextId
+}
+
+// FailNext makes the next request to the route with the given pattern respond with err instead of being handled.
+// Failures queued for the same route are sent in order.
+func (s *Server) FailNext(pattern string, err Error) {
+	s.mu.Lock()
+	s.failures[pattern] = append(s.failures[pattern], err)
+	s.mu.Unlock()
+}
+
+// Requests returns every request received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.requests)
+}
+
+// Patterns returns the route pattern of every request received so far, e.g. for asserting the order of calls
+func (s *Server) Patterns() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	patterns := make([]string, len(s.requests))
+	for i, r := range s.requests {
+		patterns[i] = r.Pattern
+	}
+	return patterns
+}
+
+// --------------------------------------------------------------------
+// |                              STATE                               |
+// --------------------------------------------------------------------
+
+// AddGuild adds a guild, replacing any guild with the same ID
+func (s *Server) AddGuild(guild Guild) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.guilds[guild.Id] = &guildState{
+		guild:   guild,
+		roles:   make(map[Snowflake]Role),
+		members: make(map[Snowflake]GuildMember),
+		bans:    make(map[Snowflake]GuildBan),
+	}
+}
+
+// AddRole adds a role to a guild added with AddGuild
+func (s *Server) AddRole(guild Snowflake, role Role) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.guilds[guild].roles[role.Id] = role
+}
+
+// AddMember adds a member to a guild added with AddGuild. member.User must be set.
+func (s *Server) AddMember(guild Snowflake, member GuildMember) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.guilds[guild].members[member.User.Id] = member
+}
+
+// AddChannel adds a channel, replacing any channel with the same ID
+func (s *Server) AddChannel(channel Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels[channel.Id] = channel
+}
+
+// Member returns a member of a guild, or nil if they aren't in it
+func (s *Server) Member(guild Snowflake, user Snowflake) *GuildMember {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g := s.guilds[guild]; g != nil {
+		if member, ok := g.members[user]; ok {
+			return &member
+		}
+	}
+	return nil
+}
+
+// Ban returns the ban of a user in a guild, or nil if they aren't banned
+func (s *Server) Ban(guild Snowflake, user Snowflake) *GuildBan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g := s.guilds[guild]; g != nil {
+		if ban, ok := g.bans[user]; ok {
+			return &ban
+		}
+	}
+	return nil
+}
+
+// AuditLog returns the audit log entries of a guild, in the order they were created
+func (s *Server) AuditLog(guild Snowflake) []AuditLogEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g := s.guilds[guild]; g != nil {
+		return slices.Clone(g.auditLog)
+	}
+	return nil
+}
+
+// Messages returns the messages in a channel, in the order they were sent
+func (s *Server) Messages(channel Snowflake) []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.messages[channel])
+}
+
+// DMs returns the messages sent to a user's DM channel, in the order they were sent
+func (s *Server) DMs(user Snowflake) []Message {
+	s.mu.Lock()
+	dm, ok := s.dms[user]
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return s.Messages(dm)
+}
+
+// Reactions returns the emojis the bot reacted to a message with
+func (s *Server) Reactions(message Snowflake) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.reactions[message])
+}
+
+// Commands returns every application command created
+func (s *Server) Commands() []ApplicationCommand {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	commands := make([]ApplicationCommand, 0, len(s.commands))
+	for _, command := range s.commands {
+		commands = append(commands, command)
+	}
+	slices.SortFunc(commands, func(a, b ApplicationCommand) int { return int(a.Id) - int(b.Id) })
+	return commands
+}
+
+// InteractionResponses returns the interaction callbacks and edits to original responses received so far
+func (s *Server) InteractionResponses() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.interactions)
+}
+
+// --------------------------------------------------------------------
+// |                              ROUTES                              |
+// --------------------------------------------------------------------
+
+func (s *Server) createCommand(r *http.Request, body []byte) (any, *Error) {
+	var command ApplicationCommand
+	if err := json.Unmarshal(body, &command); err != nil {
+		return nil, badRequest(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.commands { // Creating a command with an existing name overwrites it
+		if existing.Name == command.Name {
+			command.Id = existing.Id
+		}
+	}
+	if command.Id == 0 {
+		command.Id = s.newId()
+	}
+	command.ApplicationId = pathId(r, "application")
+	s.commands[command.Id] = command
+	return command, nil
+}
+
+func (s *Server) deleteCommand(r *http.Request, _ []byte) (any, *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := pathId(r, "command")
+	if _, ok := s.commands[id]; !ok {
+		return nil, notFound(CodeUnknownCommand, "Unknown application command")
+	}
+	delete(s.commands, id)
+	return nil, nil
+}
+
+func (s *Server) getChannel(r *http.Request, _ []byte) (any, *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channel, ok := s.channels[pathId(r, "channel")]
+	if !ok {
+		return nil, notFound(CodeUnknownChannel, "Unknown Channel")
+	}
+	return channel, nil
+}
+
+// message returns the index of a message in s.messages. s.mu must be held.
+func (s *Server) message(r *http.Request) (int, *Error) {
+	channel := pathId(r, "channel")
+	if _, ok := s.channels[channel]; !ok {
+		return 0, notFound(CodeUnknownChannel, "Unknown Channel")
+	}
+	id := pathId(r, "message")
+	i := slices.IndexFunc(s.messages[channel], func(m Message) bool { return m.Id == id })
+	if i < 0 {
+		return 0, notFound(CodeUnknownMessage, "Unknown Message")
+	}
+	return i, nil
+}
+
+func (s *Server) getMessage(r *http.Request, _ []byte) (any, *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.message(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.messages[pathId(r, "channel")][i], nil
+}
+
+func (s *Server) createMessage(r *http.Request, body []byte) (any, *Error) {
+	var message Message
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, badRequest(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channel := pathId(r, "channel")
+	if _, ok := s.channels[channel]; !ok {
+		return nil, notFound(CodeUnknownChannel, "Unknown Channel")
+	}
+	message.Id = s.newId()
+	message.ChannelId = channel
+	message.Timestamp = time.Now().Format(time.RFC3339)
+	s.messages[channel] = append(s.messages[channel], message)
+	return message, nil
+}
+
+func (s *Server) deleteMessage(r *http.Request, _ []byte) (any, *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, err := s.message(r)
+	if err != nil {
+		return nil, err
+	}
+	channel := pathId(r, "channel")
+	s.messages[channel] = slices.Delete(s.messages[channel], i, i+1)
+	return nil, nil
+}
+
+func (s *Server) createReaction(r *http.Request, _ []byte) (any, *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.message(r); err != nil {
+		return nil, err
+	}
+	message := pathId(r, "message")
+	s.reactions[message] = append(s.reactions[message], r.PathValue("emoji"))
+	return nil, nil
+}
+
+func (s *Server) createDM(_ *http.Request, body []byte) (any, *Error) {
+	var payload struct {
+		Recipient Snowflake `json:"recipient_id"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, badRequest(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.dms[payload.Recipient]; ok {
+		return s.channels[id], nil
+	}
+	channel := Channel{Id: s.newId(), Type: 1, Recipients: []User{{Id: payload.Recipient}}}
+	s.channels[channel.Id] = channel
+	s.dms[payload.Recipient] = channel.Id
+	return channel, nil
+}
+
+// guild returns the state of the guild in the request's path. s.mu must be held.
+func (s *Server) guild(r *http.Request) (*guildState, *Error) {
+	guild := s.guilds[pathId(r, "guild")]
+	if guild == nil {
+		return nil, notFound(CodeUnknownGuild, "Unknown Guild")
+	}
+	return guild, nil
+}
+
+func (s *Server) getGuild(r *http.Request, _ []byte) (any, *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guild, err := s.guild(r)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]Role, 0, len(guild.roles))
+	for _, role := range guild.roles {
+		roles = append(roles, role)
+	}
+	return struct {
+		Guild
+		Roles []Role `json:"roles"`
+	}{guild.guild, roles}, nil
+}
+
+func (s *Server) getGuildChannels(r *http.Request, _ []byte) (any, *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := pathId(r, "guild")
+	if _, err := s.guild(r); err != nil {
+		return nil, err
+	}
+	channels := make([]Channel, 0)
+	for _, channel := range s.channels {
+		if channel.GuildId == id {
+			channels = append(channels, channel)
+		}
+	}
+	return channels, nil
+}
+
+func (s *Server) getRole(r *http.Request, _ []byte) (any, *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guild, err := s.guild(r)
+	if err != nil {
+		return nil, err
+	}
+	role, ok := guild.roles[pathId(r, "role")]
+	if !ok {
+		return nil, notFound(CodeUnknownRole, "Unknown Role")
+	}
+	return role, nil
+}
+
+func (s *Server) getMember(r *http.Request, _ []byte) (any, *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guild, err := s.guild(r)
+	if err != nil {
+		return nil, err
+	}
+	member, ok := guild.members[pathId(r, "user")]
+	if !ok {
+		return nil, notFound(CodeUnknownMember, "Unknown Member")
+	}
+	return member, nil
+}
+
+func (s *Server) modifyMember(r *http.Request, body []byte) (any, *Error) {
+	var payload struct {
+		Nick                       *string      `json:"nick"`
+		Roles                      *[]Snowflake `json:"roles"`
+		Mute                       *bool        `json:"mute"`
+		Deaf                       *bool        `json:"deaf"`
+		CommunicationDisabledUntil *string      `json:"communication_disabled_until"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, badRequest(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guild, err := s.guild(r)
+	if err != nil {
+		return nil, err
+	}
+	user := pathId(r, "user")
+	member, ok := guild.members[user]
+	if !ok {
+		return nil, notFound(CodeUnknownMember, "Unknown Member")
+	}
+
+	// Fields sent as null are cleared, which decodes to nil the same as missing fields, so check the raw body as well
+	var raw map[string]json.RawMessage
+	_ = json.Unmarshal(body, &raw)
+	if payload.Nick != nil {
+		member.Nick = *payload.Nick
+	} else if _, ok := raw["nick"]; ok {
+		member.Nick = ""
+	}
+	if payload.Roles != nil {
+		member.Roles = *payload.Roles
+	}
+	if payload.Mute != nil {
+		member.Mute = *payload.Mute
+	}
+	if payload.Deaf != nil {
+		member.Deaf = *payload.Deaf
+	}
+	if payload.CommunicationDisabledUntil != nil {
+		member.CommunicationDisabledUntil = *payload.CommunicationDisabledUntil
+	} else if _, ok := raw["communication_disabled_until"]; ok {
+		member.CommunicationDisabledUntil = ""
+	}
+	guild.members[user] = member
+	s.audit(guild, r, AuditMemberUpdate, user)
+	return member, nil
+}
+
+func (s *Server) kickMember(r *http.Request, _ []byte) (any, *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guild, err := s.guild(r)
+	if err != nil {
+		return nil, err
+	}
+	user := pathId(r, "user")
+	if _, ok := guild.members[user]; !ok {
+		return nil, notFound(CodeUnknownMember, "Unknown Member")
+	}
+	delete(guild.members, user)
+	s.audit(guild, r, AuditMemberKick, user)
+	return nil, nil
+}
+
+func (s *Server) createBan(r *http.Request, body []byte) (any, *Error) {
+	var payload struct {
+		DeleteMessageSeconds int `json:"delete_message_seconds"`
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, badRequest(err)
+		}
+	}
+	if payload.DeleteMessageSeconds < 0 || payload.DeleteMessageSeconds > 604800 {
+		return nil, badRequest(fmt.Errorf("delete_message_seconds must be between 0 and 604800"))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guild, err := s.guild(r)
+	if err != nil {
+		return nil, err
+	}
+	user := pathId(r, "user")
+	banned := User{Id: user}
+	if member, ok := guild.members[user]; ok {
+		banned = *member.User
+	}
+	reason, _ := auditLogReason(r)
+	guild.bans[user] = GuildBan{User: banned, Reason: reason}
+	delete(guild.members, user) // Banning removes the user from the guild
+	s.audit(guild, r, AuditMemberBanAdd, user)
+	return nil, nil
+}
+
+func (s *Server) deleteBan(r *http.Request, _ []byte) (any, *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guild, err := s.guild(r)
+	if err != nil {
+		return nil, err
+	}
+	user := pathId(r, "user")
+	if _, ok := guild.bans[user]; !ok {
+		return nil, notFound(CodeUnknownBan, "Unknown Ban")
+	}
+	delete(guild.bans, user)
+	s.audit(guild, r, AuditMemberBanRemove, user)
+	return nil, nil
+}
+
+func (s *Server) interactionCallback(r *http.Request, body []byte) (any, *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interactions = append(s.interactions, Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+	return nil, nil
+}
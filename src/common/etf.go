@@ -0,0 +1,498 @@
+package common
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Term tags of the External Term Format as specified by https://www.erlang.org/doc/apps/erts/erl_ext_dist.html
+// Only the tags discord can send or receive have been included.
+const (
+	etfVersion      = 131
+	etfCompressed   = 80
+	etfNewFloat     = 70
+	etfSmallInteger = 97
+	etfInteger      = 98
+	etfFloat        = 99
+	etfAtom         = 100
+	etfSmallTuple   = 104
+	etfLargeTuple   = 105
+	etfNil          = 106
+	etfString       = 107
+	etfList         = 108
+	etfBinary       = 109
+	etfSmallBig     = 110
+	etfLargeBig     = 111
+	etfSmallAtom    = 115
+	etfMap          = 116
+	etfAtomUtf8     = 118
+	etfSmallAtomUtf = 119
+)
+
+// maxDeflateRatio is the most deflate can compress data by, which bounds the size of a compressed term
+const maxDeflateRatio = 1032
+
+var ErrInvalidEtf = errors.New("invalid etf term")
+
+// EtfToJson transcodes an ETF encoded term into JSON. Atoms nil, true and false become null, true and false. Integers
+// are written as JSON numbers, StringInt64 accepts both. Use UnmarshalEtf to decode a term into a value instead.
+func EtfToJson(data []byte) ([]byte, error) {
+	r, err := newEtfReader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err = r.term(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// JsonToEtf transcodes a JSON document into an ETF encoded term. Objects become maps with binary keys, strings become
+// binaries and null becomes the atom nil.
+func JsonToEtf(data []byte) ([]byte, error) {
+	return appendJsonEtf([]byte{etfVersion}, data)
+}
+
+// appendJsonEtf appends the JSON document in data to out as an ETF term, without a version byte.
+func appendJsonEtf(out []byte, data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return appendEtf(out, value)
+}
+
+type etfReader struct {
+	data []byte
+	pos  int
+}
+
+// newEtfReader returns a reader for the term in data, which must start with the version byte. Compressed terms are
+// inflated first.
+func newEtfReader(data []byte) (*etfReader, error) {
+	if len(data) == 0 || data[0] != etfVersion {
+		return nil, ErrInvalidEtf
+	}
+
+	r := &etfReader{data: data[1:]}
+	if len(r.data) > 0 && r.data[0] == etfCompressed {
+		inflated, err := r.inflate()
+		if err != nil {
+			return nil, err
+		}
+		r = &etfReader{data: inflated}
+	}
+	return r, nil
+}
+
+func (r *etfReader) read(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, ErrInvalidEtf
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *etfReader) uint8() (int, error) {
+	b, err := r.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return int(b[0]), nil
+}
+
+func (r *etfReader) uint16() (int, error) {
+	b, err := r.read(2)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint16(b)), nil
+}
+
+func (r *etfReader) uint32() (int, error) {
+	b, err := r.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint32(b)), nil
+}
+
+// inflate decompresses a compressed term, returning the uncompressed term it contains. The size sent with the term
+// can't be trusted, so nothing is allocated up front: the term is rejected if its size couldn't possibly be inflated
+// from the remaining data, or if inflating it produces a different size.
+func (r *etfReader) inflate() ([]byte, error) {
+	r.pos++ // Compressed tag
+	size, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	compressed := r.data[r.pos:]
+	if size > len(compressed)*maxDeflateRatio {
+		return nil, fmt.Errorf("%w: compressed term claims an impossible size", ErrInvalidEtf)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	out, err := io.ReadAll(io.LimitReader(zr, int64(size)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != size {
+		return nil, fmt.Errorf("%w: compressed term has the wrong size", ErrInvalidEtf)
+	}
+	return out, nil
+}
+
+// term reads a single term and writes it to out as JSON.
+func (r *etfReader) term(out *bytes.Buffer) error {
+	tag, err := r.uint8()
+	if err != nil {
+		return err
+	}
+
+	switch tag {
+	case etfSmallInteger:
+		v, err := r.uint8()
+		if err != nil {
+			return err
+		}
+		out.WriteString(strconv.Itoa(v))
+	case etfInteger:
+		b, err := r.read(4)
+		if err != nil {
+			return err
+		}
+		out.WriteString(strconv.Itoa(int(int32(binary.BigEndian.Uint32(b)))))
+	case etfNewFloat:
+		b, err := r.read(8)
+		if err != nil {
+			return err
+		}
+		return writeJsonFloat(out, math.Float64frombits(binary.BigEndian.Uint64(b)))
+	case etfFloat:
+		b, err := r.read(31)
+		if err != nil {
+			return err
+		}
+		f, err := strconv.ParseFloat(string(bytes.TrimRight(b, "\x00")), 64)
+		if err != nil {
+			return ErrInvalidEtf
+		}
+		return writeJsonFloat(out, f)
+	case etfSmallBig, etfLargeBig:
+		var n int
+		if tag == etfSmallBig {
+			n, err = r.uint8()
+		} else {
+			n, err = r.uint32()
+		}
+		if err != nil {
+			return err
+		}
+		return r.big(out, n)
+	case etfAtom, etfAtomUtf8:
+		n, err := r.uint16()
+		if err != nil {
+			return err
+		}
+		return r.atom(out, n)
+	case etfSmallAtom, etfSmallAtomUtf:
+		n, err := r.uint8()
+		if err != nil {
+			return err
+		}
+		return r.atom(out, n)
+	case etfBinary:
+		n, err := r.uint32()
+		if err != nil {
+			return err
+		}
+		b, err := r.read(n)
+		if err != nil {
+			return err
+		}
+		return writeJsonString(out, b)
+	case etfString: // Lists of bytes are encoded as strings by erlang, discord only sends them for text
+		n, err := r.uint16()
+		if err != nil {
+			return err
+		}
+		b, err := r.read(n)
+		if err != nil {
+			return err
+		}
+		return writeJsonString(out, b)
+	case etfNil:
+		out.WriteString("[]")
+	case etfList:
+		n, err := r.uint32()
+		if err != nil {
+			return err
+		}
+		if err = r.array(out, n); err != nil {
+			return err
+		}
+		tail, err := r.uint8() // Proper lists always end in NIL_EXT
+		if err != nil {
+			return err
+		}
+		if tail != etfNil {
+			return fmt.Errorf("%w: improper lists are not supported", ErrInvalidEtf)
+		}
+	case etfSmallTuple, etfLargeTuple:
+		var n int
+		if tag == etfSmallTuple {
+			n, err = r.uint8()
+		} else {
+			n, err = r.uint32()
+		}
+		if err != nil {
+			return err
+		}
+		return r.array(out, n)
+	case etfMap:
+		n, err := r.uint32()
+		if err != nil {
+			return err
+		}
+		return r.object(out, n)
+	default:
+		return fmt.Errorf("%w: unsupported tag %d", ErrInvalidEtf, tag)
+	}
+	return nil
+}
+
+func (r *etfReader) atom(out *bytes.Buffer, n int) error {
+	b, err := r.read(n)
+	if err != nil {
+		return err
+	}
+
+	switch string(b) {
+	case "nil", "null":
+		out.WriteString("null")
+	case "true", "false":
+		out.Write(b)
+	default:
+		return writeJsonString(out, b)
+	}
+	return nil
+}
+
+func (r *etfReader) big(out *bytes.Buffer, n int) error {
+	sign, err := r.uint8()
+	if err != nil {
+		return err
+	}
+	digits, err := r.read(n)
+	if err != nil {
+		return err
+	}
+
+	if n <= 8 { // Snowflakes always fit in 8 bytes, so avoid allocating a big.Int for them
+		var v uint64
+		for i := n - 1; i >= 0; i-- {
+			v = v<<8 | uint64(digits[i])
+		}
+		if sign != 0 {
+			out.WriteByte('-')
+		}
+		out.WriteString(strconv.FormatUint(v, 10))
+		return nil
+	}
+
+	be := make([]byte, n) // big.Int expects big-endian bytes, ETF digits are little-endian
+	for i, d := range digits {
+		be[n-1-i] = d
+	}
+	v := new(big.Int).SetBytes(be)
+	if sign != 0 {
+		v.Neg(v)
+	}
+	out.WriteString(v.String())
+	return nil
+}
+
+func (r *etfReader) array(out *bytes.Buffer, n int) error {
+	out.WriteByte('[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		if err := r.term(out); err != nil {
+			return err
+		}
+	}
+	out.WriteByte(']')
+	return nil
+}
+
+func (r *etfReader) object(out *bytes.Buffer, n int) error {
+	out.WriteByte('{')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+
+		var key bytes.Buffer
+		if err := r.term(&key); err != nil {
+			return err
+		}
+		if k := key.Bytes(); len(k) > 0 && k[0] == '"' {
+			out.Write(k)
+		} else { // JSON keys must be strings, so integer or atom keys get quoted
+			if err := writeJsonString(out, k); err != nil {
+				return err
+			}
+		}
+
+		out.WriteByte(':')
+		if err := r.term(out); err != nil {
+			return err
+		}
+	}
+	out.WriteByte('}')
+	return nil
+}
+
+func writeJsonString(out *bytes.Buffer, b []byte) error {
+	enc, err := json.Marshal(string(b))
+	if err != nil {
+		return err
+	}
+	out.Write(enc)
+	return nil
+}
+
+func writeJsonFloat(out *bytes.Buffer, f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("%w: float can't be represented in json", ErrInvalidEtf)
+	}
+	out.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	return nil
+}
+
+// appendEtf appends value, as decoded by encoding/json with UseNumber, to out as an ETF term.
+func appendEtf(out []byte, value any) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return appendEtfAtom(out, "nil"), nil
+	case bool:
+		return appendEtfAtom(out, strconv.FormatBool(v)), nil
+	case json.Number:
+		return appendEtfNumber(out, v)
+	case string:
+		return appendEtfBinary(out, v), nil
+	case []any:
+		if len(v) == 0 {
+			return append(out, etfNil), nil
+		}
+		out = append(out, etfList)
+		out = binary.BigEndian.AppendUint32(out, uint32(len(v)))
+		for _, elem := range v {
+			var err error
+			if out, err = appendEtf(out, elem); err != nil {
+				return nil, err
+			}
+		}
+		return append(out, etfNil), nil
+	case map[string]any:
+		out = append(out, etfMap)
+		out = binary.BigEndian.AppendUint32(out, uint32(len(v)))
+		for key, elem := range v {
+			var err error
+			if out, err = appendEtf(out, key); err != nil {
+				return nil, err
+			}
+			if out, err = appendEtf(out, elem); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("can't encode %T as etf", value)
+	}
+}
+
+func appendEtfAtom(out []byte, atom string) []byte {
+	out = append(out, etfSmallAtomUtf, byte(len(atom)))
+	return append(out, atom...)
+}
+
+func appendEtfNumber(out []byte, n json.Number) ([]byte, error) {
+	if i, err := strconv.ParseInt(n.String(), 10, 64); err == nil {
+		return appendEtfInt(out, i), nil
+	}
+	if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+		return appendEtfBig(out, u, false), nil
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	return appendEtfFloat(out, f), nil
+}
+
+func appendEtfInt(out []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= math.MaxUint8:
+		return append(out, etfSmallInteger, byte(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		out = append(out, etfInteger)
+		return binary.BigEndian.AppendUint32(out, uint32(int32(i)))
+	case i < 0:
+		return appendEtfBig(out, uint64(-(i+1))+1, true) // -(i+1) can't overflow for math.MinInt64
+	}
+	return appendEtfBig(out, uint64(i), false)
+}
+
+func appendEtfUint(out []byte, u uint64) []byte {
+	if u <= math.MaxInt32 {
+		return appendEtfInt(out, int64(u))
+	}
+	return appendEtfBig(out, u, false)
+}
+
+func appendEtfFloat(out []byte, f float64) []byte {
+	out = append(out, etfNewFloat)
+	return binary.BigEndian.AppendUint64(out, math.Float64bits(f))
+}
+
+func appendEtfBinary(out []byte, b string) []byte {
+	out = append(out, etfBinary)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(b)))
+	return append(out, b...)
+}
+
+func appendEtfBig(out []byte, v uint64, negative bool) []byte {
+	var digits []byte
+	for ; v > 0; v >>= 8 {
+		digits = append(digits, byte(v))
+	}
+
+	sign := byte(0)
+	if negative {
+		sign = 1
+	}
+	out = append(out, etfSmallBig, byte(len(digits)), sign)
+	return append(out, digits...)
+}
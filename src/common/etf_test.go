@@ -0,0 +1,173 @@
+package common
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// etfTestBinary and friends build terms by hand, so the decoder isn't only tested against its own encoder
+func etfTestBinary(s string) []byte {
+	return append(binary.BigEndian.AppendUint32([]byte{etfBinary}, uint32(len(s))), s...)
+}
+
+func etfTestAtom(s string) []byte {
+	return append([]byte{etfSmallAtomUtf, byte(len(s))}, s...)
+}
+
+func etfTestMap(pairs ...[]byte) []byte {
+	out := binary.BigEndian.AppendUint32([]byte{etfMap}, uint32(len(pairs)/2))
+	for _, p := range pairs {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// Tests that a MESSAGE_CREATE payload encoded the way discord encodes it decodes into CreateMessagePayload
+func TestEtfDecodeMessage(t *testing.T) {
+	snowflake := []byte{etfSmallBig, 8, 0, 0x01, 0x30, 0x44, 0x8e, 0xd1, 0x2a, 0x23, 0x10} // 1162820208315084801
+	term := append([]byte{etfVersion}, etfTestMap(
+		etfTestAtom("id"), snowflake,
+		etfTestBinary("content"), etfTestBinary("hello elaina"),
+		etfTestBinary("guild_id"), []byte{etfInteger, 0, 0, 0x30, 0x39},
+		etfTestBinary("tts"), etfTestAtom("false"),
+		etfTestBinary("pinned"), etfTestAtom("true"),
+		etfTestBinary("member"), etfTestAtom("nil"),
+		etfTestBinary("mentions"), []byte{etfNil},
+		etfTestBinary("author"), etfTestMap(
+			etfTestBinary("id"), []byte{etfSmallInteger, 7},
+			etfTestBinary("username"), []byte{etfString, 0, 6, 'e', 'l', 'a', 'i', 'n', 'a'},
+		),
+	)...)
+
+	var payload CreateMessagePayload
+	require.NoError(t, UnmarshalEtf(term, &payload))
+
+	// TEST CASE: Snowflakes encoded as big integers and regular integers decode into StringInt64
+	assert.Equal(t, Snowflake(1162820208315084801), payload.Id)
+	assert.Equal(t, Snowflake(12345), payload.GuildId)
+	assert.Equal(t, Snowflake(7), payload.Author.Id)
+
+	// TEST CASE: Binaries, strings and atoms decode into their go equivalents
+	assert.Equal(t, "hello elaina", payload.Content)
+	assert.Equal(t, "elaina", payload.Author.Username)
+	assert.False(t, payload.Tts)
+	assert.True(t, payload.Pinned)
+	assert.Nil(t, payload.Member)
+	assert.Empty(t, payload.Mentions)
+}
+
+// Tests that values survive being encoded to and decoded from ETF
+func TestEtfRoundTrip(t *testing.T) {
+	type testPayload struct {
+		Id       Snowflake      `json:"id"`
+		Small    int            `json:"small"`
+		Negative int            `json:"negative"`
+		Large    int64          `json:"large"`
+		Float    float64        `json:"float"`
+		Text     string         `json:"text"`
+		Null     *string        `json:"null"`
+		List     []int          `json:"list"`
+		Map      map[string]int `json:"map"`
+	}
+	in := testPayload{
+		Id:       1162820208315084801,
+		Small:    200,
+		Negative: -5000,
+		Large:    -1 << 40,
+		Float:    0.25,
+		Text:     "Elaina ✨",
+		List:     []int{1, 2, 3},
+		Map:      map[string]int{"a": 1},
+	}
+
+	enc, err := MarshalEtf(&in)
+	require.NoError(t, err)
+
+	var out testPayload
+	require.NoError(t, UnmarshalEtf(enc, &out))
+	assert.Equal(t, in, out)
+}
+
+// Tests that malformed terms are rejected instead of panicking
+func TestEtfInvalid(t *testing.T) {
+	_, err := EtfToJson([]byte{})
+	assert.ErrorIs(t, err, ErrInvalidEtf)
+
+	_, err = EtfToJson([]byte{etfVersion, etfBinary, 0, 0, 0, 10, 'a'})
+	assert.ErrorIs(t, err, ErrInvalidEtf)
+
+	_, err = EtfToJson([]byte{etfVersion, 42})
+	assert.ErrorIs(t, err, ErrInvalidEtf)
+}
+
+// Tests that compressed terms can't make the decoder allocate more than the term could inflate to
+func TestEtfCompressedSize(t *testing.T) {
+	compressed := func(term []byte, size uint32) []byte {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		_, _ = zw.Write(term)
+		_ = zw.Close()
+		out := binary.BigEndian.AppendUint32([]byte{etfVersion, etfCompressed}, size)
+		return append(out, buf.Bytes()...)
+	}
+	term := etfTestBinary("elaina")
+
+	// TEST CASE: Compressed terms with the right size decode
+	var s string
+	require.NoError(t, UnmarshalEtf(compressed(term, uint32(len(term))), &s))
+	assert.Equal(t, "elaina", s)
+
+	// TEST CASE: A size which can't be inflated from the remaining data is rejected before allocating
+	assert.ErrorIs(t, UnmarshalEtf(compressed(term, math.MaxUint32), &s), ErrInvalidEtf)
+
+	// TEST CASE: A size which doesn't match the inflated term is rejected
+	assert.ErrorIs(t, UnmarshalEtf(compressed(term, uint32(len(term))+100), &s), ErrInvalidEtf)
+}
+
+// Tests that EtfTerm keeps a nested term undecoded, and that embedded and pointer fields decode like JSON
+func TestEtfDecodeValues(t *testing.T) {
+	type embedded struct {
+		Nonce string `json:"nonce"`
+	}
+	type payload struct {
+		embedded
+		Op    int     `json:"op"`
+		Data  EtfTerm `json:"d"`
+		Seq   *int32  `json:"s"`
+		Perms int64   `json:"permissions,string"`
+	}
+	data := etfTestMap(etfTestBinary("id"), []byte{etfSmallInteger, 5})
+	term := append([]byte{etfVersion}, etfTestMap(
+		etfTestBinary("op"), []byte{etfSmallInteger, 0},
+		etfTestBinary("d"), data,
+		etfTestBinary("s"), etfTestAtom("nil"),
+		etfTestBinary("t"), etfTestBinary("unknown fields are skipped"),
+		etfTestBinary("nonce"), etfTestBinary("abc"),
+		etfTestBinary("permissions"), etfTestBinary("8192"),
+	)...)
+
+	var out payload
+	require.NoError(t, UnmarshalEtf(term, &out))
+
+	// TEST CASE: EtfTerm holds the nested term with a version byte, so it can be decoded later
+	assert.Equal(t, append([]byte{etfVersion}, data...), []byte(out.Data))
+	var inner struct {
+		Id Snowflake `json:"id"`
+	}
+	require.NoError(t, UnmarshalEtf(out.Data, &inner))
+	assert.Equal(t, Snowflake(5), inner.Id)
+
+	// TEST CASE: Embedded fields are promoted, nil leaves pointers nil and ",string" numbers are parsed
+	assert.Equal(t, "abc", out.Nonce)
+	assert.Nil(t, out.Seq)
+	assert.EqualValues(t, 8192, out.Perms)
+
+	// TEST CASE: Terms which don't fit the value are rejected
+	assert.ErrorIs(t, UnmarshalEtf(append([]byte{etfVersion}, etfTestBinary("a")...), &out.Op), ErrInvalidEtf)
+}
@@ -0,0 +1,841 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EtfTerm is an ETF term which hasn't been decoded yet, starting with the version byte. UnmarshalEtf stores the term as
+// is, so it can be decoded once its type is known, like json.RawMessage.
+type EtfTerm []byte
+
+// etfUnmarshaler is implemented by types which decode themselves from a term without transcoding it into JSON first.
+type etfUnmarshaler interface {
+	unmarshalEtf(r *etfReader) error
+}
+
+var (
+	etfTermType         = reflect.TypeFor[EtfTerm]()
+	etfUnmarshalerType  = reflect.TypeFor[etfUnmarshaler]()
+	jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+	jsonMarshalerType   = reflect.TypeFor[json.Marshaler]()
+)
+
+// MarshalEtf encodes v as an ETF term, using the same field names and options as json.Marshal. Types implementing
+// json.Marshaler are encoded from the JSON they marshal to.
+func MarshalEtf(v any) ([]byte, error) {
+	return appendEtfValue([]byte{etfVersion}, reflect.ValueOf(v), false)
+}
+
+// UnmarshalEtf decodes an ETF term into v, which must be a non-nil pointer. Values are decoded straight from the term
+// using the same field names as json.Unmarshal, which must match exactly. Types implementing json.Unmarshaler, and
+// empty interfaces, are decoded from the term transcoded into JSON instead.
+func UnmarshalEtf(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("etf: can't unmarshal into %T", v)
+	}
+
+	r, err := newEtfReader(data)
+	if err != nil {
+		return err
+	}
+	return r.value(rv.Elem(), false)
+}
+
+// etfField is a struct field with the name and options of its json tag, see etfFieldsOf
+type etfField struct {
+	name      string
+	index     []int
+	quoted    bool // Numbers and booleans are encoded as strings, set with the json ",string" option
+	omitEmpty bool
+}
+
+type etfStruct struct {
+	fields []etfField
+	byName map[string]*etfField
+}
+
+var etfStructs sync.Map // reflect.Type to *etfStruct
+
+// etfFieldsOf returns the fields of struct type t as encoding/json sees them. Fields of embedded structs are promoted,
+// and fields closer to t take precedence over promoted fields with the same name.
+func etfFieldsOf(t reflect.Type) *etfStruct {
+	if cached, ok := etfStructs.Load(t); ok {
+		return cached.(*etfStruct)
+	}
+
+	type embedded struct {
+		t     reflect.Type
+		index []int
+	}
+	s := &etfStruct{byName: make(map[string]*etfField)}
+	for level := []embedded{{t: t}}; len(level) > 0; {
+		var next []embedded
+		for _, e := range level {
+			for i := range e.t.NumField() {
+				f := e.t.Field(i)
+				tag := f.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				index := append(append([]int(nil), e.index...), i)
+
+				if f.Anonymous && name == "" {
+					ft := f.Type
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct {
+						next = append(next, embedded{t: ft, index: index})
+						continue
+					}
+				}
+				if !f.IsExported() {
+					continue
+				}
+				if name == "" {
+					name = f.Name
+				}
+				if _, exists := s.byName[name]; exists {
+					continue
+				}
+				s.fields = append(s.fields, etfField{
+					name:      name,
+					index:     index,
+					quoted:    strings.Contains(","+opts+",", ",string,"),
+					omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+				})
+				s.byName[name] = nil // Reserved, pointers are taken once fields stops growing
+			}
+		}
+		level = next
+	}
+	for i := range s.fields {
+		s.byName[s.fields[i].name] = &s.fields[i]
+	}
+
+	cached, _ := etfStructs.LoadOrStore(t, s)
+	return cached.(*etfStruct)
+}
+
+// value decodes the next term into v. If quoted is set, numbers may be sent as binaries, see etfField.quoted
+func (r *etfReader) value(v reflect.Value, quoted bool) error {
+	if v.Type() == etfTermType {
+		start := r.pos
+		if err := r.skip(); err != nil {
+			return err
+		}
+		v.SetBytes(append([]byte{etfVersion}, r.data[start:r.pos]...))
+		return nil
+	}
+
+	if v.Kind() == reflect.Pointer {
+		if r.skipNil() {
+			v.SetZero()
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return r.value(v.Elem(), quoted)
+	}
+
+	if v.CanAddr() {
+		ptr := v.Addr()
+		switch {
+		case ptr.Type().Implements(etfUnmarshalerType):
+			return ptr.Interface().(etfUnmarshaler).unmarshalEtf(r)
+		case ptr.Type().Implements(jsonUnmarshalerType):
+			var buf bytes.Buffer
+			if err := r.term(&buf); err != nil {
+				return err
+			}
+			return ptr.Interface().(json.Unmarshaler).UnmarshalJSON(buf.Bytes())
+		case v.Kind() == reflect.Interface && v.NumMethod() == 0:
+			var buf bytes.Buffer
+			if err := r.term(&buf); err != nil {
+				return err
+			}
+			return json.Unmarshal(buf.Bytes(), ptr.Interface())
+		}
+	}
+
+	tag, err := r.uint8()
+	if err != nil {
+		return err
+	}
+
+	switch tag {
+	case etfSmallInteger, etfInteger, etfSmallBig, etfLargeBig:
+		magnitude, negative, err := r.integer(tag)
+		if err != nil {
+			return err
+		}
+		return setEtfInt(v, magnitude, negative)
+	case etfNewFloat, etfFloat:
+		f, err := r.float(tag)
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(f)
+			return nil
+		}
+		return etfTypeError("float", v)
+	case etfAtom, etfAtomUtf8, etfSmallAtom, etfSmallAtomUtf:
+		atom, err := r.atomName(tag)
+		if err != nil {
+			return err
+		}
+		switch string(atom) {
+		case "nil", "null":
+			switch v.Kind() {
+			case reflect.Map, reflect.Slice:
+				v.SetZero()
+			} // Like JSON, null leaves other values unchanged
+			return nil
+		case "true", "false":
+			if v.Kind() != reflect.Bool {
+				return etfTypeError("boolean", v)
+			}
+			v.SetBool(atom[0] == 't')
+			return nil
+		}
+		return setEtfString(v, atom, quoted)
+	case etfBinary:
+		n, err := r.uint32()
+		if err != nil {
+			return err
+		}
+		b, err := r.read(n)
+		if err != nil {
+			return err
+		}
+		return setEtfString(v, b, quoted)
+	case etfString: // Lists of bytes, which are strings unless decoded into a slice
+		n, err := r.uint16()
+		if err != nil {
+			return err
+		}
+		b, err := r.read(n)
+		if err != nil {
+			return err
+		}
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return setEtfString(v, b, quoted)
+		}
+		return r.byteList(v, b)
+	case etfNil:
+		switch v.Kind() {
+		case reflect.Slice:
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		case reflect.Array:
+			v.SetZero()
+		case reflect.String:
+			v.SetString("")
+		default:
+			return etfTypeError("list", v)
+		}
+		return nil
+	case etfList, etfSmallTuple, etfLargeTuple:
+		var n int
+		if tag == etfSmallTuple {
+			n, err = r.uint8()
+		} else {
+			n, err = r.uint32()
+		}
+		if err != nil {
+			return err
+		}
+		if err = r.list(v, n); err != nil {
+			return err
+		}
+		if tag != etfList {
+			return nil
+		}
+		tail, err := r.uint8() // Proper lists always end in NIL_EXT
+		if err != nil {
+			return err
+		}
+		if tail != etfNil {
+			return fmt.Errorf("%w: improper lists are not supported", ErrInvalidEtf)
+		}
+		return nil
+	case etfMap:
+		n, err := r.uint32()
+		if err != nil {
+			return err
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			return r.structFields(v, n)
+		case reflect.Map:
+			return r.mapEntries(v, n)
+		}
+		return etfTypeError("map", v)
+	}
+	return fmt.Errorf("%w: unsupported tag %d", ErrInvalidEtf, tag)
+}
+
+// skipNil reads the next term and returns true if it is the atom nil. Otherwise, nothing is read.
+func (r *etfReader) skipNil() bool {
+	rest := r.data[r.pos:]
+	switch {
+	case len(rest) >= 5 && (rest[0] == etfSmallAtom || rest[0] == etfSmallAtomUtf) && rest[1] == 3 && string(rest[2:5]) == "nil":
+		r.pos += 5
+	case len(rest) >= 6 && (rest[0] == etfAtom || rest[0] == etfAtomUtf8) && rest[1] == 0 && rest[2] == 3 && string(rest[3:6]) == "nil":
+		r.pos += 6
+	default:
+		return false
+	}
+	return true
+}
+
+// integer reads the integer following tag, returning its magnitude and sign. Integers wider than 64 bits are rejected.
+func (r *etfReader) integer(tag int) (magnitude uint64, negative bool, err error) {
+	switch tag {
+	case etfSmallInteger:
+		v, err := r.uint8()
+		return uint64(v), false, err
+	case etfInteger:
+		b, err := r.read(4)
+		if err != nil {
+			return 0, false, err
+		}
+		v := int64(int32(binary.BigEndian.Uint32(b)))
+		if v < 0 {
+			return uint64(-v), true, nil
+		}
+		return uint64(v), false, nil
+	}
+
+	var n int
+	if tag == etfSmallBig {
+		n, err = r.uint8()
+	} else {
+		n, err = r.uint32()
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	sign, err := r.uint8()
+	if err != nil {
+		return 0, false, err
+	}
+	digits, err := r.read(n)
+	if err != nil {
+		return 0, false, err
+	}
+	if n > 8 {
+		return 0, false, fmt.Errorf("%w: integer doesn't fit in 64 bits", ErrInvalidEtf)
+	}
+	for i := n - 1; i >= 0; i-- {
+		magnitude = magnitude<<8 | uint64(digits[i])
+	}
+	return magnitude, sign != 0, nil
+}
+
+// float reads the float following tag, which is either a NEW_FLOAT_EXT or the older string based FLOAT_EXT.
+func (r *etfReader) float(tag int) (float64, error) {
+	if tag == etfNewFloat {
+		b, err := r.read(8)
+		if err != nil {
+			return 0, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	}
+
+	b, err := r.read(31)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(string(bytes.TrimRight(b, "\x00")), 64)
+	if err != nil {
+		return 0, ErrInvalidEtf
+	}
+	return f, nil
+}
+
+// atomName reads the name of the atom following tag
+func (r *etfReader) atomName(tag int) ([]byte, error) {
+	var n int
+	var err error
+	if tag == etfAtom || tag == etfAtomUtf8 {
+		n, err = r.uint16()
+	} else {
+		n, err = r.uint8()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.read(n)
+}
+
+// key reads a map key, which discord sends as binaries or atoms. Integer keys are formatted as decimal.
+func (r *etfReader) key() ([]byte, error) {
+	tag, err := r.uint8()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tag {
+	case etfBinary:
+		n, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		return r.read(n)
+	case etfString:
+		n, err := r.uint16()
+		if err != nil {
+			return nil, err
+		}
+		return r.read(n)
+	case etfAtom, etfAtomUtf8, etfSmallAtom, etfSmallAtomUtf:
+		return r.atomName(tag)
+	case etfSmallInteger, etfInteger, etfSmallBig, etfLargeBig:
+		magnitude, negative, err := r.integer(tag)
+		if err != nil {
+			return nil, err
+		}
+		key := strconv.FormatUint(magnitude, 10)
+		if negative {
+			key = "-" + key
+		}
+		return []byte(key), nil
+	}
+	return nil, fmt.Errorf("%w: unsupported map key tag %d", ErrInvalidEtf, tag)
+}
+
+// structFields decodes n map entries into the fields of struct v. Entries without a matching field are skipped.
+func (r *etfReader) structFields(v reflect.Value, n int) error {
+	fields := etfFieldsOf(v.Type())
+	for range n {
+		key, err := r.key()
+		if err != nil {
+			return err
+		}
+
+		field := fields.byName[string(key)]
+		if field == nil {
+			if err = r.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = r.value(etfFieldByIndex(v, field.index, true), field.quoted); err != nil {
+			return fmt.Errorf("%s: %w", field.name, err)
+		}
+	}
+	return nil
+}
+
+// mapEntries decodes n map entries into map v. Keys are decoded like encoding/json decodes object keys.
+func (r *etfReader) mapEntries(v reflect.Value, n int) error {
+	t := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(t, n))
+	}
+
+	for range n {
+		raw, err := r.key()
+		if err != nil {
+			return err
+		}
+		key := reflect.New(t.Key()).Elem()
+		switch t.Key().Kind() {
+		case reflect.String:
+			key.SetString(string(raw))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i, err := strconv.ParseInt(string(raw), 10, 64)
+			if err != nil || key.OverflowInt(i) {
+				return fmt.Errorf("%w: invalid map key %q for %s", ErrInvalidEtf, raw, t)
+			}
+			key.SetInt(i)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			u, err := strconv.ParseUint(string(raw), 10, 64)
+			if err != nil || key.OverflowUint(u) {
+				return fmt.Errorf("%w: invalid map key %q for %s", ErrInvalidEtf, raw, t)
+			}
+			key.SetUint(u)
+		default:
+			return etfTypeError("map", v)
+		}
+
+		elem := reflect.New(t.Elem()).Elem()
+		if err = r.value(elem, false); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+// list decodes n terms into slice or array v
+func (r *etfReader) list(v reflect.Value, n int) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		if n > len(r.data)-r.pos { // Every term is at least one byte, so don't trust n any further than that
+			return ErrInvalidEtf
+		}
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+	case reflect.Array:
+		v.SetZero()
+	default:
+		return etfTypeError("list", v)
+	}
+
+	for i := range n {
+		if i >= v.Len() { // Arrays drop extra elements, like encoding/json
+			if err := r.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := r.value(v.Index(i), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// byteList decodes a STRING_EXT into slice or array v, one element per byte
+func (r *etfReader) byteList(v reflect.Value, b []byte) error {
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), len(b), len(b)))
+	} else {
+		v.SetZero()
+	}
+	for i, c := range b {
+		if i >= v.Len() {
+			break
+		}
+		if err := setEtfInt(v.Index(i), uint64(c), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skip reads past the next term without decoding it
+func (r *etfReader) skip() error {
+	tag, err := r.uint8()
+	if err != nil {
+		return err
+	}
+
+	n := 0
+	switch tag {
+	case etfSmallInteger:
+		_, err = r.read(1)
+	case etfInteger:
+		_, err = r.read(4)
+	case etfNewFloat:
+		_, err = r.read(8)
+	case etfFloat:
+		_, err = r.read(31)
+	case etfSmallBig:
+		if n, err = r.uint8(); err == nil {
+			_, err = r.read(n + 1) // Sign and digits
+		}
+	case etfLargeBig:
+		if n, err = r.uint32(); err == nil {
+			_, err = r.read(n + 1)
+		}
+	case etfAtom, etfAtomUtf8, etfSmallAtom, etfSmallAtomUtf:
+		_, err = r.atomName(tag)
+	case etfBinary:
+		if n, err = r.uint32(); err == nil {
+			_, err = r.read(n)
+		}
+	case etfString:
+		if n, err = r.uint16(); err == nil {
+			_, err = r.read(n)
+		}
+	case etfNil:
+	case etfList, etfLargeTuple, etfSmallTuple, etfMap:
+		if tag == etfSmallTuple {
+			n, err = r.uint8()
+		} else {
+			n, err = r.uint32()
+		}
+		switch tag {
+		case etfList:
+			n++ // Tail
+		case etfMap:
+			n *= 2 // Keys and values
+		}
+		for i := 0; i < n && err == nil; i++ {
+			err = r.skip()
+		}
+	default:
+		return fmt.Errorf("%w: unsupported tag %d", ErrInvalidEtf, tag)
+	}
+	return err
+}
+
+func setEtfInt(v reflect.Value, magnitude uint64, negative bool) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if magnitude > 1<<63 || !negative && magnitude == 1<<63 {
+			return etfTypeError("integer", v)
+		}
+		i := int64(magnitude) // 1<<63 wraps to math.MinInt64, which is what a negative magnitude of 1<<63 is
+		if negative {
+			i = -i
+		}
+		if v.OverflowInt(i) {
+			return etfTypeError("integer", v)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if negative || v.OverflowUint(magnitude) {
+			return etfTypeError("integer", v)
+		}
+		v.SetUint(magnitude)
+	case reflect.Float32, reflect.Float64:
+		f := float64(magnitude)
+		if negative {
+			f = -f
+		}
+		v.SetFloat(f)
+	default:
+		return etfTypeError("integer", v)
+	}
+	return nil
+}
+
+// setEtfString sets v to the string b. If quoted is set, numbers and booleans are parsed from b instead.
+func setEtfString(v reflect.Value, b []byte, quoted bool) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(b))
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 { // Binaries are already bytes, so they aren't base64 encoded like JSON
+			v.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+	}
+	if !quoted {
+		return etfTypeError("string", v)
+	}
+
+	s := string(b)
+	switch v.Kind() {
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(s)
+		if err != nil {
+			return etfTypeError("string", v)
+		}
+		v.SetBool(parsed)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return etfTypeError("string", v)
+		}
+		v.SetFloat(f)
+		return nil
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	magnitude, err := strconv.ParseUint(strings.TrimPrefix(s, "-"), 10, 64)
+	if err != nil {
+		return etfTypeError("string", v)
+	}
+	return setEtfInt(v, magnitude, negative)
+}
+
+func etfTypeError(term string, v reflect.Value) error {
+	return fmt.Errorf("%w: can't decode %s into %s", ErrInvalidEtf, term, v.Type())
+}
+
+// etfFieldByIndex returns the field of struct v at index. Nil embedded pointers are allocated if alloc is set,
+// otherwise an invalid value is returned for fields behind them.
+func etfFieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, field := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(field)
+	}
+	return v
+}
+
+// appendEtfValue appends v to out as an ETF term, see MarshalEtf. If quoted is set, numbers and booleans are encoded
+// as binaries.
+func appendEtfValue(out []byte, v reflect.Value, quoted bool) ([]byte, error) {
+	if !v.IsValid() {
+		return appendEtfAtom(out, "nil"), nil
+	}
+	if marshaler, ok := jsonMarshalerOf(v); ok {
+		enc, err := marshaler.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		return appendJsonEtf(out, enc)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return appendEtfAtom(out, "nil"), nil
+		}
+		return appendEtfValue(out, v.Elem(), quoted)
+	case reflect.Bool:
+		if quoted {
+			return appendEtfBinary(out, strconv.FormatBool(v.Bool())), nil
+		}
+		return appendEtfAtom(out, strconv.FormatBool(v.Bool())), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if quoted {
+			return appendEtfBinary(out, strconv.FormatInt(v.Int(), 10)), nil
+		}
+		return appendEtfInt(out, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if quoted {
+			return appendEtfBinary(out, strconv.FormatUint(v.Uint(), 10)), nil
+		}
+		return appendEtfUint(out, v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("can't encode %v as etf", f)
+		}
+		if quoted {
+			return appendEtfBinary(out, strconv.FormatFloat(f, 'g', -1, 64)), nil
+		}
+		return appendEtfFloat(out, f), nil
+	case reflect.String:
+		return appendEtfBinary(out, v.String()), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return appendEtfAtom(out, "nil"), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendEtfBinary(out, string(v.Bytes())), nil
+		}
+		fallthrough
+	case reflect.Array:
+		if v.Len() == 0 {
+			return append(out, etfNil), nil
+		}
+		out = append(out, etfList)
+		out = binary.BigEndian.AppendUint32(out, uint32(v.Len()))
+		for i := range v.Len() {
+			var err error
+			if out, err = appendEtfValue(out, v.Index(i), false); err != nil {
+				return nil, err
+			}
+		}
+		return append(out, etfNil), nil
+	case reflect.Map:
+		if v.IsNil() {
+			return appendEtfAtom(out, "nil"), nil
+		}
+		out = append(out, etfMap)
+		out = binary.BigEndian.AppendUint32(out, uint32(v.Len()))
+		for iter := v.MapRange(); iter.Next(); {
+			key := iter.Key()
+			switch key.Kind() {
+			case reflect.String:
+				out = appendEtfBinary(out, key.String())
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				out = appendEtfBinary(out, strconv.FormatInt(key.Int(), 10))
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				out = appendEtfBinary(out, strconv.FormatUint(key.Uint(), 10))
+			default:
+				return nil, fmt.Errorf("can't encode map key %s as etf", key.Type())
+			}
+
+			var err error
+			if out, err = appendEtfValue(out, iter.Value(), false); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case reflect.Struct:
+		return appendEtfStruct(out, v)
+	}
+	return nil, fmt.Errorf("can't encode %s as etf", v.Type())
+}
+
+// appendEtfStruct appends struct v as a map of its fields, leaving out empty fields tagged with omitempty
+func appendEtfStruct(out []byte, v reflect.Value) ([]byte, error) {
+	fields := etfFieldsOf(v.Type()).fields
+	values := make([]reflect.Value, len(fields))
+	count := 0
+	for i, field := range fields {
+		fv := etfFieldByIndex(v, field.index, false)
+		if !fv.IsValid() || field.omitEmpty && isEmptyEtfValue(fv) {
+			continue
+		}
+		values[i] = fv
+		count++
+	}
+
+	out = append(out, etfMap)
+	out = binary.BigEndian.AppendUint32(out, uint32(count))
+	for i, field := range fields {
+		if !values[i].IsValid() {
+			continue
+		}
+		out = appendEtfBinary(out, field.name)
+
+		var err error
+		if out, err = appendEtfValue(out, values[i], field.quoted); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// jsonMarshalerOf returns v as a json.Marshaler if encoding/json would marshal it with MarshalJSON
+func jsonMarshalerOf(v reflect.Value) (json.Marshaler, bool) {
+	if v.Type().Implements(jsonMarshalerType) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return nil, false
+		}
+		return v.Interface().(json.Marshaler), true
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(jsonMarshalerType) {
+		return v.Addr().Interface().(json.Marshaler), true
+	}
+	return nil, false
+}
+
+// isEmptyEtfValue reports whether v is empty as defined by the json omitempty option
+func isEmptyEtfValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,308 @@
+package common
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+// State holds every guild the bot is in, kept up to date by gateway events. Unlike the LRU caches, entries are never
+// evicted, so lookups served from State never need a REST call.
+var State = NewGuildState()
+
+// GuildState stores guilds along with their roles, channels and members. Guilds are added by GUILD_CREATE and removed by
+// GUILD_DELETE, everything else is updated by the events for that resource. It is safe for concurrent use.
+//
+// Members are only stored once enabled with SetMemberCaching, as discord only sends member updates with the
+// GUILD_MEMBERS intent. Without it, stored members would never be updated or removed.
+type GuildState struct {
+	guilds        map[Snowflake]*guildEntry
+	channelGuilds map[Snowflake]Snowflake // Channel ID to the ID of the guild it belongs to
+	cacheMembers  bool
+	mutex         sync.RWMutex
+}
+
+type guildEntry struct {
+	guild       Guild
+	unavailable bool // Set while discord hasn't sent the guild yet, or during an outage. Nothing else is known about it
+	roles       map[Snowflake]Role
+	channels    map[Snowflake]Channel
+	members     map[Snowflake]GuildMember
+}
+
+func newGuildEntry(id Snowflake) *guildEntry {
+	return &guildEntry{
+		guild:    Guild{Id: id},
+		roles:    make(map[Snowflake]Role),
+		channels: make(map[Snowflake]Channel),
+		members:  make(map[Snowflake]GuildMember),
+	}
+}
+
+// NewGuildState creates an empty GuildState
+func NewGuildState() *GuildState {
+	return &GuildState{guilds: make(map[Snowflake]*guildEntry), channelGuilds: make(map[Snowflake]Snowflake)}
+}
+
+// --------------------------------------------------------------------
+// |                              GUILDS                              |
+// --------------------------------------------------------------------
+
+// AddGuild replaces everything stored for the guild with the contents of a GUILD_CREATE. If the guild is unavailable,
+// it is marked as such instead.
+func (s *GuildState) AddGuild(payload CreateGuildPayload) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.removeGuild(payload.Id)
+	entry := newGuildEntry(payload.Id)
+	s.guilds[payload.Id] = entry
+	if payload.Unavailable {
+		entry.unavailable = true
+		return
+	}
+
+	entry.guild = payload.Guild
+	for _, role := range payload.Roles {
+		entry.roles[role.Id] = role
+	}
+	for _, channels := range [][]Channel{payload.Channels, payload.Threads} {
+		for _, channel := range channels {
+			channel.GuildId = payload.Id // Channels in GUILD_CREATE don't include their guild
+			entry.channels[channel.Id] = channel
+			s.channelGuilds[channel.Id] = payload.Id
+		}
+	}
+	for _, member := range payload.Members {
+		if s.cacheMembers && member.User != nil {
+			entry.members[member.User.Id] = member
+		}
+	}
+}
+
+// SetUnavailable marks the given guilds as unavailable, discarding anything stored for them. Discord will send a
+// GUILD_CREATE for each of them once they are available.
+func (s *GuildState) SetUnavailable(guilds ...Snowflake) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, id := range guilds {
+		s.removeGuild(id)
+		entry := newGuildEntry(id)
+		entry.unavailable = true
+		s.guilds[id] = entry
+	}
+}
+
+// UpdateGuild replaces the guild object of a stored guild, keeping its roles, channels and members.
+func (s *GuildState) UpdateGuild(guild Guild) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if entry := s.guilds[guild.Id]; entry != nil && !entry.unavailable {
+		entry.guild = guild
+	}
+}
+
+// RemoveGuild discards the guild and everything belonging to it
+func (s *GuildState) RemoveGuild(id Snowflake) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.removeGuild(id)
+}
+
+func (s *GuildState) removeGuild(id Snowflake) {
+	entry := s.guilds[id]
+	if entry == nil {
+		return
+	}
+	for channel := range entry.channels {
+		delete(s.channelGuilds, channel)
+	}
+	delete(s.guilds, id)
+}
+
+// Guild returns a copy of the guild, or nil if it isn't stored or is unavailable
+func (s *GuildState) Guild(id Snowflake) *Guild {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if entry := s.available(id); entry != nil {
+		guild := entry.guild
+		return &guild
+	}
+	return nil
+}
+
+// GuildCount returns the number of stored guilds, including unavailable ones
+func (s *GuildState) GuildCount() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.guilds)
+}
+
+// IsUnavailable returns true if the guild is stored, but is unavailable
+func (s *GuildState) IsUnavailable(id Snowflake) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	entry := s.guilds[id]
+	return entry != nil && entry.unavailable
+}
+
+// GuildIds returns the ID of every stored guild, including unavailable ones
+func (s *GuildState) GuildIds() []Snowflake {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return slices.Collect(maps.Keys(s.guilds))
+}
+
+// available returns the entry for the guild if it is stored and available. The caller must hold the mutex.
+func (s *GuildState) available(id Snowflake) *guildEntry {
+	if entry := s.guilds[id]; entry != nil && !entry.unavailable {
+		return entry
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------
+// |                             CHANNELS                             |
+// --------------------------------------------------------------------
+
+// SetChannel adds or replaces a guild channel. Channels without a guild, or belonging to a guild which isn't stored,
+// are ignored.
+func (s *GuildState) SetChannel(channel Channel) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if entry := s.available(channel.GuildId); entry != nil {
+		entry.channels[channel.Id] = channel
+		s.channelGuilds[channel.Id] = channel.GuildId
+	}
+}
+
+// RemoveChannel discards a guild channel
+func (s *GuildState) RemoveChannel(id Snowflake) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if entry := s.guilds[s.channelGuilds[id]]; entry != nil {
+		delete(entry.channels, id)
+	}
+	delete(s.channelGuilds, id)
+}
+
+// Channel returns a copy of the guild channel, or nil if it isn't stored
+func (s *GuildState) Channel(id Snowflake) *Channel {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	guild, exists := s.channelGuilds[id]
+	if !exists {
+		return nil
+	}
+	if entry := s.available(guild); entry != nil {
+		if channel, exists := entry.channels[id]; exists {
+			return &channel
+		}
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------
+// |                              ROLES                               |
+// --------------------------------------------------------------------
+
+// SetRole adds or replaces a role of a stored guild
+func (s *GuildState) SetRole(guild Snowflake, role Role) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if entry := s.available(guild); entry != nil {
+		entry.roles[role.Id] = role
+	}
+}
+
+// RemoveRole discards a role, and removes it from every stored member of the guild
+func (s *GuildState) RemoveRole(guild Snowflake, role Snowflake) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entry := s.available(guild)
+	if entry == nil {
+		return
+	}
+	delete(entry.roles, role)
+	for id, member := range entry.members {
+		for i, r := range member.Roles {
+			if r == role {
+				member.Roles = append(member.Roles[:i:i], member.Roles[i+1:]...) // Copy, the old slice may be shared with a caller
+				entry.members[id] = member
+				break
+			}
+		}
+	}
+}
+
+// Role returns a copy of the role, or nil if it isn't stored
+func (s *GuildState) Role(guild Snowflake, role Snowflake) *Role {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if entry := s.available(guild); entry != nil {
+		if r, exists := entry.roles[role]; exists {
+			return &r
+		}
+	}
+	return nil
+}
+
+// Roles returns a copy of every role of the guild, keyed by ID, or nil if the guild isn't stored or is unavailable
+func (s *GuildState) Roles(guild Snowflake) map[Snowflake]Role {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if entry := s.available(guild); entry != nil {
+		return maps.Clone(entry.roles)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------
+// |                             MEMBERS                              |
+// --------------------------------------------------------------------
+
+// SetMemberCaching sets whether members are stored. Disabling it discards every stored member.
+func (s *GuildState) SetMemberCaching(enabled bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cacheMembers = enabled
+	if !enabled {
+		for _, entry := range s.guilds {
+			clear(entry.members)
+		}
+	}
+}
+
+// SetMember adds or replaces a member of a stored guild. Members without a user, or any member while member caching is
+// disabled, are ignored.
+func (s *GuildState) SetMember(guild Snowflake, member GuildMember) {
+	if member.User == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if entry := s.available(guild); entry != nil && s.cacheMembers {
+		entry.members[member.User.Id] = member
+	}
+}
+
+// RemoveMember discards a member of a guild
+func (s *GuildState) RemoveMember(guild Snowflake, user Snowflake) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if entry := s.available(guild); entry != nil {
+		delete(entry.members, user)
+	}
+}
+
+// Member returns a copy of the guild member, or nil if it isn't stored
+func (s *GuildState) Member(guild Snowflake, user Snowflake) *GuildMember {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if entry := s.available(guild); entry != nil {
+		if member, exists := entry.members[user]; exists {
+			return &member
+		}
+	}
+	return nil
+}
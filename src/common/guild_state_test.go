@@ -0,0 +1,112 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testGuildPayload() CreateGuildPayload {
+	return CreateGuildPayload{
+		Guild:    Guild{Id: 1, Name: "Guild", OwnerId: 100},
+		Roles:    []Role{{Id: 1, Name: "@everyone", Permissions: PermSendMessages}, {Id: 2, Name: "Moderator", Permissions: PermModerateMembers}},
+		Channels: []Channel{{Id: 10, Name: "general"}},
+		Threads:  []Channel{{Id: 11, Name: "thread"}},
+		Members:  []GuildMember{{User: &User{Id: 200}, Roles: []Snowflake{2}}},
+	}
+}
+
+// Tests that GUILD_CREATE contents can be looked up, and that updates and deletes apply to the right guild
+func TestGuildState(t *testing.T) {
+	state := NewGuildState()
+	state.SetMemberCaching(true)
+	state.AddGuild(testGuildPayload())
+
+	// TEST CASE: Everything sent in GUILD_CREATE can be looked up
+	require.NotNil(t, state.Guild(1))
+	assert.Equal(t, "Guild", state.Guild(1).Name)
+	require.NotNil(t, state.Role(1, 2))
+	assert.Equal(t, Permissions(PermModerateMembers), state.Role(1, 2).Permissions)
+	require.NotNil(t, state.Channel(10))
+	assert.Equal(t, Snowflake(1), state.Channel(10).GuildId)
+	assert.NotNil(t, state.Channel(11))
+	require.NotNil(t, state.Member(1, 200))
+	assert.Equal(t, []Snowflake{2}, state.Member(1, 200).Roles)
+
+	// TEST CASE: Every role of a guild can be looked up at once, as a copy
+	roles := state.Roles(1)
+	assert.Len(t, roles, 2)
+	delete(roles, 2)
+	assert.Len(t, state.Roles(1), 2)
+	assert.Nil(t, state.Roles(2))
+
+	// TEST CASE: Resources are added, updated and removed by their events
+	state.SetChannel(Channel{Id: 12, GuildId: 1, Name: "new"})
+	state.SetChannel(Channel{Id: 10, GuildId: 1, Name: "renamed"})
+	state.RemoveChannel(11)
+	assert.Equal(t, "new", state.Channel(12).Name)
+	assert.Equal(t, "renamed", state.Channel(10).Name)
+	assert.Nil(t, state.Channel(11))
+
+	state.SetMember(1, GuildMember{User: &User{Id: 201}})
+	state.RemoveMember(1, 200)
+	assert.NotNil(t, state.Member(1, 201))
+	assert.Nil(t, state.Member(1, 200))
+
+	// TEST CASE: Deleting a role removes it from members
+	state.SetMember(1, GuildMember{User: &User{Id: 202}, Roles: []Snowflake{1, 2}})
+	member := state.Member(1, 202)
+	state.RemoveRole(1, 2)
+	assert.Nil(t, state.Role(1, 2))
+	assert.Equal(t, []Snowflake{1}, state.Member(1, 202).Roles)
+	assert.Equal(t, []Snowflake{1, 2}, member.Roles, "copies returned before the delete must not change")
+
+	// TEST CASE: Resources of guilds which aren't stored are ignored
+	state.SetChannel(Channel{Id: 20, GuildId: 2})
+	state.SetRole(2, Role{Id: 2})
+	assert.Nil(t, state.Channel(20))
+	assert.Nil(t, state.Role(2, 2))
+
+	// TEST CASE: Unavailable guilds keep nothing until they are created again
+	state.SetUnavailable(1)
+	assert.Nil(t, state.Guild(1))
+	assert.Nil(t, state.Channel(10))
+	assert.Equal(t, 1, state.GuildCount())
+	assert.Equal(t, []Snowflake{1}, state.GuildIds())
+	assert.True(t, state.IsUnavailable(1))
+	state.AddGuild(testGuildPayload())
+	assert.False(t, state.IsUnavailable(1))
+	assert.NotNil(t, state.Guild(1))
+	assert.NotNil(t, state.Channel(10))
+
+	// TEST CASE: Removing a guild removes its channels
+	state.RemoveGuild(1)
+	assert.Nil(t, state.Guild(1))
+	assert.Nil(t, state.Channel(10))
+	assert.Equal(t, 0, state.GuildCount())
+}
+
+// Tests that members are only stored while member caching is enabled
+func TestGuildStateMemberCaching(t *testing.T) {
+	state := NewGuildState()
+
+	// TEST CASE: Members aren't stored by default
+	state.AddGuild(testGuildPayload())
+	state.SetMember(1, GuildMember{User: &User{Id: 201}})
+	assert.Nil(t, state.Member(1, 200))
+	assert.Nil(t, state.Member(1, 201))
+
+	// TEST CASE: Members are stored once enabled
+	state.SetMemberCaching(true)
+	state.AddGuild(testGuildPayload())
+	state.SetMember(1, GuildMember{User: &User{Id: 201}})
+	assert.NotNil(t, state.Member(1, 200))
+	assert.NotNil(t, state.Member(1, 201))
+
+	// TEST CASE: Disabling it discards stored members, but nothing else
+	state.SetMemberCaching(false)
+	assert.Nil(t, state.Member(1, 200))
+	assert.Nil(t, state.Member(1, 201))
+	assert.NotNil(t, state.Role(1, 2))
+}
@@ -2,6 +2,7 @@ package common
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"net/http"
@@ -10,12 +11,33 @@ import (
 	"time"
 )
 
-var httpClient = http.Client{Timeout: time.Second * 5}
+var httpClient = &http.Client{Timeout: time.Second * 5}
+var apiUrl = BaseApiUrl
+
+// SetHttpTransport replaces how every HTTP request is sent, e.g. to send REST calls to a stub when replaying events.
+func SetHttpTransport(transport http.RoundTripper) {
+	httpClient.Transport = transport
+}
+
+// SetHttpClient replaces the client every HTTP request is sent with. It should not be changed while requests are sent.
+func SetHttpClient(client *http.Client) {
+	httpClient = client
+}
+
+// SetApiUrl replaces the base URL of the discord API, e.g. to send REST calls to a fake discord in tests. An empty url
+// restores BaseApiUrl. It should not be changed while requests are sent.
+func SetApiUrl(url string) {
+	if url == "" {
+		url = BaseApiUrl
+	}
+	apiUrl = url
+}
 
 // SendHttp signs the provided HTTP request with the client's auth headers and attempts to send it up to 3 times until a
-// response or error is received. Only the final error will be returned if a response is not obtained.
-func SendHttp(method string, url string, body io.Reader, headers http.Header) (*http.Response, error) {
-	req, err := http.NewRequest(method, url, body)
+// response or error is received. Only the final error will be returned if a response is not obtained. The request is
+// abandoned once ctx is done.
+func SendHttp(ctx context.Context, method string, url string, body io.Reader, headers http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -33,35 +55,41 @@ func SendHttp(method string, url string, body io.Reader, headers http.Header) (*
 }
 
 // Get sends an HTTP GET request to the given URL signed with the bot's authorization token
-func Get(url string) (*http.Response, error) {
-	return SendHttp(http.MethodGet, url, nil, nil)
+func Get(ctx context.Context, url string) (*http.Response, error) {
+	return SendHttp(ctx, http.MethodGet, url, nil, nil)
 }
 
 // Post sends an HTTP POST request to the given URL signed with the bot's authorization token
-func Post(url string, body io.Reader) (*http.Response, error) {
-	return SendHttp(http.MethodPost, url, body, nil)
+func Post(ctx context.Context, url string, body io.Reader) (*http.Response, error) {
+	return SendHttp(ctx, http.MethodPost, url, body, nil)
 }
 
 // Delete sends an HTTP POST request to the given URL signed with the bot's authorization token
-func Delete(url string) (*http.Response, error) {
-	return SendHttp(http.MethodDelete, url, nil, nil)
+func Delete(ctx context.Context, url string) (*http.Response, error) {
+	return SendHttp(ctx, http.MethodDelete, url, nil, nil)
 }
 
 // Patch sends an HTTP PATCH request to the given URL signed with the bot's authorization token
-func Patch(url string, body []byte) (*http.Response, error) {
-	return SendHttp(http.MethodPatch, url, bytes.NewBuffer(body), nil)
+func Patch(ctx context.Context, url string, body []byte) (*http.Response, error) {
+	return SendHttp(ctx, http.MethodPatch, url, bytes.NewBuffer(body), nil)
 }
 
 // Put sends an HTTP P{UT request to the given URL signed with the bot's authorization token
-func Put(url string, body []byte) (*http.Response, error) {
-	return SendHttp(http.MethodPut, url, bytes.NewBuffer(body), nil)
+func Put(ctx context.Context, url string, body []byte) (*http.Response, error) {
+	return SendHttp(ctx, http.MethodPut, url, bytes.NewBuffer(body), nil)
+}
+
+// GetApiUrl returns the base URL of the discord API, see SetApiUrl
+func GetApiUrl() string {
+	return apiUrl
 }
 
+// ApiUrl returns the URL of the given path of the discord API, escaping each part. See SetApiUrl
 func ApiUrl(parts ...string) string {
 	for i, v := range parts {
 		parts[i] = url.PathEscape(v)
 	}
-	out, err := url.JoinPath(BaseApiUrl, parts...)
+	out, err := url.JoinPath(apiUrl, parts...)
 	AssertIsNil(err)
 	return out
 }
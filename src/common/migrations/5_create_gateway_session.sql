@@ -0,0 +1,9 @@
+CREATE TABLE gateway_session (
+    shard_id INT UNSIGNED PRIMARY KEY,
+    shard_count INT UNSIGNED NOT NULL,
+    session_id VARCHAR(64) NOT NULL,
+    resume_url VARCHAR(255) NOT NULL,
+    sequence INT NOT NULL,
+    saved_at BIGINT NOT NULL,
+    guilds MEDIUMTEXT NOT NULL
+);
@@ -0,0 +1,46 @@
+package common
+
+// GuildEvent is implemented by event payloads which can belong to a guild, so they can be filtered by their guild
+// without knowing the payload's type.
+type GuildEvent interface {
+	EventGuild() Snowflake // 0 if the event was sent outside a guild
+}
+
+// UserEvent is implemented by event payloads caused by a user, so they can be filtered by their user without knowing
+// the payload's type.
+type UserEvent interface {
+	EventUser() *User // nil if discord didn't send the user
+}
+
+func (p CreateMessagePayload) EventGuild() Snowflake { return p.GuildId }
+func (p CreateMessagePayload) EventUser() *User      { return &p.Author }
+
+func (p UpdateMessagePayload) EventGuild() Snowflake { return p.GuildId }
+func (p UpdateMessagePayload) EventUser() *User      { return &p.Author }
+
+func (p DeleteMessagePayload) EventGuild() Snowflake     { return p.GuildId }
+func (p BulkDeleteMessagePayload) EventGuild() Snowflake { return p.GuildId }
+
+func (p ReactionAddPayload) EventGuild() Snowflake { return p.GuildId }
+func (p ReactionAddPayload) EventUser() *User {
+	if p.Member == nil {
+		return nil
+	}
+	return p.Member.User
+}
+
+func (p ReactionRemovePayload) EventGuild() Snowflake { return p.GuildId }
+
+func (p Interaction) EventGuild() Snowflake { return p.GuildId }
+func (p Interaction) EventUser() *User {
+	if p.Member != nil && p.Member.User != nil { // User is only sent outside guilds
+		return p.Member.User
+	}
+	return p.User
+}
+
+func (p UpdateRolePayload) EventGuild() Snowflake        { return p.GuildId }
+func (p DeleteRolePayload) EventGuild() Snowflake        { return p.GuildId }
+func (p AddGuildMemberPayload) EventGuild() Snowflake    { return p.GuildId }
+func (p UpdateGuildMemberPayload) EventGuild() Snowflake { return p.GuildId }
+func (p RemoveGuildMemberPayload) EventGuild() Snowflake { return p.GuildId }
@@ -0,0 +1,182 @@
+package restapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Sentinel errors for common JSON error codes, a DiscordError with the code matches them with errors.Is.
+// https://discord.com/developers/docs/topics/opcodes-and-status-codes#json
+var (
+	ErrUnknownChannel      = errors.New("unknown channel")
+	ErrUnknownGuild        = errors.New("unknown guild")
+	ErrUnknownMember       = errors.New("unknown member")
+	ErrUnknownMessage      = errors.New("unknown message")
+	ErrUnknownRole         = errors.New("unknown role")
+	ErrUnknownUser         = errors.New("unknown user")
+	ErrUnknownEmoji        = errors.New("unknown emoji")
+	ErrUnknownBan          = errors.New("unknown ban")
+	ErrUnknownInteraction  = errors.New("unknown interaction")
+	ErrUnknownCommand      = errors.New("unknown application command")
+	ErrAlreadyAcknowledged = errors.New("interaction has already been acknowledged")
+	ErrMissingAccess       = errors.New("missing access")
+	ErrCannotMessageUser   = errors.New("cannot send messages to this user")
+	ErrMissingPermissions  = errors.New("missing permissions")
+	ErrInvalidFormBody     = errors.New("invalid form body")
+	ErrMaxReactions        = errors.New("maximum number of reactions reached")
+	ErrReactionBlocked     = errors.New("reaction was blocked")
+	ErrRequestTooLarge     = errors.New("request entity too large")
+	ErrUnauthorized        = errors.New("unauthorized: the bot token is invalid")
+)
+
+var errorCodes = map[int]error{
+	10003: ErrUnknownChannel,
+	10004: ErrUnknownGuild,
+	10007: ErrUnknownMember,
+	10008: ErrUnknownMessage,
+	10011: ErrUnknownRole,
+	10013: ErrUnknownUser,
+	10014: ErrUnknownEmoji,
+	10026: ErrUnknownBan,
+	10062: ErrUnknownInteraction,
+	10063: ErrUnknownCommand,
+	30010: ErrMaxReactions,
+	40005: ErrRequestTooLarge,
+	40060: ErrAlreadyAcknowledged,
+	50001: ErrMissingAccess,
+	50007: ErrCannotMessageUser,
+	50013: ErrMissingPermissions,
+	50035: ErrInvalidFormBody,
+	90001: ErrReactionBlocked,
+}
+
+// DiscordError is an error response from discord. Its Code matches the sentinel errors above with errors.Is, a 401
+// matches ErrUnauthorized.
+type DiscordError struct {
+	Status  int          `json:"-"` // HTTP status code
+	Code    int          `json:"code"`
+	Message string       `json:"message"`
+	Errors  *FieldErrors `json:"errors,omitempty"` // Which fields of the request were invalid, only sent for some codes
+}
+
+func (err *DiscordError) Error() string {
+	out := fmt.Sprintf("discord error %d (%d %s): %s", err.Code, err.Status, http.StatusText(err.Status), err.Message)
+	if err.Errors == nil {
+		return out
+	}
+
+	fields := err.Errors.Flatten()
+	for _, path := range slices.Sorted(maps.Keys(fields)) {
+		for _, e := range fields[path] {
+			out += fmt.Sprintf("; %s: %s", path, e)
+		}
+	}
+	return out
+}
+
+func (err *DiscordError) Is(target error) bool {
+	if err.Status == http.StatusUnauthorized && target == ErrUnauthorized {
+		return true
+	}
+	sentinel, ok := errorCodes[err.Code]
+	return ok && sentinel == target
+}
+
+// FieldErrors is the nested errors object of a DiscordError. Each level is keyed by a field name or array index, with
+// the errors of the field at that level in Errors.
+// https://discord.com/developers/docs/reference#error-messages
+type FieldErrors struct {
+	Errors []FieldError
+	Fields map[string]*FieldErrors
+}
+
+// FieldError is a single reason a field was invalid, such as BASE_TYPE_REQUIRED
+type FieldError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	return e.Message + " (" + e.Code + ")"
+}
+
+func (f *FieldErrors) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	for key, value := range raw {
+		if key == "_errors" {
+			if err := json.Unmarshal(value, &f.Errors); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var child FieldErrors
+		if err := json.Unmarshal(value, &child); err != nil {
+			return err
+		}
+		if f.Fields == nil {
+			f.Fields = make(map[string]*FieldErrors)
+		}
+		f.Fields[key] = &child
+	}
+	return nil
+}
+
+// Flatten returns the errors of every field, keyed by its dotted path such as "embeds.0.title"
+func (f *FieldErrors) Flatten() map[string][]FieldError {
+	out := make(map[string][]FieldError)
+	f.flatten("", out)
+	return out
+}
+
+func (f *FieldErrors) flatten(path string, out map[string][]FieldError) {
+	if len(f.Errors) > 0 {
+		out[path] = f.Errors
+	}
+
+	for key := range f.Fields {
+		child := key
+		if path != "" {
+			child = path + "." + key
+		}
+		f.Fields[key].flatten(child, out)
+	}
+}
+
+// parseError decodes the error response discord sent with the given status. Bodies which aren't a JSON error, such as
+// the HTML pages sent by cloudflare, are kept as the message.
+func parseError(status int, body []byte) *DiscordError {
+	err := &DiscordError{Status: status}
+	if json.Unmarshal(body, err) != nil || err.Message == "" {
+		err.Code, err.Errors = 0, nil
+		err.Message = strings.TrimSpace(string(body))
+	}
+	return err
+}
+
+// unauthorized is tripped by the first 401, after which every request fails without being sent. Discord temporarily
+// bans bots which keep sending requests with an invalid token, and a token doesn't become valid again on its own.
+var unauthorized atomic.Bool
+
+// tripUnauthorized stops every further request, see unauthorized
+func tripUnauthorized() {
+	if !unauthorized.Swap(true) {
+		slog.Error("[REST] Discord rejected the bot token, every request will fail until ResetUnauthorized is called")
+	}
+}
+
+// ResetUnauthorized allows requests to be sent again after a 401, it should only be called once the token was replaced
+func ResetUnauthorized() {
+	unauthorized.Store(false)
+}
@@ -0,0 +1,110 @@
+package restapi
+
+import (
+	. "elaina-common"
+	"elaina-common/discordtest"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests that error responses are decoded, including their nested field errors
+func TestParseError(t *testing.T) {
+	// TEST CASE: The code, message and field errors of a JSON error are decoded
+	err := parseError(http.StatusBadRequest, []byte(`{
+		"code": 50035,
+		"message": "Invalid Form Body",
+		"errors": {
+			"content": {"_errors": [{"code": "BASE_TYPE_MAX_LENGTH", "message": "Must be 2000 or fewer in length."}]},
+			"embeds": {"0": {"title": {"_errors": [{"code": "BASE_TYPE_REQUIRED", "message": "This field is required"}]}}}
+		}
+	}`))
+	assert.Equal(t, http.StatusBadRequest, err.Status)
+	assert.Equal(t, 50035, err.Code)
+	assert.Equal(t, "Invalid Form Body", err.Message)
+	assert.Equal(t, map[string][]FieldError{
+		"content":        {{Code: "BASE_TYPE_MAX_LENGTH", Message: "Must be 2000 or fewer in length."}},
+		"embeds.0.title": {{Code: "BASE_TYPE_REQUIRED", Message: "This field is required"}},
+	}, err.Errors.Flatten())
+	assert.Equal(t, "discord error 50035 (400 Bad Request): Invalid Form Body"+
+		"; content: Must be 2000 or fewer in length. (BASE_TYPE_MAX_LENGTH)"+
+		"; embeds.0.title: This field is required (BASE_TYPE_REQUIRED)", err.Error())
+
+	// TEST CASE: Bodies which aren't a JSON error are kept as the message
+	err = parseError(http.StatusBadGateway, []byte("<html>502 Bad Gateway</html>\n"))
+	assert.Equal(t, 0, err.Code)
+	assert.Equal(t, "<html>502 Bad Gateway</html>", err.Message)
+	assert.Nil(t, err.Errors)
+}
+
+// Tests that errors returned by REST calls match the sentinel for their code
+func TestDiscordErrorIs(t *testing.T) {
+	ctx := t.Context()
+	server := startFakeDiscord(t)
+	server.AddGuild(Guild{Id: 40})
+
+	// TEST CASE: Unknown resources match their sentinel, and no other
+	_, err := GetGuildMember(ctx, 40, 41)
+	assert.ErrorIs(t, err, ErrUnknownMember)
+	assert.NotErrorIs(t, err, ErrUnknownGuild)
+	assert.ErrorIs(t, DeleteBan(ctx, 40, 41), ErrUnknownBan)
+
+	// TEST CASE: Wrapped errors still match, and can be unwrapped into a DiscordError
+	server.FailNext("PUT /guilds/{guild}/bans/{user}", discordtest.Error{Status: http.StatusForbidden, Code: discordtest.CodeMissingPermissions, Message: "Missing Permissions"})
+	err = fmt.Errorf("failed to create ban: %w", CreateBan(ctx, 40, 41, 0))
+	assert.ErrorIs(t, err, ErrMissingPermissions)
+	var discordErr *DiscordError
+	require.True(t, errors.As(err, &discordErr))
+	assert.Equal(t, http.StatusForbidden, discordErr.Status)
+	assert.Equal(t, "Missing Permissions", discordErr.Message)
+
+	// TEST CASE: Field errors sent by discord are decoded
+	server.FailNext("POST /channels/{channel}/messages", discordtest.Error{
+		Status: http.StatusBadRequest, Code: 50035, Message: "Invalid Form Body",
+		Errors: []byte(`{"content": {"_errors": [{"code": "BASE_TYPE_REQUIRED", "message": "This field is required"}]}}`),
+	})
+	server.AddChannel(Channel{Id: 42, GuildId: 40})
+	_, err = CreateMessage(ctx, 42, "", false)
+	assert.ErrorIs(t, err, ErrInvalidFormBody)
+	require.True(t, errors.As(err, &discordErr))
+	assert.Contains(t, discordErr.Errors.Flatten(), "content")
+}
+
+// Tests that a 401 stops every further request instead of panicking
+func TestUnauthorized(t *testing.T) {
+	ctx := t.Context()
+	server := startFakeDiscord(t)
+	server.AddGuild(Guild{Id: 50})
+	server.FailNext("GET /guilds/{guild}", discordtest.Error{Status: http.StatusUnauthorized, Message: "401: Unauthorized"})
+
+	// TEST CASE: The 401 is returned as an error
+	_, err := GetGuild(ctx, 50)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	require.Len(t, server.Requests(), 1)
+
+	// TEST CASE: Later requests to any route fail without being sent
+	_, err = GetGuildMember(ctx, 50, 51)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = CreateDM(ctx, 51)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.ErrorIs(t, CreateInteractionResponse(ctx, 52, "token", struct{}{}), ErrUnauthorized)
+	assert.Len(t, server.Requests(), 1)
+
+	// TEST CASE: Interaction responses, which skip the rate limiter, trip it as well
+	ResetUnauthorized()
+	server.FailNext("POST /interactions/{interaction}/{token}/callback", discordtest.Error{Status: http.StatusUnauthorized, Message: "401: Unauthorized"})
+	assert.ErrorIs(t, CreateInteractionResponse(ctx, 52, "token", struct{}{}), ErrUnauthorized)
+	_, err = CreateDM(ctx, 51)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Len(t, server.Requests(), 2)
+
+	// TEST CASE: Requests are sent again once it is reset
+	ResetUnauthorized()
+	guild, err := GetGuild(ctx, 50)
+	require.NoError(t, err)
+	assert.Equal(t, Snowflake(50), guild.Id)
+}
@@ -0,0 +1,80 @@
+package restapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxAuditLogReason is the most characters discord accepts in an audit log reason
+const MaxAuditLogReason = 512
+
+var ErrReasonTooLong = fmt.Errorf("audit log reason is longer than %d characters", MaxAuditLogReason)
+
+// RequestOption configures a single REST call, such as how long it may take
+type RequestOption func(options *requestOptions)
+
+type requestOptions struct {
+	deadline time.Time // Zero for no deadline other than the context's
+	reason   string    // Empty for no audit log reason
+}
+
+// WithDeadline gives up on the call, including any rate limit waits or retries, once deadline has passed
+func WithDeadline(deadline time.Time) RequestOption {
+	return func(options *requestOptions) {
+		if options.deadline.IsZero() || deadline.Before(options.deadline) {
+			options.deadline = deadline
+		}
+	}
+}
+
+// WithTimeout gives up on the call, including any rate limit waits or retries, once timeout has passed
+func WithTimeout(timeout time.Duration) RequestOption {
+	return WithDeadline(time.Now().Add(timeout))
+}
+
+// WithReason shows reason in the guild's audit log for the action taken by the call. It is only used by discord on
+// calls which modify a guild, such as CreateBan or ModifyGuildMember. Calls with a reason longer than MaxAuditLogReason
+// fail with ErrReasonTooLong without being sent.
+func WithReason(reason string) RequestOption {
+	return func(options *requestOptions) {
+		options.reason = reason
+	}
+}
+
+// newRequestOptions applies options, returning an error if they are invalid
+func newRequestOptions(options []RequestOption) (requestOptions, error) {
+	var opts requestOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	if !utf8.ValidString(opts.reason) {
+		return opts, errors.New("audit log reason is not valid UTF-8")
+	}
+	if length := utf8.RuneCountInString(opts.reason); length > MaxAuditLogReason {
+		return opts, fmt.Errorf("%w: %d characters", ErrReasonTooLong, length)
+	}
+	return opts, nil
+}
+
+// context returns ctx bounded by the deadline of the options, the returned cancel func must always be called
+func (opts requestOptions) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if opts.deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, opts.deadline)
+}
+
+// headers returns the headers a request with the options is sent with, or nil if it needs none
+func (opts requestOptions) headers() http.Header {
+	if opts.reason == "" {
+		return nil
+	}
+	headers := make(http.Header)
+	headers.Set("X-Audit-Log-Reason", url.PathEscape(opts.reason)) // Discord expects the reason to be URL encoded
+	return headers
+}
@@ -1,76 +1,163 @@
 package restapi
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
 	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
-// routeBucket represents a token bucket for any route or endpoint. Tokens should be consumed from a bucket before a request
-// is made, and only one thread can actively consume from the bucket at a time.
-type routeBucket struct {
-	sync.Mutex // built-in mutex is used for ensuring only one thread is consuming at a time
-	id         string
-	remaining  uint64
-	limit      uint64
-	reset      time.Time
-	mu         sync.Mutex // mu is used for accessing the bucket's contents
+// rateLimitMargin is added to every Retry-After, weirdly discord still sends more 429s unless it's offset further
+var rateLimitMargin = time.Millisecond * 500
+
+// limiter is shared by every route, so routes which discord puts in the same bucket also share its limit
+var limiter = newRateLimiter()
+
+// rateLimiter tracks discord's rate limits, it is safe for concurrent use. Buckets are identified by the hash discord
+// sends in X-RateLimit-Bucket and the major parameter of the request, as the same bucket is limited separately for
+// every channel, guild or webhook. Until the hash of a route is known, its requests use a bucket keyed by the route.
+// https://discord.com/developers/docs/topics/rate-limits
+type rateLimiter struct {
+	mu          sync.Mutex
+	hashes      map[string]string  // Route key to the bucket hash discord sent for it
+	buckets     map[string]*bucket // Bucket hash or route key + major parameter to bucket
+	globalReset time.Time          // Every request waits until then after a global 429
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{hashes: make(map[string]string), buckets: make(map[string]*bucket)}
 }
 
-// consume will block until the bucket has a token available for consumption, then consume it. If true is returned, the
-// bucket should only be unlocked after an http response for it has been returned.
-func (b *routeBucket) consume() {
-	b.Lock()
+// bucket represents a token bucket for a bucket hash and major parameter. It is locked while a request is in flight, so
+// requests sharing a bucket are sent one at a time and each sees the limits returned by the previous one. The lock is a
+// channel rather than a mutex so requests waiting for it can give up.
+type bucket struct {
+	lock      chan struct{}
+	remaining int
+	reset     time.Time
+}
+
+func newBucket() *bucket {
+	return &bucket{lock: make(chan struct{}, 1), remaining: 1}
+}
 
-	b.mu.Lock()
-	defer b.mu.Unlock()
+// bucket returns the bucket a request to route with the given major parameter is limited by, creating it if needed
+func (l *rateLimiter) bucket(route *route, major string) *bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	// If reset is in the future, we have to wait until then.
-	if wait := b.getWaitTime(); wait > 0 {
-		time.Sleep(wait)
+	key := route.key + ":" + major
+	if hash, ok := l.hashes[route.key]; ok {
+		key = hash + ":" + major
 	}
 
-	b.remaining--
+	b := l.buckets[key]
+	if b == nil {
+		b = newBucket()
+		l.buckets[key] = b
+	}
+	return b
 }
 
-// getWaitTime calculates the amount of time the bucket needs to wait to be able to access a token
-func (b *routeBucket) getWaitTime() time.Duration {
-	if b.remaining > 0 {
-		return 0
+// learn records the bucket hash discord sent for route. If no bucket exists for the hash and major parameter yet, b
+// becomes that bucket so the limits it was updated with carry over.
+func (l *rateLimiter) learn(route *route, major string, hash string, b *bucket) {
+	if hash == "" {
+		return // Route isn't rate limited by a bucket
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.hashes[route.key] = hash
+	key := hash + ":" + major
+	if _, ok := l.buckets[key]; !ok {
+		l.buckets[key] = b
 	}
-	return b.reset.Sub(time.Now())
 }
 
-func (b *routeBucket) update(headers http.Header) error {
-	if headers == nil {
+// waitGlobal blocks until the global rate limit, if any, has reset. If ctx is done first, its error is returned.
+func (l *rateLimiter) waitGlobal(ctx context.Context) error {
+	l.mu.Lock()
+	wait := time.Until(l.globalReset)
+	l.mu.Unlock()
+
+	return sleep(ctx, wait)
+}
+
+func (l *rateLimiter) setGlobalReset(reset time.Time) {
+	l.mu.Lock()
+	if reset.After(l.globalReset) {
+		l.globalReset = reset
+	}
+	l.mu.Unlock()
+}
+
+// acquire locks the bucket, blocking until it has a token available, and consumes it. The bucket must be released once
+// the response has been used to update it, or the request failed. If ctx is done first, the bucket is left unlocked and
+// ctx's error is returned.
+func (b *bucket) acquire(ctx context.Context) error {
+	select {
+	case b.lock <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if b.remaining <= 0 {
+		if err := sleep(ctx, time.Until(b.reset)); err != nil {
+			b.release()
+			return err
+		}
+	}
+	b.remaining--
+	return nil
+}
+
+func (b *bucket) release() {
+	<-b.lock
+}
+
+// sleep blocks for d, returning early with ctx's error if it is done first. If ctx's deadline is before d has passed,
+// it doesn't wait for it to be done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return fmt.Errorf("%w: rate limit resets after the deadline", context.DeadlineExceeded)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
 		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	hLimit := headers.Get("X-RateLimit-Limit")
+// update updates the bucket, which must be locked, from the rate limit headers of a response. Buckets which discord
+// didn't send limits for always have a token available.
+func (b *bucket) update(headers http.Header) error {
 	hRemaining := headers.Get("X-RateLimit-Remaining")
 	hReset := headers.Get("X-RateLimit-Reset")
 	hResetAfter := headers.Get("X-RateLimit-Reset-After")
 
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	if hLimit != "" {
-		limit, err := strconv.ParseUint(hLimit, 10, 64)
-		if err != nil {
-			return err
-		}
-		b.limit = limit
+	if hRemaining == "" {
+		b.remaining = 1
+		return nil
 	}
-
-	if hRemaining != "" {
-		remaining, err := strconv.ParseUint(hRemaining, 10, 64)
-		if err != nil {
-			return err
-		}
-		b.remaining = remaining
+	remaining, err := strconv.Atoi(hRemaining)
+	if err != nil {
+		return err
 	}
+	b.remaining = remaining
 
 	// Reset-After seems to be more accurate than Reset so it should be prioritized
 	if hResetAfter != "" {
@@ -90,3 +177,58 @@ func (b *routeBucket) update(headers http.Header) error {
 	}
 	return nil
 }
+
+// update updates the limits of b, which must be locked, from a response to route. A 429 stops b or every bucket from
+// being used until Retry-After has passed, depending on its X-RateLimit-Scope:
+//   - user: The bot exceeded the bucket's limit, or a sub-limit discord doesn't send headers for
+//   - shared: The limit of the resource is shared with other users, only b waits but it isn't the bot's fault
+//   - global: The bot exceeded the global limit, every request waits
+func (l *rateLimiter) update(route *route, major string, b *bucket, resp *http.Response) error {
+	if err := b.update(resp.Header); err != nil {
+		return err
+	}
+	l.learn(route, major, resp.Header.Get("X-RateLimit-Bucket"), b)
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	retryAfter, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64)
+	if err != nil {
+		return fmt.Errorf("invalid Retry-After on rate limit: %w", err)
+	}
+	retry := time.Now().Add(time.Duration(retryAfter * float64(time.Second))).Add(rateLimitMargin)
+	scope := resp.Header.Get("X-RateLimit-Scope")
+
+	if scope == "global" || resp.Header.Get("X-RateLimit-Global") != "" {
+		slog.Warn("[REST] Global rate limit exceeded", slog.Float64("retry_after", retryAfter))
+		l.setGlobalReset(retry)
+		return nil
+	}
+
+	if scope == "shared" {
+		slog.Debug("[REST] Shared rate limit exceeded", slog.String("route", route.key), slog.String("major", major), slog.Float64("retry_after", retryAfter))
+	} else {
+		slog.Warn("[REST] Rate limit exceeded", slog.String("route", route.key), slog.String("major", major), slog.Float64("retry_after", retryAfter))
+	}
+	b.remaining = 0
+	b.reset = retry // Retry-After is more precise than the reset of the bucket's headers
+	return nil
+}
+
+// majorParameters are the top-level resources discord limits separately, with the number of arguments identifying them
+var majorParameters = map[string]int{
+	"/channels/": 1,
+	"/guilds/":   1,
+	"/webhooks/": 2, // Webhook ID and token
+}
+
+// majorArgs returns how many of path's leading arguments form its major parameter
+func majorArgs(path string) int {
+	for prefix, args := range majorParameters {
+		if strings.HasPrefix(path, prefix+"%") {
+			return args
+		}
+	}
+	return 0
+}
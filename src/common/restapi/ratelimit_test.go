@@ -0,0 +1,236 @@
+package restapi
+
+import (
+	"context"
+	. "elaina-common"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startRateLimitServer sends every REST request to handler with a fresh rateLimiter, and without rateLimitMargin
+func startRateLimitServer(t *testing.T, handler http.HandlerFunc) {
+	server := httptest.NewServer(handler)
+	margin := rateLimitMargin
+	limiter, rateLimitMargin = newRateLimiter(), 0
+	SetApiUrl(server.URL)
+	t.Cleanup(func() {
+		SetApiUrl("")
+		rateLimitMargin = margin
+		server.Close()
+	})
+}
+
+func setLimitHeaders(w http.ResponseWriter, bucket string, remaining string, resetAfter string) {
+	w.Header().Set("X-RateLimit-Bucket", bucket)
+	w.Header().Set("X-RateLimit-Limit", "5")
+	w.Header().Set("X-RateLimit-Remaining", remaining)
+	w.Header().Set("X-RateLimit-Reset-After", resetAfter)
+}
+
+func timed(f func() error) (time.Duration, error) {
+	start := time.Now()
+	err := f()
+	return time.Since(start), err
+}
+
+// Tests that concurrent requests to many routes and buckets are safe and all succeed
+func TestRateLimiterConcurrent(t *testing.T) {
+	var requests atomic.Int32
+	startRateLimitServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		setLimitHeaders(w, "shared-hash", "3", "0.01")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	// TEST CASE: Requests from many goroutines to different routes and channels don't race and all succeed
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				channel := Snowflake(j % 3)
+				if j%2 == 0 {
+					_, err := routeGetChannel.do(t.Context(), nil, nil, channel)
+					errs <- err
+				} else {
+					_, err := routeGetMessage.do(t.Context(), nil, nil, channel, Snowflake(i))
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(100), requests.Load())
+}
+
+// Tests that buckets are keyed by their hash and major parameter
+func TestRateLimiterMajorParameter(t *testing.T) {
+	startRateLimitServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/channels/1") {
+			setLimitHeaders(w, "channel-hash", "0", "0.3") // Channel 1 is out of tokens for a while
+		} else {
+			setLimitHeaders(w, "channel-hash", "4", "0.3")
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	_, err := routeGetChannel.do(t.Context(), nil, nil, Snowflake(1))
+	require.NoError(t, err)
+
+	// TEST CASE: A different major parameter in the same bucket isn't limited
+	elapsed, err := timed(func() error {
+		_, err := routeGetChannel.do(t.Context(), nil, nil, Snowflake(2))
+		return err
+	})
+	require.NoError(t, err)
+	assert.Less(t, elapsed, time.Millisecond*150)
+
+	// TEST CASE: Another route with the same hash shares the bucket once its hash is known
+	_, err = routeGetMessage.do(t.Context(), nil, nil, Snowflake(3), Snowflake(1))
+	require.NoError(t, err)
+	elapsed, err = timed(func() error {
+		_, err := routeGetMessage.do(t.Context(), nil, nil, Snowflake(1), Snowflake(1))
+		return err
+	})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, elapsed, time.Millisecond*200)
+}
+
+// Tests that 429s wait for Retry-After according to their scope before being retried
+func TestRateLimiterTooManyRequests(t *testing.T) {
+	var requests atomic.Int32
+	scope := "user"
+	startRateLimitServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			setLimitHeaders(w, "limited-hash", "4", "5") // Sub-limits are hit even though the bucket has tokens left
+			w.Header().Set("X-RateLimit-Scope", scope)
+			w.Header().Set("Retry-After", "0.2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		setLimitHeaders(w, "limited-hash", "4", "5")
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	for _, s := range []string{"user", "shared"} {
+		scope = s
+		requests.Store(0)
+		limiter = newRateLimiter()
+
+		// TEST CASE: The request is retried once Retry-After has passed
+		elapsed, err := timed(func() error {
+			_, err := routeGetChannel.do(t.Context(), nil, nil, Snowflake(1))
+			return err
+		})
+		require.NoError(t, err, scope)
+		assert.GreaterOrEqual(t, elapsed, time.Millisecond*200, scope)
+		assert.Equal(t, int32(2), requests.Load(), scope)
+
+		// TEST CASE: Other major parameters aren't limited by it
+		elapsed, err = timed(func() error {
+			_, err := routeGetChannel.do(t.Context(), nil, nil, Snowflake(2))
+			return err
+		})
+		require.NoError(t, err, scope)
+		assert.Less(t, elapsed, time.Millisecond*150, scope)
+	}
+}
+
+// Tests that a global 429 limits every route
+func TestRateLimiterGlobal(t *testing.T) {
+	var requests atomic.Int32
+	startRateLimitServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("X-RateLimit-Global", "true")
+			w.Header().Set("X-RateLimit-Scope", "global")
+			w.Header().Set("Retry-After", "0.2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	// TEST CASE: The request is retried once the global limit resets
+	start := time.Now()
+	_, err := routeGetChannel.do(t.Context(), nil, nil, Snowflake(1))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*200)
+
+	// TEST CASE: Requests to any route wait for the global limit
+	limiter.setGlobalReset(time.Now().Add(time.Millisecond * 200))
+	elapsed, err := timed(func() error {
+		_, err := routeGetGuild.do(t.Context(), nil, nil, Snowflake(1))
+		return err
+	})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, elapsed, time.Millisecond*150)
+}
+
+// Tests that rate limit waits give up once the request's context is done or its deadline has passed
+func TestRateLimiterCancellation(t *testing.T) {
+	var requests atomic.Int32
+	startRateLimitServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		setLimitHeaders(w, "slow-hash", "0", "5") // Every bucket is out of tokens for a long time
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	_, err := routeGetChannel.do(t.Context(), nil, nil, Snowflake(1))
+	require.NoError(t, err)
+
+	// TEST CASE: A deadline before the bucket resets fails immediately without sending the request
+	elapsed, err := timed(func() error {
+		_, err := routeGetChannel.do(t.Context(), nil, []RequestOption{WithTimeout(time.Second)}, Snowflake(1))
+		return err
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, elapsed, time.Millisecond*100)
+	assert.Equal(t, int32(1), requests.Load())
+
+	// TEST CASE: Cancelling the context stops the wait for the bucket
+	ctx, cancel := context.WithCancel(t.Context())
+	time.AfterFunc(time.Millisecond*50, cancel)
+	elapsed, err = timed(func() error {
+		_, err := routeGetChannel.do(ctx, nil, nil, Snowflake(1))
+		return err
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, elapsed, time.Second)
+
+	// TEST CASE: A request queued behind one waiting for the bucket can give up, and cancelled requests leave it usable
+	waiting, cancelWaiting := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		_, err := routeGetChannel.do(waiting, nil, nil, Snowflake(1))
+		done <- err
+	}()
+	time.Sleep(time.Millisecond * 20)
+	_, err = routeGetChannel.do(t.Context(), nil, []RequestOption{WithTimeout(time.Millisecond * 50)}, Snowflake(1))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	cancelWaiting()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Equal(t, int32(1), requests.Load())
+
+	// TEST CASE: Cancelling the context stops the wait for the global rate limit
+	limiter.setGlobalReset(time.Now().Add(time.Second * 5))
+	ctx, cancel = context.WithTimeout(t.Context(), time.Millisecond*50)
+	defer cancel()
+	_, err = routeGetGuild.do(ctx, nil, nil, Snowflake(1))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
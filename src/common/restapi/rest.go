@@ -2,33 +2,31 @@ package restapi
 
 import (
 	"bytes"
+	"context"
 	. "elaina-common"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
-	"strconv"
-	"sync"
-	"sync/atomic"
-	"time"
+	"net/url"
 )
 
 const maxRestAttempts = 3
 
-var routeCreateCommand = newApiRoute(http.MethodPost, "/applications/%d/commands", nil)
-var routeDeleteCommand = newApiRoute(http.MethodDelete, "/applications/%d/commands/%d", nil)
+var routeCreateCommand = newApiRoute(http.MethodPost, "/applications/%s/commands", nil)
+var routeDeleteCommand = newApiRoute(http.MethodDelete, "/applications/%s/commands/%d", nil)
 
 var routeGetMessage = newApiRoute(http.MethodGet, "/channels/%d/messages/%d", nil)
 var routeCreateMessage = newApiRoute(http.MethodPost, "/channels/%d/messages", nil)
-var routeDeleteMessage = newApiRoute(http.MethodDelete, "/channels/%d/messages", nil)
-var routeCreateReaction = newApiRoute(http.MethodPost, "/channels/%d/messages/%d/reactions/%s/@me", nil)
+var routeDeleteMessage = newApiRoute(http.MethodDelete, "/channels/%d/messages/%d", nil)
+var routeCreateReaction = newApiRoute(http.MethodPut, "/channels/%d/messages/%d/reactions/%s/@me", nil)
 
 var routeGetChannel = newApiRoute(http.MethodGet, "/channels/%d", nil)
 var routeCreateDM = newApiRoute(http.MethodPost, "/users/@me/channels", nil)
 
 var routeGetGuild = newApiRoute(http.MethodGet, "/guilds/%d", nil)
+var routeGetGuildChannels = newApiRoute(http.MethodGet, "/guilds/%d/channels", nil)
 var routeGetRole = newApiRoute(http.MethodGet, "/guilds/%d/roles/%d", nil)
 var routeGetGuildMember = newApiRoute(http.MethodGet, "/guilds/%d/members/%d", nil)
 var routeModifyGuildMember = newApiRoute(http.MethodPatch, "/guilds/%d/members/%d", nil)
@@ -36,59 +34,92 @@ var routeKickGuildMember = newApiRoute(http.MethodDelete, "/guilds/%d/members/%d
 var routeCreateGuildBan = newApiRoute(http.MethodPut, "/guilds/%d/bans/%d", nil)
 var routeDeleteGuildBan = newApiRoute(http.MethodDelete, "/guilds/%d/bans/%d", nil)
 
+var routeCreateInteractionResponse = newApiRoute(http.MethodPost, "/interactions/%d/%s/callback", nil)
+var routeEditOriginalInteractionResponse = newApiRoute(http.MethodPatch, "/webhooks/%s/%s/messages/@original", nil)
+
 func newApiRoute(method string, path string, headers http.Header) *route {
-	return &route{method: method, path: path, headers: headers, urlToBucket: make(map[string]string)}
+	return &route{method: method, path: path, headers: headers, key: method + " " + path, majorArgs: majorArgs(path)}
 }
 
-var globalRetryAfter = atomic.Int64{}
-var buckets = make(map[string]*routeBucket)
-
 type route struct {
-	method      string
-	path        string
-	headers     http.Header
-	urlToBucket map[string]string
-	unknownMu   sync.Mutex // Requests with an unknown bucket need to be executed synchronously
+	method    string
+	path      string
+	headers   http.Header
+	key       string // Identifies the route until discord sends its bucket hash
+	majorArgs int    // Number of leading args forming the major parameter, see majorParameters
 }
 
-func (route *route) do(body []byte, attempt int, args ...any) (respBody []byte, err error) {
-	waitUntil := time.UnixMilli(globalRetryAfter.Load())
-	if wait := waitUntil.Sub(time.Now()); wait > 0 { // If we're globally rate limited, wait until it expires.
-		time.Sleep(wait)
+// major returns the major parameter of a request to the route with the given args
+func (route *route) major(args []any) string {
+	return fmt.Sprint(args[:min(route.majorArgs, len(args))]...)
+}
+
+// do sends a request to the route with the given args, retrying server errors and rate limits. It gives up once ctx is
+// done or the deadline of options has passed, including while waiting for a rate limit.
+func (route *route) do(ctx context.Context, body []byte, options []RequestOption, args ...any) ([]byte, error) {
+	opts, err := newRequestOptions(options)
+	if err != nil {
+		return nil, err
 	}
 
-	url := BaseApiUrl + fmt.Sprintf(route.path, args...)
+	ctx, cancel := opts.context(ctx)
+	defer cancel()
+	return route.send(ctx, body, opts.headers(), 1, args...)
+}
 
-	bucket := buckets[route.urlToBucket[url]]
-	if bucket != nil {
-		bucket.consume()
-	} else {
-		route.unknownMu.Lock()
+// doUnlimited sends a single request to the route with the given args without going through the rate limiter. It is
+// used for routes identified by an interaction token: discord doesn't apply the global limit to them, and a bucket
+// per token would never be used again once the interaction expires.
+func (route *route) doUnlimited(ctx context.Context, body []byte, options []RequestOption, args ...any) ([]byte, error) {
+	opts, err := newRequestOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	if unauthorized.Load() {
+		return nil, fmt.Errorf("%w: requests are disabled after an earlier 401", ErrUnauthorized)
 	}
 
-	resp, err := SendHttp(route.method, url, bytes.NewReader(body), nil)
+	ctx, cancel := opts.context(ctx)
+	defer cancel()
+	resp, err := SendHttp(ctx, route.method, GetApiUrl()+fmt.Sprintf(route.path, args...), bytes.NewReader(body), opts.headers())
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if bucket != nil {
-		err = bucket.update(resp.Header)
-		bucket.Unlock()
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		route.unknownMu.Unlock()
-		bucketId := resp.Header.Get("X-RateLimit-Bucket")
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return respBody, responseError(resp.StatusCode, respBody)
+}
 
-		bucket = &routeBucket{id: bucketId}
-		if err = bucket.update(resp.Header); err != nil {
-			return nil, err
-		}
+func (route *route) send(ctx context.Context, body []byte, headers http.Header, attempt int, args ...any) (respBody []byte, err error) {
+	if unauthorized.Load() {
+		return nil, fmt.Errorf("%w: requests are disabled after an earlier 401", ErrUnauthorized)
+	}
+	endpoint := GetApiUrl() + fmt.Sprintf(route.path, args...)
+	major := route.major(args)
 
-		buckets[bucketId] = bucket
-		route.urlToBucket[url] = bucketId
+	bucket := limiter.bucket(route, major)
+	if err = limiter.waitGlobal(ctx); err != nil {
+		return nil, err
+	}
+	if err = bucket.acquire(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := SendHttp(ctx, route.method, endpoint, bytes.NewReader(body), headers.Clone())
+	if err != nil {
+		bucket.release() // No response means there are no rate limit headers to update the bucket with
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	err = limiter.update(route, major, bucket, resp)
+	bucket.release()
+	if err != nil {
+		return nil, err
 	}
 
 	respBody, err = io.ReadAll(resp.Body)
@@ -107,62 +138,38 @@ func (route *route) do(body []byte, attempt int, args ...any) (respBody []byte,
 	case http.StatusBadGateway:
 		if attempt < maxRestAttempts {
 			slog.Warn(fmt.Sprintf("[REST] Bad gateway: attempt %d failed, retrying...", attempt))
-			return route.do(body, attempt+1, args...)
-		}
-		return nil, fmt.Errorf("exceeded maximum number of retries")
-	case http.StatusTooManyRequests:
-		hGlobal := resp.Header.Get("X-RateLimit-Global")
-		hRetryAfter := resp.Header.Get("Retry-After")
-
-		retryAfter, err := strconv.ParseFloat(hRetryAfter, 64)
-		if err != nil {
-			return respBody, err // Should never be hit
-		}
-		retry := time.Now().Add(time.Duration(retryAfter * float64(time.Second))).Add(time.Millisecond * 500) // Weirdly, still get more 429s unless it's offset further
-
-		if hGlobal != "" {
-			globalRetryAfter.Store(retry.UnixMilli())
-		} else {
-			bucket.mu.Lock()
-			bucket.reset = retry
-			bucket.remaining = 0
-			bucket.limit = 0
-			bucket.mu.Unlock()
+			return route.send(ctx, body, headers, attempt+1, args...)
 		}
-
+		return nil, fmt.Errorf("exceeded maximum number of retries: %w", parseError(resp.StatusCode, respBody))
+	case http.StatusTooManyRequests: // The limiter already waits for Retry-After before the next attempt
 		if attempt < maxRestAttempts {
-			return route.do(body, attempt+1, args...)
+			return route.send(ctx, body, headers, attempt+1, args...)
 		}
-		return nil, fmt.Errorf("rate limit: exceeded maximum number of retries")
-	case http.StatusUnauthorized:
-		panic(errors.New("invalid bot token/tried to access something a bot can't")) // Really, really, terribly awfully horrible if this is ever hit.
+		return nil, fmt.Errorf("rate limit: exceeded maximum number of retries: %w", parseError(resp.StatusCode, respBody))
 	default:
-		err = RestError{Response: resp, Body: respBody}
+		err = responseError(resp.StatusCode, respBody)
 	}
 
 	return
 }
 
-type RestError struct {
-	Response *http.Response
-	Body     []byte
-}
-
-func (err RestError) Error() string {
-	return fmt.Sprintf("rest error (%v): %s", err.Response.Status, string(err.Body))
-}
-
-type RestErrorMessage struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+// responseError returns the error discord sent with status and body, or nil if status is successful
+func responseError(status int, body []byte) error {
+	switch {
+	case status >= 200 && status < 300:
+		return nil
+	case status == http.StatusUnauthorized: // The token is invalid, so every other request would fail too
+		tripUnauthorized()
+	}
+	return parseError(status, body)
 }
 
-func getCacheable[K comparable, T any](cache *LRUCache[K, T], id K, route *route, args ...any) (*T, error) {
+func getCacheable[K comparable, T any](ctx context.Context, cache *LRUCache[K, T], id K, route *route, options []RequestOption, args ...any) (*T, error) {
 	if val := cache.Get(id); val != nil {
 		return val, nil
 	}
 
-	resp, err := route.do(nil, 1, args)
+	resp, err := route.do(ctx, nil, options, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -179,16 +186,16 @@ func getCacheable[K comparable, T any](cache *LRUCache[K, T], id K, route *route
 // |                             COMMANDS                             |
 // --------------------------------------------------------------------
 
-func CreateOrUpdateCommand(command *ApplicationCommand) ([]byte, error) {
+func CreateOrUpdateCommand(ctx context.Context, command *ApplicationCommand, options ...RequestOption) ([]byte, error) {
 	enc, err := json.Marshal(command)
 	if err != nil {
 		return nil, err
 	}
-	return routeCreateCommand.do(enc, 1, CommonSecrets.Id)
+	return routeCreateCommand.do(ctx, enc, options, CommonSecrets.Id)
 }
 
-func DeleteCommand(command Snowflake) error {
-	_, err := routeDeleteCommand.do(nil, 1, CommonSecrets.Id, command)
+func DeleteCommand(ctx context.Context, command Snowflake, options ...RequestOption) error {
+	_, err := routeDeleteCommand.do(ctx, nil, options, CommonSecrets.Id, command)
 	return err
 }
 
@@ -196,8 +203,8 @@ func DeleteCommand(command Snowflake) error {
 // |                             MESSAGES                             |
 // --------------------------------------------------------------------
 
-func GetMessage(channel Snowflake, message Snowflake) (*Message, error) {
-	resp, err := routeGetMessage.do(nil, 1, channel, message)
+func GetMessage(ctx context.Context, channel Snowflake, message Snowflake, options ...RequestOption) (*Message, error) {
+	resp, err := routeGetMessage.do(ctx, nil, options, channel, message)
 	if err != nil {
 		return nil, err
 	}
@@ -209,7 +216,7 @@ func GetMessage(channel Snowflake, message Snowflake) (*Message, error) {
 	return &msg, nil
 }
 
-func CreateMessage(channel Snowflake, content string, tts bool) (*Message, error) {
+func CreateMessage(ctx context.Context, channel Snowflake, content string, tts bool, options ...RequestOption) (*Message, error) {
 	enc, err := json.Marshal(struct {
 		Content string `json:"content"`
 		Tts     bool   `json:"tts"`
@@ -218,7 +225,7 @@ func CreateMessage(channel Snowflake, content string, tts bool) (*Message, error
 		return nil, err
 	}
 
-	resp, err := routeCreateMessage.do(enc, 1, channel)
+	resp, err := routeCreateMessage.do(ctx, enc, options, channel)
 	if err != nil {
 		return nil, err
 	}
@@ -230,15 +237,15 @@ func CreateMessage(channel Snowflake, content string, tts bool) (*Message, error
 	return &msg, nil
 }
 
-func DeleteMessage(channel Snowflake, message Snowflake) error {
-	_, err := routeDeleteMessage.do(nil, 1, channel, message)
+func DeleteMessage(ctx context.Context, channel Snowflake, message Snowflake, options ...RequestOption) error {
+	_, err := routeDeleteMessage.do(ctx, nil, options, channel, message)
 	return err
 }
 
 // CreateReaction creates a reaction to a message using the bot account. emoji must be either a Unicode emoji for
 // built-in emojis or a string in the format "name:snowflake" for custom discord emojis.
-func CreateReaction(channelId Snowflake, messageId Snowflake, emoji string) error {
-	_, err := routeCreateMessage.do(nil, 1, channelId, messageId, emoji)
+func CreateReaction(ctx context.Context, channelId Snowflake, messageId Snowflake, emoji string, options ...RequestOption) error {
+	_, err := routeCreateReaction.do(ctx, nil, options, channelId, messageId, url.PathEscape(emoji))
 	return err
 }
 
@@ -246,11 +253,15 @@ func CreateReaction(channelId Snowflake, messageId Snowflake, emoji string) erro
 // |                             CHANNELS                             |
 // --------------------------------------------------------------------
 
-func GetChannel(channelId Snowflake) (*Channel, error) {
-	return getCacheable(ChannelCache, channelId, routeGetChannel, channelId)
+// GetChannel returns the channel from State if it is a known guild channel, otherwise it is fetched from discord.
+func GetChannel(ctx context.Context, channelId Snowflake, options ...RequestOption) (*Channel, error) {
+	if channel := State.Channel(channelId); channel != nil {
+		return channel, nil
+	}
+	return getCacheable(ctx, ChannelCache, channelId, routeGetChannel, options, channelId)
 }
 
-func CreateDM(recipient Snowflake) (*Channel, error) {
+func CreateDM(ctx context.Context, recipient Snowflake, options ...RequestOption) (*Channel, error) {
 	body, err := json.Marshal(struct {
 		Recipient Snowflake `json:"recipient_id"`
 	}{recipient})
@@ -258,7 +269,7 @@ func CreateDM(recipient Snowflake) (*Channel, error) {
 		return nil, err
 	}
 
-	resp, err := routeCreateDM.do(body, 1)
+	resp, err := routeCreateDM.do(ctx, body, options)
 	if err != nil {
 		return nil, err
 	}
@@ -274,45 +285,104 @@ func CreateDM(recipient Snowflake) (*Channel, error) {
 // |                              GUILDS                              |
 // --------------------------------------------------------------------
 
-func GetGuild(id Snowflake) (*Guild, error) {
-	return getCacheable(GuildCache, id, routeGetGuild, id)
+// GetGuild returns the guild from State if it is available, otherwise it is fetched from discord.
+func GetGuild(ctx context.Context, id Snowflake, options ...RequestOption) (*Guild, error) {
+	if guild := State.Guild(id); guild != nil {
+		return guild, nil
+	}
+	return getCacheable(ctx, GuildCache, id, routeGetGuild, options, id)
+}
+
+// FetchGuild fetches the guild with its roles and channels from discord, skipping State and the caches. The result can
+// be passed to State.AddGuild, for guilds which discord won't send a GUILD_CREATE for.
+func FetchGuild(ctx context.Context, id Snowflake, options ...RequestOption) (*CreateGuildPayload, error) {
+	resp, err := routeGetGuild.do(ctx, nil, options, id)
+	if err != nil {
+		return nil, err
+	}
+	var guild CreateGuildPayload // Only the guild and its roles are sent, which is enough for State
+	if err = json.Unmarshal(resp, &guild); err != nil {
+		return nil, err
+	}
+
+	if resp, err = routeGetGuildChannels.do(ctx, nil, options, id); err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(resp, &guild.Channels); err != nil {
+		return nil, err
+	}
+	return &guild, nil
 }
 
-func GetRole(guildId Snowflake, roleId Snowflake) (*Role, error) {
-	return getCacheable(RoleCache, roleId, routeGetRole, guildId, roleId)
+// GetRole returns the role from State if its guild is available, otherwise it is fetched from discord.
+func GetRole(ctx context.Context, guildId Snowflake, roleId Snowflake, options ...RequestOption) (*Role, error) {
+	if role := State.Role(guildId, roleId); role != nil {
+		return role, nil
+	}
+	return getCacheable(ctx, RoleCache, roleId, routeGetRole, options, guildId, roleId)
 }
 
-func GetGuildMember(guild Snowflake, guildMemberId Snowflake) (*GuildMember, error) {
-	return getCacheable(GuildMemberCache, guildMemberId, routeGetGuildMember, guild, guildMemberId)
+// GetGuildMember returns the member from State if it is known, otherwise it is fetched from discord.
+func GetGuildMember(ctx context.Context, guild Snowflake, guildMemberId Snowflake, options ...RequestOption) (*GuildMember, error) {
+	if member := State.Member(guild, guildMemberId); member != nil {
+		return member, nil
+	}
+	return getCacheable(ctx, GuildMemberCache, guildMemberId, routeGetGuildMember, options, guild, guildMemberId)
 }
 
-func ModifyGuildMember(guildId Snowflake, userId Snowflake, payload ModifyGuildMemberPayload) error {
+func ModifyGuildMember(ctx context.Context, guildId Snowflake, userId Snowflake, payload ModifyGuildMemberPayload, options ...RequestOption) error {
 	enc, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
-	_, err = routeModifyGuildMember.do(enc, 1, guildId, userId)
+	_, err = routeModifyGuildMember.do(ctx, enc, options, guildId, userId)
 	return err
 }
 
-func KickUser(guildId Snowflake, userId Snowflake) error {
-	_, err := routeKickGuildMember.do(nil, 1, guildId, userId)
+func KickUser(ctx context.Context, guildId Snowflake, userId Snowflake, options ...RequestOption) error {
+	_, err := routeKickGuildMember.do(ctx, nil, options, guildId, userId)
 	return err
 }
 
-func CreateBan(guildId Snowflake, userId Snowflake, deleteSeconds int) error {
+func CreateBan(ctx context.Context, guildId Snowflake, userId Snowflake, deleteSeconds int, options ...RequestOption) error {
 	enc, err := json.Marshal(struct {
 		Seconds int `json:"delete_message_seconds"`
 	}{deleteSeconds})
 	if err != nil {
 		return err
 	}
-	_, err = routeCreateGuildBan.do(enc, 1, guildId, userId)
+	_, err = routeCreateGuildBan.do(ctx, enc, options, guildId, userId)
 	return err
 }
 
-func DeleteBan(guildId Snowflake, userId Snowflake) error {
-	_, err := routeDeleteGuildBan.do(nil, 1, guildId, userId)
+func DeleteBan(ctx context.Context, guildId Snowflake, userId Snowflake, options ...RequestOption) error {
+	_, err := routeDeleteGuildBan.do(ctx, nil, options, guildId, userId)
+	return err
+}
+
+// --------------------------------------------------------------------
+// |                           INTERACTIONS                           |
+// --------------------------------------------------------------------
+
+// CreateInteractionResponse sends the initial response to an interaction, response is sent as JSON
+func CreateInteractionResponse(ctx context.Context, id Snowflake, token string, response any, options ...RequestOption) error {
+	enc, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	_, err = routeCreateInteractionResponse.doUnlimited(ctx, enc, options, id, token)
+	return err
+}
+
+// EditOriginalInteractionResponse replaces the content of the initial response to an interaction
+func EditOriginalInteractionResponse(ctx context.Context, token string, content string, options ...RequestOption) error {
+	enc, err := json.Marshal(struct {
+		Content string `json:"content"`
+	}{content})
+	if err != nil {
+		return err
+	}
+	_, err = routeEditOriginalInteractionResponse.doUnlimited(ctx, enc, options, CommonSecrets.Id, token)
 	return err
 }
 
@@ -0,0 +1,192 @@
+package restapi
+
+import (
+	. "elaina-common"
+	"elaina-common/discordtest"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startFakeDiscord sends every REST request to a new fake discord, with a fresh rateLimiter
+func startFakeDiscord(t *testing.T) *discordtest.Server {
+	server := discordtest.NewServer()
+	limiter = newRateLimiter()
+	SetApiUrl(server.URL)
+	t.Cleanup(func() {
+		ResetUnauthorized()
+		SetApiUrl("")
+		server.Close()
+	})
+	return server
+}
+
+// Tests the message, reaction, channel and DM routes against the fake discord
+func TestMessages(t *testing.T) {
+	ctx := t.Context()
+	server := startFakeDiscord(t)
+	server.AddChannel(Channel{Id: 100, Type: 0, GuildId: 10})
+
+	// TEST CASE: Channels unknown to State are fetched
+	channel, err := GetChannel(ctx, 100)
+	require.NoError(t, err)
+	assert.Equal(t, Snowflake(10), channel.GuildId)
+	_, err = GetChannel(ctx, 101)
+	assert.Error(t, err)
+
+	// TEST CASE: Messages can be created, fetched, reacted to and deleted
+	message, err := CreateMessage(ctx, 100, "hello", false)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", message.Content)
+
+	fetched, err := GetMessage(ctx, 100, message.Id)
+	require.NoError(t, err)
+	assert.Equal(t, message.Id, fetched.Id)
+
+	require.NoError(t, CreateReaction(ctx, 100, message.Id, "👋"))
+	require.NoError(t, CreateReaction(ctx, 100, message.Id, "elaina:123"))
+	assert.Equal(t, []string{"👋", "elaina:123"}, server.Reactions(message.Id))
+
+	require.NoError(t, DeleteMessage(ctx, 100, message.Id))
+	assert.Empty(t, server.Messages(100))
+	assert.Error(t, DeleteMessage(ctx, 100, message.Id))
+
+	// TEST CASE: A DM channel is created once per user and can be messaged
+	dm, err := CreateDM(ctx, 7)
+	require.NoError(t, err)
+	again, err := CreateDM(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, dm.Id, again.Id)
+	_, err = CreateMessage(ctx, dm.Id, "psst", false)
+	require.NoError(t, err)
+	require.Len(t, server.DMs(7), 1)
+	assert.Equal(t, "psst", server.DMs(7)[0].Content)
+}
+
+// Tests the guild, role, member and ban routes against the fake discord
+func TestGuilds(t *testing.T) {
+	ctx := t.Context()
+	server := startFakeDiscord(t)
+	server.AddGuild(Guild{Id: 20, Name: "Witches", OwnerId: 1})
+	server.AddRole(20, Role{Id: 21, Name: "Apprentice"})
+	server.AddMember(20, GuildMember{User: &User{Id: 22, Username: "saya"}, Roles: []Snowflake{21}})
+	server.AddMember(20, GuildMember{User: &User{Id: 23, Username: "fran"}})
+
+	// TEST CASE: Guilds, roles and members unknown to State are fetched
+	guild, err := GetGuild(ctx, 20)
+	require.NoError(t, err)
+	assert.Equal(t, "Witches", guild.Name)
+	role, err := GetRole(ctx, 20, 21)
+	require.NoError(t, err)
+	assert.Equal(t, "Apprentice", role.Name)
+	member, err := GetGuildMember(ctx, 20, 22)
+	require.NoError(t, err)
+	assert.Equal(t, "saya", member.User.Username)
+	_, err = GetGuildMember(ctx, 20, 24)
+	assert.Error(t, err)
+
+	// TEST CASE: Members can be timed out and kicked
+	until := time.Now().Add(time.Hour)
+	require.NoError(t, ModifyGuildMember(ctx, 20, 22, ModifyGuildMemberPayload{CommunicationDisabledUntil: &Nullable[time.Time]{Value: until}}))
+	assert.NotEmpty(t, server.Member(20, 22).CommunicationDisabledUntil)
+	require.NoError(t, ModifyGuildMember(ctx, 20, 22, ModifyGuildMemberPayload{CommunicationDisabledUntil: &Nullable[time.Time]{Null: true}}))
+	assert.Empty(t, server.Member(20, 22).CommunicationDisabledUntil)
+
+	require.NoError(t, KickUser(ctx, 20, 23))
+	assert.Nil(t, server.Member(20, 23))
+
+	// TEST CASE: Banning removes the member, unbanning removes the ban
+	require.NoError(t, CreateBan(ctx, 20, 22, 60))
+	assert.NotNil(t, server.Ban(20, 22))
+	assert.Nil(t, server.Member(20, 22))
+	require.NoError(t, DeleteBan(ctx, 20, 22))
+	assert.Nil(t, server.Ban(20, 22))
+	assert.Error(t, DeleteBan(ctx, 20, 22))
+}
+
+// Tests that audit log reasons are sent URL encoded on moderation calls, and checked before being sent
+func TestAuditLogReason(t *testing.T) {
+	ctx := t.Context()
+	server := startFakeDiscord(t)
+	server.AddGuild(Guild{Id: 60})
+	server.AddMember(60, GuildMember{User: &User{Id: 61}})
+	server.AddMember(60, GuildMember{User: &User{Id: 62}})
+
+	// TEST CASE: Reasons reach the audit log and ban intact, including characters which must be encoded
+	reason := "Spamming 🍞 in #general/#memes, 100% rude\nagain"
+	require.NoError(t, ModifyGuildMember(ctx, 60, 61, ModifyGuildMemberPayload{Nick: &Nullable[string]{Value: "muted"}}, WithReason("Rename")))
+	require.NoError(t, CreateBan(ctx, 60, 61, 0, WithReason(reason)))
+	require.NoError(t, DeleteBan(ctx, 60, 61, WithReason("Appealed")))
+	require.NoError(t, KickUser(ctx, 60, 62))
+
+	auditLog := server.AuditLog(60)
+	require.Len(t, auditLog, 4)
+	assert.Equal(t, []int{discordtest.AuditMemberUpdate, discordtest.AuditMemberBanAdd, discordtest.AuditMemberBanRemove, discordtest.AuditMemberKick},
+		[]int{auditLog[0].ActionType, auditLog[1].ActionType, auditLog[2].ActionType, auditLog[3].ActionType})
+	assert.Equal(t, []string{"Rename", reason, "Appealed", ""},
+		[]string{auditLog[0].Reason, auditLog[1].Reason, auditLog[2].Reason, auditLog[3].Reason})
+	assert.NotContains(t, server.Requests()[1].Header.Get("X-Audit-Log-Reason"), " ")
+
+	// TEST CASE: Reasons are limited by characters rather than bytes
+	require.NoError(t, CreateBan(ctx, 60, 63, 0, WithReason(strings.Repeat("🍞", MaxAuditLogReason))))
+	assert.Equal(t, strings.Repeat("🍞", MaxAuditLogReason), server.Ban(60, 63).Reason)
+
+	// TEST CASE: Calls with a reason which is too long fail without being sent
+	requests := len(server.Requests())
+	err := CreateBan(ctx, 60, 64, 0, WithReason(strings.Repeat("a", MaxAuditLogReason+1)))
+	assert.ErrorIs(t, err, ErrReasonTooLong)
+	assert.Nil(t, server.Ban(60, 64))
+	assert.Len(t, server.Requests(), requests)
+}
+
+// Tests the application command routes against the fake discord
+func TestCommands(t *testing.T) {
+	ctx := t.Context()
+	server := startFakeDiscord(t)
+	CommonSecrets.Id = "99"
+	defer func() { CommonSecrets.Id = "" }()
+
+	// TEST CASE: Commands are created, and overwritten when created again with the same name
+	_, err := CreateOrUpdateCommand(ctx, &ApplicationCommand{Name: "ban", Description: "Bans a user"})
+	require.NoError(t, err)
+	_, err = CreateOrUpdateCommand(ctx, &ApplicationCommand{Name: "ban", Description: "Bans a user, again"})
+	require.NoError(t, err)
+	commands := server.Commands()
+	require.Len(t, commands, 1)
+	assert.Equal(t, "Bans a user, again", commands[0].Description)
+	assert.Equal(t, Snowflake(99), commands[0].ApplicationId)
+
+	// TEST CASE: Commands can be deleted
+	require.NoError(t, DeleteCommand(ctx, commands[0].Id))
+	assert.Empty(t, server.Commands())
+}
+
+// Tests that requests follow the fake's rate limit headers and are retried after failures
+func TestFakeDiscordLimits(t *testing.T) {
+	ctx := t.Context()
+	server := startFakeDiscord(t)
+	server.Limit, server.Window = 2, time.Millisecond*200
+	server.AddChannel(Channel{Id: 300})
+
+	// TEST CASE: Requests wait for the bucket to reset instead of hitting 429s
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := CreateMessage(ctx, 300, "spam", false)
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*400)
+	assert.Len(t, server.Requests(), 5, "no request should have been rate limited and retried")
+
+	// TEST CASE: Server errors are retried, other errors are returned
+	server.FailNext("POST /channels/{channel}/messages", discordtest.Error{Status: http.StatusBadGateway})
+	_, err := CreateMessage(ctx, 300, "retried", false)
+	require.NoError(t, err)
+
+	server.FailNext("POST /channels/{channel}/messages", discordtest.Error{Status: http.StatusForbidden, Code: discordtest.CodeMissingPermissions, Message: "Missing Permissions"})
+	_, err = CreateMessage(ctx, 300, "forbidden", false)
+	assert.ErrorContains(t, err, "Missing Permissions")
+}
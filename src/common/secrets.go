@@ -2,7 +2,7 @@ package common
 
 const BaseApiUrl = "https://discord.com/api/v" + ApiVersion
 const ApiVersion = "10"
-const ApiEncoding = "json"
+const ApiEncoding = "json" // Default gateway encoding, see UnmarshalEtf for the alternative
 
 var CommonSecrets = struct {
 	Id       string // Client ID
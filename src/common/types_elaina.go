@@ -2,7 +2,9 @@ package common
 
 import (
 	"encoding/json"
+	"fmt"
 	"strconv"
+	"time"
 )
 
 // Macro represents a text macro where a given trigger string sends a response message in the chat.
@@ -25,6 +27,17 @@ func DefaultGuildSettings() GuildSettings {
 	}
 }
 
+// GatewaySession represents the state needed for a shard to resume its gateway session after the bot restarts.
+type GatewaySession struct {
+	ShardId    int         `json:"shard_id"`
+	ShardCount int         `json:"shard_count"`
+	SessionId  string      `json:"session_id"`
+	ResumeUrl  string      `json:"resume_url"`
+	Sequence   int32       `json:"sequence"`
+	SavedAt    time.Time   `json:"saved_at"`
+	Guilds     []Snowflake `json:"guilds"` // Guilds of the shard, as resuming doesn't send READY or GUILD_CREATE again
+}
+
 // Nullable represents a serializable primitive which can also be Null. For example, representing a Null string
 type Nullable[T any] struct {
 	Value T
@@ -46,10 +59,16 @@ func (s *StringInt64) String() string {
 }
 
 func (s *StringInt64) UnmarshalJSON(data []byte) error {
-	var str string
-	if err := json.Unmarshal(data, &str); err != nil {
-		return err
+	str := string(data)
+	if str == "null" { // Nullable fields such as a channel's parent_id are left as 0
+		return nil
 	}
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+	} // Otherwise it's a plain number, which is how ETF transcoded payloads represent snowflakes
+
 	id, err := strconv.ParseUint(str, 10, 64)
 	if err != nil {
 		return err
@@ -58,6 +77,47 @@ func (s *StringInt64) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// unmarshalEtf accepts the integers discord sends snowflakes as in ETF payloads, as well as binaries and nil, without
+// transcoding the term into JSON.
+func (s *StringInt64) unmarshalEtf(r *etfReader) error {
+	if r.skipNil() { // Nullable fields are left as 0, like with JSON
+		return nil
+	}
+	tag, err := r.uint8()
+	if err != nil {
+		return err
+	}
+
+	switch tag {
+	case etfSmallInteger, etfInteger, etfSmallBig, etfLargeBig:
+		id, negative, err := r.integer(tag)
+		if err != nil {
+			return err
+		}
+		if negative {
+			return fmt.Errorf("%w: negative snowflake", ErrInvalidEtf)
+		}
+		*s = StringInt64(id)
+		return nil
+	case etfBinary:
+		n, err := r.uint32()
+		if err != nil {
+			return err
+		}
+		b, err := r.read(n)
+		if err != nil {
+			return err
+		}
+		id, err := strconv.ParseUint(string(b), 10, 64)
+		if err != nil {
+			return err
+		}
+		*s = StringInt64(id)
+		return nil
+	}
+	return fmt.Errorf("%w: can't decode tag %d into a snowflake", ErrInvalidEtf, tag)
+}
+
 func (s *StringInt64) MarshalJSON() ([]byte, error) {
 	return json.Marshal(s.String())
 }
@@ -437,3 +437,103 @@ type GuildBan struct {
 	User   User   `json:"user"`
 	Reason string `json:"reason"`
 }
+
+// Activity represents https://discord.com/developers/docs/events/gateway-events#activity-object. Bots can only send
+// Name, Type, Url and State, the other fields are only present when receiving presences.
+type Activity struct {
+	Name          string       `json:"name"`
+	Type          ActivityType `json:"type"`
+	Url           string       `json:"url,omitempty"`            // Optional, nullable. Only used by ActivityStreaming
+	CreatedAt     int64        `json:"created_at,omitempty"`     // Receive only
+	ApplicationId Snowflake    `json:"application_id,omitempty"` // Optional, receive only
+	Details       string       `json:"details,omitempty"`        // Optional, nullable
+	State         string       `json:"state,omitempty"`          // Optional, nullable. Text of the status for ActivityCustom
+	Emoji         *Emoji       `json:"emoji,omitempty"`          // Optional, nullable. Receive only
+	Flags         int          `json:"flags,omitempty"`          // Optional, receive only
+}
+
+// ClientStatus represents https://discord.com/developers/docs/events/gateway-events#client-status-object
+type ClientStatus struct {
+	Desktop string `json:"desktop,omitempty"` // Optional
+	Mobile  string `json:"mobile,omitempty"`  // Optional
+	Web     string `json:"web,omitempty"`     // Optional
+}
+
+// VoiceState represents https://discord.com/developers/docs/resources/voice#voice-state-object
+type VoiceState struct {
+	GuildId                 Snowflake    `json:"guild_id"`   // Optional
+	ChannelId               Snowflake    `json:"channel_id"` // Nullable, 0 once the user leaves the voice channel
+	UserId                  Snowflake    `json:"user_id"`
+	Member                  *GuildMember `json:"member"` // Optional
+	SessionId               string       `json:"session_id"`
+	Deaf                    bool         `json:"deaf"`
+	Mute                    bool         `json:"mute"`
+	SelfDeaf                bool         `json:"self_deaf"`
+	SelfMute                bool         `json:"self_mute"`
+	SelfStream              bool         `json:"self_stream"` // Optional
+	SelfVideo               bool         `json:"self_video"`
+	Suppress                bool         `json:"suppress"`
+	RequestToSpeakTimestamp string       `json:"request_to_speak_timestamp"` // Nullable
+}
+
+// GuildScheduledEvent represents https://discord.com/developers/docs/resources/guild-scheduled-event#guild-scheduled-event-object
+type GuildScheduledEvent struct {
+	Id                 Snowflake       `json:"id"`
+	GuildId            Snowflake       `json:"guild_id"`
+	ChannelId          Snowflake       `json:"channel_id"` // Nullable
+	CreatorId          Snowflake       `json:"creator_id"` // Optional, nullable
+	Name               string          `json:"name"`
+	Description        string          `json:"description"` // Optional, nullable
+	ScheduledStartTime string          `json:"scheduled_start_time"`
+	ScheduledEndTime   string          `json:"scheduled_end_time"` // Nullable
+	PrivacyLevel       int             `json:"privacy_level"`
+	Status             int             `json:"status"`          // 1 = SCHEDULED, 2 = ACTIVE, 3 = COMPLETED, 4 = CANCELED
+	EntityType         int             `json:"entity_type"`     // 1 = STAGE_INSTANCE, 2 = VOICE, 3 = EXTERNAL
+	EntityId           Snowflake       `json:"entity_id"`       // Nullable
+	EntityMetadata     json.RawMessage `json:"entity_metadata"` // Nullable
+	Creator            *User           `json:"creator"`         // Optional
+	UserCount          int             `json:"user_count"`      // Optional
+	Image              string          `json:"image"`           // Optional, nullable
+}
+
+// StageInstance represents https://discord.com/developers/docs/resources/stage-instance#stage-instance-object
+type StageInstance struct {
+	Id                    Snowflake `json:"id"`
+	GuildId               Snowflake `json:"guild_id"`
+	ChannelId             Snowflake `json:"channel_id"`
+	Topic                 string    `json:"topic"`
+	PrivacyLevel          int       `json:"privacy_level"`
+	GuildScheduledEventId Snowflake `json:"guild_scheduled_event_id"` // Nullable
+}
+
+// AutoModerationRule represents https://discord.com/developers/docs/resources/auto-moderation#auto-moderation-rule-object
+type AutoModerationRule struct {
+	Id              Snowflake              `json:"id"`
+	GuildId         Snowflake              `json:"guild_id"`
+	Name            string                 `json:"name"`
+	CreatorId       Snowflake              `json:"creator_id"`
+	EventType       int                    `json:"event_type"`   // 1 = MESSAGE_SEND, 2 = MEMBER_UPDATE
+	TriggerType     int                    `json:"trigger_type"` // 1 = KEYWORD, 3 = SPAM, 4 = KEYWORD_PRESET, 5 = MENTION_SPAM, 6 = MEMBER_PROFILE
+	TriggerMetadata json.RawMessage        `json:"trigger_metadata"`
+	Actions         []AutoModerationAction `json:"actions"`
+	Enabled         bool                   `json:"enabled"`
+	ExemptRoles     []Snowflake            `json:"exempt_roles"`
+	ExemptChannels  []Snowflake            `json:"exempt_channels"`
+}
+
+// AutoModerationAction represents https://discord.com/developers/docs/resources/auto-moderation#auto-moderation-action-object
+type AutoModerationAction struct {
+	Type     int             `json:"type"`     // 1 = BLOCK_MESSAGE, 2 = SEND_ALERT_MESSAGE, 3 = TIMEOUT, 4 = BLOCK_MEMBER_INTERACTION
+	Metadata json.RawMessage `json:"metadata"` // Optional
+}
+
+// AuditLogEntry represents https://discord.com/developers/docs/resources/audit-log#audit-log-entry-object
+type AuditLogEntry struct {
+	Id         Snowflake       `json:"id"`
+	TargetId   string          `json:"target_id"` // Nullable
+	Changes    json.RawMessage `json:"changes"`   // Optional
+	UserId     Snowflake       `json:"user_id"`   // Nullable
+	ActionType int             `json:"action_type"`
+	Options    json.RawMessage `json:"options"` // Optional
+	Reason     string          `json:"reason"`  // Optional
+}
@@ -27,3 +27,8 @@ var DiscordEpoch = int64(1420070400000)
 func TimeToSnowflake(t time.Time) Snowflake {
 	return Snowflake((t.UnixMilli() - DiscordEpoch) << 22)
 }
+
+// SnowflakeToTime returns when the given snowflake was created
+func SnowflakeToTime(id Snowflake) time.Time {
+	return time.UnixMilli(int64(id>>22) + DiscordEpoch)
+}